@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/robinovitch61/wander/internal/tui/components/app"
+	"github.com/robinovitch61/wander/internal/tui/nomad"
+	"github.com/spf13/cobra"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// completionCacheTTL is how long the jobs and allocations fetched for shell completion are reused for, so that
+// pressing tab repeatedly stays fast
+const completionCacheTTL = 30 * time.Second
+
+type completionCache struct {
+	FetchedAt  time.Time
+	Candidates nomad.CompletionCandidates
+}
+
+// completeTargets completes the first argument with job IDs and, if allocs is true, short allocation IDs, only of
+// running allocations if runningOnly is true
+func completeTargets(allocs, runningOnly bool) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		candidates, ok := completionCandidates(cmd)
+		if !ok {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		completions := candidates.JobCompletions(toComplete)
+		if allocs {
+			completions = append(completions, candidates.AllocCompletions(toComplete, runningOnly)...)
+		}
+		return completions, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// completeCopyArgs completes paths in a task as <alloc id or job>: for the rest of the path to be typed, and local
+// paths as files
+func completeCopyArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 1 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	if strings.ContainsAny(toComplete, `:/\.~`) {
+		return nil, cobra.ShellCompDirectiveDefault
+	}
+	candidates, ok := completionCandidates(cmd)
+	if !ok {
+		return nil, cobra.ShellCompDirectiveDefault
+	}
+	var completions []string
+	for _, c := range append(candidates.JobCompletions(toComplete), candidates.AllocCompletions(toComplete, true)...) {
+		id, description, _ := strings.Cut(c, "\t")
+		completions = append(completions, id+":\t"+description)
+	}
+	if len(completions) == 0 {
+		return nil, cobra.ShellCompDirectiveDefault
+	}
+	return completions, cobra.ShellCompDirectiveNoSpace | cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeTask completes --task with the tasks of the allocations that the job or allocation argument refers to
+func completeTask(runningOnly bool) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		candidates, ok := completionCandidates(cmd)
+		if !ok {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return candidates.TaskCompletions(completionTarget(cmd, args), toComplete, runningOnly), cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// completeTaskGroup completes --group with the task groups of the running allocations of the job argument
+func completeTaskGroup(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	candidates, ok := completionCandidates(cmd)
+	if !ok {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return candidates.TaskGroupCompletions(completionTarget(cmd, args), toComplete, true), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completionTarget is the job or allocation that the arguments refer to, which for cp is the one of the path in a task
+func completionTarget(cmd *cobra.Command, args []string) string {
+	if cmd == cpCmd {
+		for _, a := range args {
+			if target, _, remote := nomad.ParseCopyPath(a); remote {
+				return target
+			}
+		}
+		return ""
+	}
+	if len(args) == 0 {
+		return ""
+	}
+	return args[0]
+}
+
+// completionCandidates fetches the jobs and allocations to complete from, or reuses those fetched in the last
+// completionCacheTTL for the same cluster, namespace and token
+func completionCandidates(cmd *cobra.Command) (nomad.CompletionCandidates, bool) {
+	// completions don't run the hook that reads the config file
+	_ = initConfig(cmd, rootNameToArg)
+	config := getConfig(cmd, []string{}, "")
+
+	cachePath := completionCachePath(config)
+	if cachePath != "" {
+		if b, err := os.ReadFile(cachePath); err == nil {
+			var cache completionCache
+			if json.Unmarshal(b, &cache) == nil && time.Since(cache.FetchedAt) < completionCacheTTL {
+				return cache.Candidates, true
+			}
+		}
+	}
+
+	client, err := config.Client()
+	if err != nil {
+		return nomad.CompletionCandidates{}, false
+	}
+	candidates, err := nomad.FetchCompletionCandidates(*client)
+	if err != nil {
+		return nomad.CompletionCandidates{}, false
+	}
+	if cachePath != "" {
+		if b, err := json.Marshal(completionCache{FetchedAt: time.Now(), Candidates: candidates}); err == nil {
+			if os.MkdirAll(filepath.Dir(cachePath), 0700) == nil {
+				_ = os.WriteFile(cachePath, b, 0600)
+			}
+		}
+	}
+	return candidates, true
+}
+
+// completionCachePath is where completion candidates are cached for the config's cluster, namespace and token, empty
+// if there's no cache directory. The token is hashed so it isn't written anywhere.
+func completionCachePath(config app.Config) string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	key := sha256.Sum256([]byte(strings.Join([]string{config.URL, config.Region, config.Namespace, config.Token, config.HTTPAuth}, "\n")))
+	return filepath.Join(dir, "wander", "completion", hex.EncodeToString(key[:8])+".json")
+}
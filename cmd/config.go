@@ -0,0 +1,328 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"fmt"
+	"github.com/robinovitch61/wander/internal/tui/nomad"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+var (
+	configCmd = &cobra.Command{
+		Use:   "config",
+		Short: "Validate or show the config",
+		Long:  `Validate the options set by flags, env vars and the config file, or show the value each option ends up with and where it came from`,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			// recorded before initConfig, which marks flags set from env vars or the config file as changed too
+			cmd.Flags().Visit(func(f *pflag.Flag) {
+				flagsSetOnCommandLine[f.Name] = true
+			})
+			return initConfig(cmd, rootNameToArg)
+		},
+		Run: func(cmd *cobra.Command, _ []string) {
+			_ = cmd.Help()
+		},
+	}
+
+	configValidateCmd = &cobra.Command{
+		Use:   "validate",
+		Short: "Check every option",
+		Long: `Check every option, e.g. that jq queries compile, columns exist or look like meta keys, TLS files are readable
+and the address parses, exiting 1 if any are invalid`,
+		Example: `
+  # check the default config file, ~/.wander.yaml, along with env vars
+  wander config validate
+
+  # check another config file
+  wander config validate --config ./wander.yaml
+`,
+		Run: configValidateEntrypoint,
+	}
+
+	configShowCmd = &cobra.Command{
+		Use:   "show",
+		Short: "Show the effective value of each option",
+		Long:  `Show the value each option ends up with and whether it came from a flag, an env var, the config file or the default`,
+		Run:   configShowEntrypoint,
+	}
+
+	flagsSetOnCommandLine = make(map[string]bool)
+
+	// metaKeyRegex matches job columns that could be meta keys
+	metaKeyRegex = regexp.MustCompile(`^[A-Za-z0-9_.\-]+$`)
+)
+
+// configOptionsFromFileOnly can't be set by flags
+var configOptionsFromFileOnly = []string{"logo-color", "event-presets", "alert-rules"}
+
+// configOptionsHidden have values that are secret
+var configOptionsHidden = map[string]bool{"token": true, "http-auth": true}
+
+type configProblems struct {
+	errors   []string
+	warnings []string
+}
+
+func (p *configProblems) error(option, format string, a ...interface{}) {
+	p.errors = append(p.errors, fmt.Sprintf("%s: %s", configKey(option), fmt.Sprintf(format, a...)))
+}
+
+func (p *configProblems) warn(option, format string, a ...interface{}) {
+	p.warnings = append(p.warnings, fmt.Sprintf("%s: %s", configKey(option), fmt.Sprintf(format, a...)))
+}
+
+// configKey is the config file key of a root or serve option
+func configKey(option string) string {
+	if a, exists := rootNameToArg[option]; exists {
+		return a.cfgFileEnvVar
+	}
+	return serveNameToArg[option].cfgFileEnvVar
+}
+
+func configValidateEntrypoint(cmd *cobra.Command, _ []string) {
+	var problems configProblems
+	value := func(option string) string {
+		return cmd.Flags().Lookup(option).Value.String()
+	}
+
+	if addr, err := url.Parse(value("addr")); err != nil {
+		problems.error("addr", "%v", err)
+	} else if (addr.Scheme != "http" && addr.Scheme != "https") || addr.Host == "" {
+		problems.error("addr", "%s must be an http or https url, e.g. http://localhost:4646", value("addr"))
+	}
+	if err := validateToken(value("token")); err != nil {
+		problems.error("token", "%v", err)
+	}
+	if tokenFile := value("token-file"); tokenFile != "" {
+		if _, err := os.ReadFile(tokenFile); err != nil {
+			problems.error("token-file", "%v", err)
+		}
+		if value("token-command") != "" {
+			problems.warn("token-command", "ignored as token-file is set")
+		}
+	}
+	if value("token") != "" {
+		for _, option := range []string{"token-file", "token-command"} {
+			if value(option) != "" {
+				problems.warn(option, "ignored as token is set")
+			}
+		}
+	}
+	validateTLSConfig(cmd, &problems)
+
+	for _, option := range []string{"job-columns", "all-tasks-columns", "tasks-for-job-columns"} {
+		known := nomad.TaskColumns
+		if option == "job-columns" {
+			known = nomad.JobColumns
+		}
+		for _, col := range strings.Split(value(option), ",") {
+			col = strings.TrimSpace(col)
+			switch {
+			case col == "":
+				problems.error(option, "empty column")
+			case slices.Contains(known, col):
+			case option == "job-columns" && metaKeyRegex.MatchString(col):
+				problems.warn(option, "%s is not a known column, so is shown from the job meta, or - if no job has that meta key", col)
+			default:
+				problems.error(option, "unknown column %s, must be one of %s", col, strings.Join(known, ", "))
+			}
+		}
+	}
+
+	for option, minimum := range map[string]int{
+		"update":               -1,
+		"log-offset":           0,
+		"log-record-max-bytes": 0,
+		"log-max-rows":         0,
+		"event-max-rows":       0,
+		"exec-scrollback":      0,
+		"exec-all-concurrency": 1,
+	} {
+		if v, err := strconv.Atoi(value(option)); err != nil || v < minimum {
+			problems.error(option, "%s must be an integer of at least %d", value(option), minimum)
+		}
+	}
+
+	if regexString := value("log-group-regex"); regexString != "" {
+		if _, err := regexp.Compile(regexString); err != nil {
+			problems.error("log-group-regex", "%v", err)
+		}
+	}
+	for _, option := range []string{"event-topics", "alert-topics"} {
+		if _, err := nomad.ParseTopics(value(option)); err != nil {
+			problems.error(option, "%v", err)
+		}
+	}
+	for _, option := range []string{"event-jq-query", "alloc-event-jq-query", "event-summary-jq-query"} {
+		if _, err := nomad.CompileJQQuery(value(option)); err != nil {
+			problems.error(option, "%v", err)
+		}
+	}
+	if dir := value("exec-record-dir"); dir != "" {
+		if info, err := os.Stat(dir); err == nil && !info.IsDir() {
+			problems.error("exec-record-dir", "%s is not a directory", dir)
+		} else if os.IsNotExist(err) {
+			problems.warn("exec-record-dir", "%s doesn't exist yet, so will be created", dir)
+		}
+	}
+
+	presets, err := parseEventPresets()
+	if err != nil {
+		problems.error("event-presets", "%v", err)
+	}
+	for _, preset := range presets {
+		if _, err := nomad.ParseTopics(preset.Topics); err != nil {
+			problems.error("event-presets", "preset %s: %v", preset.Name, err)
+		}
+		if _, err := nomad.CompileJQQuery(preset.JQQuery); err != nil {
+			problems.error("event-presets", "preset %s: %v", preset.Name, err)
+		}
+	}
+	if _, err := parseAlertRules(); err != nil {
+		problems.error("alert-rules", "%v", err)
+	}
+	if users, err := parseServeUsers(); err != nil {
+		problems.error("serve-users", "%v", err)
+	} else if err := validateServeAuth(viper.GetString(configKey("authorized-keys")), users); err != nil {
+		problems.error("authorized-keys", "%v", err)
+	}
+
+	knownKeys := make(map[string]bool)
+	for _, nameToArg := range []map[string]arg{rootNameToArg, serveNameToArg} {
+		for _, a := range nameToArg {
+			knownKeys[a.cfgFileEnvVar] = true
+		}
+	}
+	for _, key := range viper.AllKeys() {
+		if !knownKeys[key] && viper.InConfig(key) {
+			problems.warnings = append(problems.warnings, fmt.Sprintf("%s: unknown option in %s", key, viper.ConfigFileUsed()))
+		}
+	}
+
+	// map iteration above isn't ordered
+	sort.Strings(problems.errors)
+	sort.Strings(problems.warnings)
+	for _, w := range problems.warnings {
+		fmt.Printf("warning: %s\n", w)
+	}
+	for _, e := range problems.errors {
+		fmt.Printf("error: %s\n", e)
+	}
+	if len(problems.errors) > 0 {
+		os.Exit(1)
+	}
+	fmt.Println("config is valid")
+}
+
+func validateTLSConfig(cmd *cobra.Command, problems *configProblems) {
+	value := func(option string) string {
+		return cmd.Flags().Lookup(option).Value.String()
+	}
+	for _, option := range []string{"cacert", "client-cert", "client-key"} {
+		if path := value(option); path != "" {
+			if _, err := os.ReadFile(path); err != nil {
+				problems.error(option, "%v", err)
+			}
+		}
+	}
+	if capath := value("capath"); capath != "" {
+		if _, err := os.ReadDir(capath); err != nil {
+			problems.error("capath", "%v", err)
+		}
+	}
+
+	clientCert, clientKey := value("client-cert"), value("client-key")
+	switch {
+	case clientCert != "" && clientKey == "":
+		problems.error("client-key", "must be set with client-cert")
+	case clientCert == "" && clientKey != "":
+		problems.error("client-cert", "must be set with client-key")
+	case clientCert != "":
+		if _, err := tls.LoadX509KeyPair(clientCert, clientKey); err != nil {
+			problems.error("client-cert", "%v", err)
+		}
+	}
+}
+
+func configShowEntrypoint(cmd *cobra.Command, _ []string) {
+	var options []string
+	for option := range rootNameToArg {
+		if option != "config" && option != "help" {
+			options = append(options, option)
+		}
+	}
+	sort.Slice(options, func(i, j int) bool {
+		return rootNameToArg[options[i]].cfgFileEnvVar < rootNameToArg[options[j]].cfgFileEnvVar
+	})
+
+	configFile := viper.ConfigFileUsed()
+	if configFile == "" {
+		configFile = "none"
+	}
+	fmt.Printf("config file: %s\n\n", configFile)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "OPTION\tSOURCE\tVALUE")
+	for _, option := range options {
+		key := rootNameToArg[option].cfgFileEnvVar
+		var value string
+		if slices.Contains(configOptionsFromFileOnly, option) {
+			value = configFileOnlyValue(option)
+		} else {
+			value = cmd.Flags().Lookup(option).Value.String()
+		}
+		if configOptionsHidden[option] && value != "" {
+			value = "<hidden>"
+		}
+		if value == "" {
+			value = `""`
+		}
+		// source before value as values like jq queries are long
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", key, configSource(option), strings.Join(strings.Fields(value), " "))
+	}
+	_ = w.Flush()
+}
+
+func configFileOnlyValue(option string) string {
+	switch option {
+	case "event-presets":
+		presets, err := parseEventPresets()
+		if err != nil {
+			return fmt.Sprintf("invalid: %v", err)
+		}
+		return fmt.Sprintf("%d presets", len(presets))
+	case "alert-rules":
+		rules, err := parseAlertRules()
+		if err != nil {
+			return fmt.Sprintf("invalid: %v", err)
+		}
+		return fmt.Sprintf("%d rules", len(rules))
+	default:
+		return viper.GetString(rootNameToArg[option].cfgFileEnvVar)
+	}
+}
+
+// configSource follows the precedence of initConfig and bindFlags: flags, then env vars, then the config file
+func configSource(option string) string {
+	key := rootNameToArg[option].cfgFileEnvVar
+	switch {
+	case flagsSetOnCommandLine[option]:
+		return "flag"
+	case os.Getenv(strings.ToUpper(key)) != "":
+		return "env"
+	case viper.InConfig(key):
+		return "config file"
+	default:
+		return "default"
+	}
+}
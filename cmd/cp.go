@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/moby/term"
+	"github.com/robinovitch61/wander/internal/tui/nomad"
+	"github.com/spf13/cobra"
+	"os"
+	"os/signal"
+	"strconv"
+	"time"
+)
+
+var (
+	cpCmd = &cobra.Command{
+		Use:   "cp",
+		Short: "Copy files to and from a running task",
+		Long: `Copy a file or directory to or from a running nomad task, as a tar archive over exec, comparing sha256 checksums
+once copied. Paths in a task are written <alloc id or job>:<path>, using the same prefix matching as exec.`,
+		Example: `
+  # download a file from the only task of the only allocation of a job
+  wander cp alright_stop:/etc/redis.conf ./redis.conf
+
+  # upload a directory into a directory of a task
+  wander cp ./config 3dca0982:/local/ --task redis
+
+  # download from the allocation directory without exec, e.g. if the task has no tar, as with nomad alloc fs
+  wander cp --fs 3dca0982:redis/local/config.yml .
+`,
+		Run:               cpEntrypoint,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error { return nil },
+	}
+)
+
+func cpEntrypoint(cmd *cobra.Command, args []string) {
+	if len(args) == 0 {
+		_ = cmd.Help()
+		os.Exit(0)
+	}
+	if len(args) != 2 {
+		fmt.Println(fmt.Errorf("specify a source and a destination"))
+		os.Exit(1)
+	}
+
+	srcTarget, srcPath, srcRemote := nomad.ParseCopyPath(args[0])
+	dstTarget, dstPath, dstRemote := nomad.ParseCopyPath(args[1])
+	if srcRemote == dstRemote {
+		fmt.Println(fmt.Errorf("exactly one of the source and destination must be in a task, written <alloc id or job>:<path>"))
+		os.Exit(1)
+	}
+	useFS, _ := strconv.ParseBool(cmd.Flags().Lookup("fs").Value.String())
+	if useFS && dstRemote {
+		fmt.Println(fmt.Errorf("--fs only downloads, as the allocation file system can't be written to"))
+		os.Exit(1)
+	}
+	target := srcTarget
+	if dstRemote {
+		target = dstTarget
+	}
+	task := cmd.Flags().Lookup("task").Value.String()
+
+	// can ignore storing rootOpts here as cp just needs a client
+	config := getConfig(cmd, []string{}, "")
+	client, err := config.Client()
+	if err != nil {
+		fmt.Println(fmt.Errorf("could not get client: %v", err))
+		os.Exit(1)
+	}
+
+	var pick nomad.ExecCandidatePicker
+	if term.IsTerminal(os.Stdin.Fd()) {
+		pick = pickExecCandidate
+	}
+	alloc, task, found, err := nomad.FindExecTask(client, target, task, pick)
+	if err != nil {
+		fmt.Println(fmt.Errorf("could not find task: %v", err))
+		os.Exit(1)
+	}
+	if !found {
+		os.Exit(1)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	progress := &nomad.CopyProgress{}
+	stopProgress := printCopyProgress(args[0], progress)
+
+	var result nomad.CopyResult
+	switch {
+	case dstRemote:
+		result, err = nomad.CopyToTask(ctx, client, alloc, task, srcPath, dstPath, progress)
+	case useFS:
+		result, err = nomad.CopyFromAllocFS(client, alloc, srcPath, dstPath, progress)
+	default:
+		result, err = nomad.CopyFromTask(ctx, client, alloc, task, srcPath, dstPath, progress)
+	}
+	stopProgress()
+	if err != nil {
+		fmt.Println(fmt.Errorf("could not copy %s to %s: %v", args[0], args[1], err))
+		os.Exit(1)
+	}
+	fmt.Printf("%s to %s: %s\n", args[0], args[1], result.Summary())
+}
+
+// printCopyProgress shows the progress of copying src on stderr until the returned func is called, if stderr is a
+// terminal
+func printCopyProgress(src string, progress *nomad.CopyProgress) func() {
+	if !term.IsTerminal(os.Stderr.Fd()) {
+		return func() {}
+	}
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(200 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			// clears the rest of the line, as the progress can get shorter
+			fmt.Fprintf(os.Stderr, "\rCopying %s: %s\x1b[K", src, progress)
+			select {
+			case <-done:
+				fmt.Fprintln(os.Stderr)
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/robinovitch61/wander/internal/tui/components/app"
+	"github.com/robinovitch61/wander/internal/tui/nomad"
+	"github.com/spf13/cobra"
+	"os"
+	"os/signal"
+	"strconv"
+)
+
+var (
+	eventsCmd = &cobra.Command{
+		Use:   "events",
+		Short: "Print or replay events",
+		Long: `Print events of the configured event topics and namespace as they happen, one line of JSON per event output by
+the configured event jq query, or replay events recorded from an events page with R`,
+		Example: `
+  # print events as they happen
+  wander events --event-topics Job,Allocation
+
+  # print the first 10 deployment events from raft index 1000 with a different jq query
+  wander events --event-topics Deployment --since-index 1000 --count 10 --event-jq-query '.Events[] | {type: .Type, index: .Index}'
+
+  # replay a recording
+  wander events --replay events.ndjson
+
+  # replay a recording with a different jq query
+  wander events --replay events.ndjson --event-jq-query '.Events[] | {type: .Type, index: .Index}'
+`,
+		Run: eventsEntrypoint,
+	}
+)
+
+func eventsEntrypoint(cmd *cobra.Command, _ []string) {
+	replayPath := cmd.Flags().Lookup("replay").Value.String()
+	if replayPath == "" {
+		printEvents(cmd)
+		return
+	}
+	if _, err := os.Stat(replayPath); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	// the replay doesn't exec, so doesn't need rootOpts
+	config := getConfig(cmd, []string{}, "")
+	config.Event.ReplayPath = replayPath
+	program := tea.NewProgram(app.InitialModel(config), tea.WithAltScreen())
+
+	if _, err := program.Run(); err != nil {
+		fmt.Printf("Error on wander startup: %v", err)
+		os.Exit(1)
+	}
+}
+
+// printEvents prints events as lines of JSON as they happen, rather than showing them in an events page
+func printEvents(cmd *cobra.Command) {
+	sinceIndex, err := strconv.ParseUint(cmd.Flags().Lookup("since-index").Value.String(), 10, 64)
+	if err != nil {
+		fmt.Println(fmt.Errorf("since-index must be a raft index, not %s", cmd.Flags().Lookup("since-index").Value.String()))
+		os.Exit(1)
+	}
+	count, err := strconv.Atoi(cmd.Flags().Lookup("count").Value.String())
+	if err != nil || count < 0 {
+		fmt.Println(fmt.Errorf("count must be a number of events, not %s", cmd.Flags().Lookup("count").Value.String()))
+		os.Exit(1)
+	}
+
+	// can ignore storing rootOpts here as printing events just needs a client
+	config := getConfig(cmd, []string{}, "")
+	client, err := config.Client()
+	if err != nil {
+		fmt.Println(fmt.Errorf("could not get client: %v", err))
+		os.Exit(1)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	err = nomad.StreamEvents(
+		ctx,
+		*client,
+		config.Event.Topics,
+		config.Event.Namespace,
+		sinceIndex,
+		config.Event.JQQuery,
+		count,
+		os.Stdout,
+		func(err error) {
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "events stream closed: %v, reconnecting\n", err)
+			} else {
+				fmt.Fprintln(os.Stderr, "events stream closed, reconnecting")
+			}
+		},
+		func(err error) { fmt.Fprintln(os.Stderr, err) },
+	)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("could not stream events: %v", err))
+		os.Exit(1)
+	}
+}
@@ -2,6 +2,8 @@ package cmd
 
 import (
 	"fmt"
+	"github.com/moby/term"
+	"github.com/robinovitch61/wander/internal/tui/components/picker"
 	"github.com/robinovitch61/wander/internal/tui/nomad"
 	"github.com/spf13/cobra"
 	"os"
@@ -23,6 +25,9 @@ var (
   wander exec al echo "hi"
   wander exec 3d echo "hi"
 
+  # if a prefix matches multiple allocations or tasks, choose one interactively
+  wander exec a echo "hi"
+
   # specify flags for the exec command with --
   wander exec alright_stop --task redis -- echo -n "hi"
 `,
@@ -52,9 +57,24 @@ func execEntrypoint(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
-	_, err = nomad.AllocExec(client, allocID, task, execArgs)
+	// only ask to choose between matching tasks when someone is there to answer
+	var pick nomad.ExecCandidatePicker
+	if term.IsTerminal(os.Stdin.Fd()) {
+		pick = pickExecCandidate
+	}
+
+	_, err = nomad.AllocExec(client, allocID, task, execArgs, retrieveExecRecordDir(cmd), pick)
 	if err != nil {
 		fmt.Println(fmt.Errorf("could not exec into task: %v", err))
 		os.Exit(1)
 	}
 }
+
+func pickExecCandidate(candidates []nomad.ExecCandidate) (nomad.ExecCandidate, bool, error) {
+	header, rows := nomad.ExecCandidatesAsTable(candidates)
+	chosen, err := picker.Run("Multiple running tasks match, choose one to exec into", header, rows)
+	if err != nil || chosen < 0 {
+		return nomad.ExecCandidate{}, false, err
+	}
+	return candidates[chosen], true, nil
+}
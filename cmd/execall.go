@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/robinovitch61/wander/internal/tui/nomad"
+	"github.com/spf13/cobra"
+	"os"
+	"strings"
+)
+
+var (
+	execAllCmd = &cobra.Command{
+		Use:   "exec-all",
+		Short: "Run a command in every running allocation of a job",
+		Long:  `Run a non-interactive command in every running allocation of a nomad job or task group`,
+		Example: `
+  # assuming each allocation has a single task
+  wander exec-all alright_stop cat /etc/redis.conf
+
+  # specify the task group and task
+  wander exec-all alright_stop --group cache --task redis -- redis-server --version
+
+  # output json, e.g. to process with jq
+  wander exec-all alright_stop --output json -- cat /VERSION | jq -r '.[] | .stdout'
+`,
+		Run:               execAllEntrypoint,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error { return nil },
+	}
+)
+
+// execAllJSONResult is how a result is output with --output json
+type execAllJSONResult struct {
+	AllocID    string `json:"alloc_id"`
+	AllocName  string `json:"alloc_name"`
+	Node       string `json:"node"`
+	TaskGroup  string `json:"task_group"`
+	Task       string `json:"task"`
+	ExitCode   int    `json:"exit_code"`
+	Stdout     string `json:"stdout"`
+	Stderr     string `json:"stderr"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+func execAllEntrypoint(cmd *cobra.Command, args []string) {
+	if len(args) == 0 {
+		_ = cmd.Help()
+		os.Exit(0)
+	}
+
+	jobID := args[0]
+	command := args[1:]
+	if len(command) == 0 {
+		fmt.Println(fmt.Errorf("no command specified"))
+		os.Exit(1)
+	}
+	output := cmd.Flags().Lookup("output").Value.String()
+	if output != "text" && output != "json" {
+		fmt.Println(fmt.Errorf("output must be text or json, not %s", output))
+		os.Exit(1)
+	}
+	taskGroup := cmd.Flags().Lookup("group").Value.String()
+	task := cmd.Flags().Lookup("task").Value.String()
+	concurrency := retrieveExecAllConcurrency(cmd)
+
+	// can ignore storing rootOpts here as exec-all just needs a client
+	config := getConfig(cmd, []string{}, "")
+	client, err := config.Client()
+	if err != nil {
+		fmt.Println(fmt.Errorf("could not get client: %v", err))
+		os.Exit(1)
+	}
+
+	targets, err := nomad.FindExecAllTargets(*client, jobID, config.Namespace, taskGroup, task)
+	if err != nil {
+		fmt.Println(fmt.Errorf("could not find allocations: %v", err))
+		os.Exit(1)
+	}
+
+	var onResult func(nomad.ExecAllResult)
+	if output == "text" {
+		// print each result as it completes
+		onResult = func(r nomad.ExecAllResult) {
+			for _, line := range strings.Split(r.Output(), "\n") {
+				if line != "" {
+					fmt.Printf("%s %s\n", r.Prefix(), line)
+				}
+			}
+			if r.Err == nil {
+				fmt.Printf("%s exited %d\n", r.Prefix(), r.ExitCode)
+			}
+		}
+	}
+	results := nomad.ExecAll(context.Background(), client, targets, command, concurrency, onResult)
+
+	failed := false
+	var jsonResults []execAllJSONResult
+	for _, r := range results {
+		if r.Err != nil || r.ExitCode != 0 {
+			failed = true
+		}
+		jsonResult := execAllJSONResult{
+			AllocID:    r.AllocID,
+			AllocName:  r.AllocName,
+			Node:       r.NodeName,
+			TaskGroup:  r.TaskGroup,
+			Task:       r.Task,
+			ExitCode:   r.ExitCode,
+			Stdout:     r.Stdout,
+			Stderr:     r.Stderr,
+			DurationMS: r.Duration.Milliseconds(),
+		}
+		if r.Err != nil {
+			jsonResult.Error = r.Err.Error()
+		}
+		jsonResults = append(jsonResults, jsonResult)
+	}
+
+	if output == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(jsonResults); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/robinovitch61/wander/internal/tui/nomad"
+	"github.com/spf13/cobra"
+	"os"
+)
+
+var (
+	jobsCmd = &cobra.Command{
+		Use:   "jobs",
+		Short: "List jobs",
+		Long:  `List jobs with the columns of the jobs page, set with job-columns, as a table, json, csv or with a go template`,
+		Example: `
+  # list jobs as on the jobs page
+  wander jobs
+
+  # output json with some columns, including meta keys, e.g. to process with jq
+  wander jobs --job-columns Job,Status,owner --output json | jq -r '.[] | select(.Status == "dead") | .owner'
+
+  # output each job with a go template, referencing columns with spaces with index
+  wander jobs --template '{{.Job}} has been running for {{index . "Since Submit"}}'
+`,
+		Run: jobsEntrypoint,
+	}
+)
+
+func jobsEntrypoint(cmd *cobra.Command, _ []string) {
+	output := retrieveRowsOutput(cmd)
+
+	// can ignore storing rootOpts here as jobs just needs a client
+	config := getConfig(cmd, []string{}, "")
+	client, err := config.Client()
+	if err != nil {
+		fmt.Println(fmt.Errorf("could not get client: %v", err))
+		os.Exit(1)
+	}
+
+	rows, err := nomad.JobsRows(*client, config.JobColumns)
+	if err != nil {
+		fmt.Println(fmt.Errorf("could not list jobs: %v", err))
+		os.Exit(1)
+	}
+	if err := output.print(config.JobColumns, rows); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
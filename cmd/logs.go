@@ -0,0 +1,178 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/nomad/api"
+	"github.com/moby/term"
+	"github.com/robinovitch61/wander/internal/tui/nomad"
+	"github.com/spf13/cobra"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	logsCmd = &cobra.Command{
+		Use:   "logs",
+		Short: "Print the logs of a task",
+		Long: `Print the logs of a nomad task, using the same prefix matching of allocation and job IDs as exec, optionally
+following them or printing those of every running allocation of a job`,
+		Example: `
+  # print the logs of the only task of the only allocation of a job
+  wander logs alright_stop
+
+  # follow the stderr logs of a task from the last 10KB
+  wander logs 3dca0982 --task redis --stderr --follow --offset 10000
+
+  # follow the logs of every running allocation of a job, each line prefixed by its allocation
+  wander logs alright_stop --all-allocs --follow
+
+  # print the logs of the last hour, going by the timestamps in the logs
+  wander logs alright_stop --since 1h
+`,
+		Run: logsEntrypoint,
+	}
+)
+
+// logsTarget is a task whose logs are printed, with each line prefixed by prefix if it isn't empty
+type logsTarget struct {
+	alloc  *api.Allocation
+	task   string
+	prefix string
+}
+
+func logsEntrypoint(cmd *cobra.Command, args []string) {
+	if len(args) == 0 {
+		_ = cmd.Help()
+		os.Exit(0)
+	}
+	if len(args) != 1 {
+		fmt.Println(fmt.Errorf("specify a single allocation id or job"))
+		os.Exit(1)
+	}
+
+	task := cmd.Flags().Lookup("task").Value.String()
+	useStderr, _ := strconv.ParseBool(cmd.Flags().Lookup("stderr").Value.String())
+	follow, _ := strconv.ParseBool(cmd.Flags().Lookup("follow").Value.String())
+	allAllocs, _ := strconv.ParseBool(cmd.Flags().Lookup("all-allocs").Value.String())
+	offset, err := strconv.ParseInt(cmd.Flags().Lookup("offset").Value.String(), 10, 64)
+	if err != nil || offset < 0 {
+		fmt.Println(fmt.Errorf("offset must be a number of bytes, not %s", cmd.Flags().Lookup("offset").Value.String()))
+		os.Exit(1)
+	}
+	since, err := parseSince(cmd.Flags().Lookup("since").Value.String(), time.Now())
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	// can ignore storing rootOpts here as logs just needs a client
+	config := getConfig(cmd, []string{}, "")
+	client, err := config.Client()
+	if err != nil {
+		fmt.Println(fmt.Errorf("could not get client: %v", err))
+		os.Exit(1)
+	}
+
+	var targets []logsTarget
+	if allAllocs {
+		execTargets, err := nomad.FindExecAllTargets(*client, args[0], config.Namespace, "", task)
+		if err != nil {
+			fmt.Println(fmt.Errorf("could not find allocations: %v", err))
+			os.Exit(1)
+		}
+		for _, t := range execTargets {
+			alloc, _, err := client.Allocations().Info(t.AllocID, nil)
+			if err != nil {
+				fmt.Println(fmt.Errorf("could not get allocation %s: %v", t.AllocID, err))
+				os.Exit(1)
+			}
+			targets = append(targets, logsTarget{alloc: alloc, task: t.Task, prefix: t.Prefix()})
+		}
+	} else {
+		var pick nomad.ExecCandidatePicker
+		if term.IsTerminal(os.Stdin.Fd()) {
+			pick = pickExecCandidate
+		}
+		alloc, task, found, err := nomad.FindExecTask(client, args[0], task, pick)
+		if err != nil {
+			fmt.Println(fmt.Errorf("could not find task: %v", err))
+			os.Exit(1)
+		}
+		if !found {
+			os.Exit(1)
+		}
+		targets = append(targets, logsTarget{alloc: alloc, task: task})
+	}
+
+	logType := nomad.StdOut
+	if useStderr {
+		logType = nomad.StdErr
+	}
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	// lines of different allocations are printed whole
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	failed := false
+	for _, t := range targets {
+		t := t
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			opts := nomad.StreamLogsOptions{
+				LogType: logType,
+				Offset:  offset,
+				Follow:  follow,
+				Since:   since,
+				OnReconnect: func(attempt int, err error) {
+					reason := ""
+					if err != nil {
+						reason = fmt.Sprintf(": %v", err)
+					}
+					fmt.Fprintf(os.Stderr, "%slogs stream closed%s, reconnecting (attempt %d)\n", prefixWithSpace(t.prefix), reason, attempt)
+				},
+			}
+			err := nomad.StreamLogs(ctx, *client, t.alloc, t.task, opts, func(line string) {
+				mu.Lock()
+				defer mu.Unlock()
+				fmt.Printf("%s%s\n", prefixWithSpace(t.prefix), line)
+			})
+			if err != nil {
+				mu.Lock()
+				defer mu.Unlock()
+				fmt.Fprintln(os.Stderr, fmt.Errorf("%scould not get logs: %v", prefixWithSpace(t.prefix), err))
+				failed = true
+			}
+		}()
+	}
+	wg.Wait()
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func prefixWithSpace(prefix string) string {
+	if prefix == "" {
+		return ""
+	}
+	return prefix + " "
+}
+
+// parseSince parses since as a duration before now, e.g. 1h, or a time, e.g. 2023-01-02T15:04:05Z
+func parseSince(since string, now time.Time) (time.Time, error) {
+	if since == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(since); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, since); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("since must be a duration like 1h or a time like 2023-01-02T15:04:05Z, not %s", since)
+}
@@ -4,8 +4,10 @@ import (
 	"errors"
 	"fmt"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/moby/term"
 	"github.com/robinovitch61/wander/internal/dev"
 	"github.com/robinovitch61/wander/internal/tui/constants"
+	"github.com/robinovitch61/wander/internal/tui/nomad"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
@@ -39,6 +41,14 @@ var (
 			cfgFileEnvVar: "nomad_token",
 			description:   `Nomad token`,
 		},
+		"token-file": {
+			cfgFileEnvVar: "wander_token_file",
+			description:   `File to read the nomad token from if token isn't set, read again if the token expires`,
+		},
+		"token-command": {
+			cfgFileEnvVar: "wander_token_command",
+			description:   `Shell command that prints the nomad token if token and token-file aren't set, run again if the token expires`,
+		},
 		"region": {
 			cliShort:      "r",
 			cfgFileEnvVar: "nomad_region",
@@ -116,6 +126,45 @@ var (
 			isBool:        true,
 			defaultIfBool: true,
 		},
+		"log-structured": {
+			cfgFileEnvVar: "wander_log_structured",
+			description:   `Start logs in structured mode, parsing JSON or logfmt lines into columns`,
+			isBool:        true,
+			defaultIfBool: false,
+		},
+		"log-fields": {
+			cfgFileEnvVar: "wander_log_fields",
+			description:   `Fields to display as columns for structured logs. Job meta key wander_log_fields overrides`,
+			defaultString: "time,level,msg",
+		},
+		"log-preserve-colors": {
+			cfgFileEnvVar: "wander_log_preserve_colors",
+			description:   `Keep color and style escape sequences in logs rather than stripping them`,
+			isBool:        true,
+			defaultIfBool: false,
+		},
+		"log-group-regex": {
+			cfgFileEnvVar: "wander_log_group_regex",
+			description:   `Log lines matching this regex are grouped with the previous line, e.g. '^(\s+|Caused by:|Traceback)' for stack traces. Empty to not group`,
+		},
+		"log-record-max-bytes": {
+			cfgFileEnvVar: "wander_log_record_max_bytes",
+			description:   `Rotate files that logs are recorded to once they reach this many bytes. 0 to never rotate`,
+			isInt:         true,
+			defaultIfInt:  0,
+		},
+		"log-max-rows": {
+			cfgFileEnvVar: "wander_log_max_rows",
+			description:   `Maximum rows kept on the logs page, dropping the oldest ones. 0 for no maximum`,
+			isInt:         true,
+			defaultIfInt:  0,
+		},
+		"event-max-rows": {
+			cfgFileEnvVar: "wander_event_max_rows",
+			description:   `Maximum rows kept on events pages, dropping the oldest ones. 0 for no maximum`,
+			isInt:         true,
+			defaultIfInt:  0,
+		},
 		"copy-save-path": {
 			cliShort:      "s",
 			cfgFileEnvVar: "wander_copy_save_path",
@@ -143,9 +192,48 @@ var (
 			description:   `jq query for allocation-specific events. "." for entire JSON`,
 			defaultString: constants.DefaultAllocEventJQQuery,
 		},
+		"event-summary-jq-query": {
+			cfgFileEnvVar: "wander_event_summary_jq_query",
+			description:   `jq query run on each event whose output groups events in the events summary`,
+			defaultString: constants.DefaultEventSummaryJQQuery,
+		},
+		"exec-record-dir": {
+			cfgFileEnvVar: "wander_exec_record_dir",
+			description:   `Directory to record exec sessions to as asciinema files. Empty to not record`,
+			defaultString: "",
+		},
+		"exec-embedded": {
+			cfgFileEnvVar: "wander_exec_embedded",
+			description:   `Run exec sessions in a terminal inside wander, in tabs that stay open while browsing, rather than taking over the terminal`,
+			isBool:        true,
+			defaultIfBool: false,
+		},
+		"exec-scrollback": {
+			cfgFileEnvVar: "wander_exec_scrollback",
+			description:   `Lines of scrollback kept for each embedded exec session`,
+			isInt:         true,
+			defaultIfInt:  10000,
+		},
+		"exec-all-concurrency": {
+			cfgFileEnvVar: "wander_exec_all_concurrency",
+			description:   `Maximum allocations a command runs in at once when running it in every allocation of a job`,
+			isInt:         true,
+			defaultIfInt:  8,
+		},
 		"logo-color": {
 			cfgFileEnvVar: "wander_logo_color",
 		},
+		"event-presets": {
+			cfgFileEnvVar: constants.EventPresetsConfigKey,
+		},
+		"alert-rules": {
+			cfgFileEnvVar: "wander_alert_rules",
+		},
+		"alert-topics": {
+			cfgFileEnvVar: "wander_alert_topics",
+			description:   `Topics to check alert rules against, comma-separated. Alert rules are only set in the config file`,
+			defaultString: "Job,Allocation,Deployment,Node",
+		},
 		"compact-header": {
 			cfgFileEnvVar: "wander_compact_header",
 			description:   `Start with compact header`,
@@ -176,6 +264,12 @@ var (
 			isBool:        true,
 			defaultIfBool: true,
 		},
+		"read-only": {
+			cfgFileEnvVar: "wander_read_only",
+			description:   `Remove every action that changes the cluster or runs commands in it, like the admin menus, exec and copying files`,
+			isBool:        true,
+			defaultIfBool: false,
+		},
 	}
 
 	description = `wander is a terminal application for Nomad by HashiCorp. It is used to
@@ -189,6 +283,11 @@ in a productivity-focused UI.`
 		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 			return initConfig(cmd, rootNameToArg)
 		},
+		Example: `
+  # start on the logs of a task, see the README for the pages that can be linked to
+  wander wander://logs/alright_stop/redis?type=stderr
+`,
+		Args:    cobra.MaximumNArgs(1),
 		Run:     mainEntrypoint,
 		Version: getVersion(),
 	}
@@ -214,6 +313,8 @@ func init() {
 	for _, cliLong = range []string{
 		"addr",
 		"token",
+		"token-file",
+		"token-command",
 		"region",
 		"namespace",
 		"http-auth",
@@ -229,16 +330,30 @@ func init() {
 		"tasks-for-job-columns",
 		"log-offset",
 		"log-tail",
+		"log-structured",
+		"log-fields",
+		"log-preserve-colors",
+		"log-group-regex",
+		"log-record-max-bytes",
+		"log-max-rows",
+		"event-max-rows",
 		"copy-save-path",
 		"event-topics",
 		"event-namespace",
 		"event-jq-query",
 		"alloc-event-jq-query",
+		"event-summary-jq-query",
+		"alert-topics",
+		"exec-record-dir",
+		"exec-embedded",
+		"exec-scrollback",
+		"exec-all-concurrency",
 		"compact-header",
 		"start-all-tasks",
 		"compact-tables",
 		"start-filtering",
 		"filter-with-context",
+		"read-only",
 	} {
 		c := rootNameToArg[cliLong]
 		if c.isBool {
@@ -257,6 +372,7 @@ func init() {
 		"port",
 		"host-key-path",
 		"host-key-pem",
+		"authorized-keys",
 	} {
 		c := serveNameToArg[cliLong]
 		if c.isBool {
@@ -272,8 +388,58 @@ func init() {
 	// exec
 	execCmd.PersistentFlags().StringP("task", "", "", "Sets the task to exec command in")
 
+	// exec-all
+	execAllCmd.PersistentFlags().StringP("group", "", "", "Only run the command in allocations of this task group")
+	execAllCmd.PersistentFlags().StringP("task", "", "", "Sets the task to run the command in, required if allocations have multiple tasks")
+	execAllCmd.PersistentFlags().StringP("output", "", "text", "Output format, text (lines prefixed by allocation) or json")
+
+	// cp
+	cpCmd.PersistentFlags().StringP("task", "", "", "Sets the task to copy to or from")
+	cpCmd.PersistentFlags().BoolP("fs", "", false, "Download from the allocation directory with the file system api rather than exec, needing nothing in the task")
+
+	// logs
+	logsCmd.PersistentFlags().StringP("task", "", "", "Sets the task to print the logs of")
+	logsCmd.PersistentFlags().BoolP("stderr", "", false, "Print stderr rather than stdout logs")
+	logsCmd.PersistentFlags().BoolP("follow", "", false, "Keep printing new logs until the task finishes, reconnecting if the stream closes")
+	logsCmd.PersistentFlags().IntP("offset", "", 0, "Start this many bytes before the end of the logs, 0 for all logs")
+	logsCmd.PersistentFlags().StringP("since", "", "", "Only print lines from this long ago, e.g. 1h, or this time, e.g. 2023-01-02T15:04:05Z, going by the timestamps in the lines")
+	logsCmd.PersistentFlags().BoolP("all-allocs", "", false, "Print the logs of every running allocation of the job, each line prefixed by its allocation")
+
+	// jobs and tasks
+	for _, c := range []*cobra.Command{jobsCmd, tasksCmd} {
+		c.PersistentFlags().StringP("output", "", "table", "Output format, table, json or csv")
+		c.PersistentFlags().StringP("template", "", "", `Go template to output each row with instead, e.g. '{{.Job}} {{index . "Since Submit"}}'`)
+	}
+
+	// events
+	eventsCmd.PersistentFlags().StringP("replay", "", "", "Replay events from a file recorded with R on an events page")
+	eventsCmd.PersistentFlags().IntP("since-index", "", 0, "Print events from this raft index rather than from now")
+	eventsCmd.PersistentFlags().IntP("count", "", 0, "Exit after printing this many events, 0 to keep printing")
+
+	// config
+	configCmd.AddCommand(configValidateCmd)
+	configCmd.AddCommand(configShowCmd)
+
+	// completions
+	execCmd.ValidArgsFunction = completeTargets(true, true)
+	execAllCmd.ValidArgsFunction = completeTargets(false, true)
+	cpCmd.ValidArgsFunction = completeCopyArgs
+	logsCmd.ValidArgsFunction = completeTargets(true, false)
+	_ = execCmd.RegisterFlagCompletionFunc("task", completeTask(true))
+	_ = execAllCmd.RegisterFlagCompletionFunc("task", completeTask(true))
+	_ = execAllCmd.RegisterFlagCompletionFunc("group", completeTaskGroup)
+	_ = cpCmd.RegisterFlagCompletionFunc("task", completeTask(true))
+	_ = logsCmd.RegisterFlagCompletionFunc("task", completeTask(false))
+
 	rootCmd.AddCommand(serveCmd)
 	rootCmd.AddCommand(execCmd)
+	rootCmd.AddCommand(execAllCmd)
+	rootCmd.AddCommand(cpCmd)
+	rootCmd.AddCommand(logsCmd)
+	rootCmd.AddCommand(jobsCmd)
+	rootCmd.AddCommand(tasksCmd)
+	rootCmd.AddCommand(eventsCmd)
+	rootCmd.AddCommand(configCmd)
 }
 
 func initConfig(cmd *cobra.Command, nameToArg map[string]arg) error {
@@ -304,7 +470,8 @@ func initConfig(cmd *cobra.Command, nameToArg map[string]arg) error {
 	}
 
 	if err := viper.ReadInConfig(); err == nil {
-		fmt.Println("Using config file:", viper.ConfigFileUsed())
+		// stderr so it isn't mixed up with the output of subcommands like jobs
+		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
 	} else {
 		var configFileNotFoundError viper.ConfigFileNotFoundError
 		if !errors.As(err, &configFileNotFoundError) {
@@ -340,10 +507,14 @@ func bindFlags(cmd *cobra.Command, nameToArg map[string]arg) {
 	})
 }
 
-func mainEntrypoint(cmd *cobra.Command, _ []string) {
+func mainEntrypoint(cmd *cobra.Command, args []string) {
 	dev.Debug("~STARTING UP~")
 	rootOpts := getRootOpts(cmd)
-	initialModel, options := setup(cmd, rootOpts, "")
+	var deepLink *nomad.DeepLink
+	if len(args) == 1 {
+		deepLink = retrieveDeepLink(cmd, args[0])
+	}
+	initialModel, options := setup(cmd, rootOpts, "", term.IsTerminal(os.Stdout.Fd()), deepLink, false)
 	program := tea.NewProgram(initialModel, options...)
 
 	if _, err := program.Run(); err != nil {
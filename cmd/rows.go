@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/robinovitch61/wander/internal/tui/constants"
+	"github.com/robinovitch61/wander/internal/tui/formatter"
+	"github.com/spf13/cobra"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// rowsOutput is how the rows of a table like the jobs page's are output by subcommands like jobs
+type rowsOutput struct {
+	format string
+	// template is executed for each row, with its columns by name, if set
+	template *template.Template
+}
+
+func retrieveRowsOutput(cmd *cobra.Command) rowsOutput {
+	output := rowsOutput{format: cmd.Flags().Lookup("output").Value.String()}
+	if output.format != "table" && output.format != "json" && output.format != "csv" {
+		fmt.Println(fmt.Errorf("output must be table, json or csv, not %s", output.format))
+		os.Exit(1)
+	}
+	if t := cmd.Flags().Lookup("template").Value.String(); t != "" {
+		parsed, err := template.New("row").Option("missingkey=error").Parse(t)
+		if err != nil {
+			fmt.Println(fmt.Errorf("error parsing template: %v", err))
+			os.Exit(1)
+		}
+		output.template = parsed
+	}
+	return output
+}
+
+func (o rowsOutput) print(columns []string, rows [][]string) error {
+	if o.template != nil {
+		for _, row := range rows {
+			if err := o.template.Execute(os.Stdout, rowByColumn(columns, row)); err != nil {
+				return err
+			}
+			fmt.Println()
+		}
+		return nil
+	}
+
+	switch o.format {
+	case "json":
+		objects := []map[string]string{}
+		for _, row := range rows {
+			objects = append(objects, rowByColumn(columns, row))
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(objects)
+	case "csv":
+		writer := csv.NewWriter(os.Stdout)
+		if err := writer.Write(columns); err != nil {
+			return err
+		}
+		return writer.WriteAll(rows)
+	default:
+		table := formatter.GetRenderedTableAsString(columns, rows)
+		for _, row := range append(table.HeaderRows, table.ContentRows...) {
+			fmt.Println(strings.TrimRight(strings.ReplaceAll(row, constants.TableSeparator, constants.TablePadding), " "))
+		}
+		return nil
+	}
+}
+
+func rowByColumn(columns, row []string) map[string]string {
+	byColumn := make(map[string]string)
+	for i, column := range columns {
+		if i < len(row) {
+			byColumn[column] = row[i]
+		}
+	}
+	return byColumn
+}
@@ -7,7 +7,9 @@ import (
 	"github.com/charmbracelet/ssh"
 	"github.com/charmbracelet/wish"
 	bm "github.com/charmbracelet/wish/bubbletea"
+	"github.com/hashicorp/nomad/api"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 	"log"
 	"os"
 	"os/signal"
@@ -42,6 +44,13 @@ var (
 			cfgFileEnvVar: "wander_host_key_pem",
 			description:   `Host key PEM block for wander ssh server`,
 		},
+		"authorized-keys": {
+			cfgFileEnvVar: "wander_authorized_keys",
+			description:   `Only accept sessions with public keys in this authorized_keys file, which needs serve users set`,
+		},
+		"serve-users": {
+			cfgFileEnvVar: "wander_serve_users",
+		},
 	}
 
 	serveDescription = `Starts an ssh server hosting wander.`
@@ -68,6 +77,22 @@ func serveEntrypoint(cmd *cobra.Command, args []string) {
 	hostKeyPath := cmd.Flags().Lookup("host-key-path").Value.String()
 	hostKeyPEM := cmd.Flags().Lookup("host-key-pem").Value.String()
 
+	auth := retrieveServeAuth(cmd)
+	var serverClient *api.Client
+	if auth != nil {
+		for _, u := range auth.users {
+			if u.Role != "" {
+				// tokens for roles are created with the server's token
+				serverClient, err = getConfig(cmd, []string{}, "").Client()
+				if err != nil {
+					fmt.Println(fmt.Errorf("could not get client: %v", err))
+					os.Exit(1)
+				}
+				break
+			}
+		}
+	}
+
 	options := []ssh.Option{wish.WithAddress(fmt.Sprintf("%s:%d", host, port))}
 	if hostKeyPath != "" {
 		options = append(options, wish.WithHostKeyPath(hostKeyPath))
@@ -75,8 +100,11 @@ func serveEntrypoint(cmd *cobra.Command, args []string) {
 	if hostKeyPEM != "" {
 		options = append(options, wish.WithHostKeyPEM([]byte(hostKeyPEM)))
 	}
+	if auth != nil {
+		options = append(options, wish.WithPublicKeyAuth(auth.handlePublicKey))
+	}
 	middleware := wish.WithMiddleware(
-		bm.Middleware(generateTeaHandler(cmd)),
+		bm.Middleware(generateTeaHandler(cmd, auth, serverClient)),
 		customLoggingMiddleware(),
 	)
 	options = append(options, middleware)
@@ -104,14 +132,31 @@ func serveEntrypoint(cmd *cobra.Command, args []string) {
 	}
 }
 
-func generateTeaHandler(cmd *cobra.Command) func(ssh.Session) (tea.Model, []tea.ProgramOption) {
+func generateTeaHandler(cmd *cobra.Command, auth *serveAuth, serverClient *api.Client) func(ssh.Session) (tea.Model, []tea.ProgramOption) {
 	changedOpts := getRootOpts(cmd.Parent())
 	return func(s ssh.Session) (tea.Model, []tea.ProgramOption) {
-		// optionally override token - MUST run with `-t` flag to force pty, e.g. ssh -p 20000 localhost -t <token>
 		var overrideToken string
-		if sshCommands := s.Command(); len(sshCommands) == 1 {
+		// serve only binds its own options from the config file and env vars to flags, so read-only is checked here too
+		readOnly := viper.GetBool(rootNameToArg["read-only"].cfgFileEnvVar)
+		if auth != nil {
+			// sessions get their serve user's permissions, not ones passed in
+			u, found := auth.userFor(s.User(), s.PublicKey())
+			if !found {
+				wish.Fatalln(s, "no serve user for this session")
+				return nil, nil
+			}
+			token, err := sessionToken(s, u, serverClient)
+			if err != nil {
+				log.Printf("%s %s: %v\n", s.User(), s.RemoteAddr().String(), err)
+				wish.Fatalln(s, err)
+				return nil, nil
+			}
+			overrideToken, readOnly = token, readOnly || u.ReadOnly
+		} else if sshCommands := s.Command(); len(sshCommands) == 1 {
+			// optionally override token - MUST run with `-t` flag to force pty, e.g. ssh -p 20000 localhost -t <token>
 			overrideToken = strings.TrimSpace(sshCommands[0])
 		}
-		return setup(cmd, changedOpts, overrideToken)
+		_, _, isPty := s.Pty()
+		return setup(cmd, changedOpts, overrideToken, isPty, nil, readOnly)
 	}
 }
@@ -0,0 +1,182 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"github.com/charmbracelet/ssh"
+	"github.com/hashicorp/nomad/api"
+	"github.com/robinovitch61/wander/internal/tui/nomad"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	gossh "golang.org/x/crypto/ssh"
+	"log"
+	"os"
+	"strings"
+)
+
+// serveUser maps ssh sessions, by public key fingerprint and optionally user, to the nomad token or ACL role they get.
+// The user alone can't identify a session, as clients choose it.
+type serveUser struct {
+	User        string `mapstructure:"user"`
+	Fingerprint string `mapstructure:"fingerprint"`
+	Token       string `mapstructure:"token"`
+	Role        string `mapstructure:"role"`
+	ReadOnly    bool   `mapstructure:"read_only"`
+}
+
+func (u serveUser) matches(user, fingerprint string) bool {
+	return (u.User == "" || u.User == user) && (u.Fingerprint == "" || u.Fingerprint == fingerprint)
+}
+
+func (u serveUser) String() string {
+	var parts []string
+	if u.User != "" {
+		parts = append(parts, "user "+u.User)
+	}
+	if u.Fingerprint != "" {
+		parts = append(parts, "key "+u.Fingerprint)
+	}
+	return strings.Join(parts, " with ")
+}
+
+// serveAuth authenticates ssh sessions by public key, against authorizedKeys if set, and finds the serve user each
+// session is for if there are any
+type serveAuth struct {
+	authorizedKeysPath string
+	authorizedKeys     []ssh.PublicKey
+	users              []serveUser
+}
+
+// retrieveServeAuth is nil if neither authorized keys nor serve users are set, in which case any session is accepted
+// with the server's token. Otherwise, every session needs a serve user.
+func retrieveServeAuth(cmd *cobra.Command) *serveAuth {
+	users, err := parseServeUsers()
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	path := cmd.Flags().Lookup("authorized-keys").Value.String()
+	if err := validateServeAuth(path, users); err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	if path == "" && len(users) == 0 {
+		return nil
+	}
+
+	auth := &serveAuth{authorizedKeysPath: path, users: users}
+	if path != "" {
+		auth.authorizedKeys, err = readAuthorizedKeys(path)
+		if err != nil {
+			fmt.Println(fmt.Errorf("could not read authorized keys: %v", err))
+			os.Exit(1)
+		}
+	}
+	return auth
+}
+
+func parseServeUsers() ([]serveUser, error) {
+	var users []serveUser
+	if err := viper.UnmarshalKey(serveNameToArg["serve-users"].cfgFileEnvVar, &users); err != nil {
+		return nil, fmt.Errorf("serve users cannot be parsed: %v", err)
+	}
+	for i, u := range users {
+		if u.Fingerprint == "" {
+			// any key, even one in authorized keys, can connect as any user
+			return nil, fmt.Errorf("serve user %d needs a fingerprint", i+1)
+		}
+		if (u.Token == "") == (u.Role == "") {
+			return nil, fmt.Errorf("serve user %s needs exactly one of token and role", u)
+		}
+		if err := validateToken(u.Token); err != nil {
+			return nil, fmt.Errorf("serve user %s: %v", u, err)
+		}
+	}
+	return users, nil
+}
+
+// validateServeAuth rejects authorized keys without serve users, whose sessions would all get the server's token
+func validateServeAuth(authorizedKeysPath string, users []serveUser) error {
+	if authorizedKeysPath != "" && len(users) == 0 {
+		return fmt.Errorf("authorized keys need serve users, mapping each key to a nomad token or ACL role")
+	}
+	return nil
+}
+
+// readAuthorizedKeys reads keys in the format of ssh's authorized_keys files, ignoring their options
+func readAuthorizedKeys(path string) ([]ssh.PublicKey, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var keys []ssh.PublicKey
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, _, _, _, err := gossh.ParseAuthorizedKey([]byte(line))
+		if err != nil {
+			return nil, fmt.Errorf("line %d of %s: %v", lineNumber, path, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, scanner.Err()
+}
+
+func (a *serveAuth) handlePublicKey(ctx ssh.Context, key ssh.PublicKey) bool {
+	fingerprint := gossh.FingerprintSHA256(key)
+	if a.authorizedKeysPath != "" && !a.isAuthorized(key) {
+		log.Printf("%s rejected %s: key %s not in authorized keys\n", ctx.User(), ctx.RemoteAddr().String(), fingerprint)
+		return false
+	}
+	if _, found := a.userFor(ctx.User(), key); !found {
+		log.Printf("%s rejected %s: no serve user for key %s\n", ctx.User(), ctx.RemoteAddr().String(), fingerprint)
+		return false
+	}
+	return true
+}
+
+func (a *serveAuth) isAuthorized(key ssh.PublicKey) bool {
+	for _, k := range a.authorizedKeys {
+		if ssh.KeysEqual(k, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// userFor is the first serve user that matches the user and key
+func (a *serveAuth) userFor(user string, key ssh.PublicKey) (serveUser, bool) {
+	if key == nil {
+		return serveUser{}, false
+	}
+	fingerprint := gossh.FingerprintSHA256(key)
+	for _, u := range a.users {
+		if u.matches(user, fingerprint) {
+			return u, true
+		}
+	}
+	return serveUser{}, false
+}
+
+// sessionToken is the token of the serve user of the session, creating one for its role with the server's client if
+// it has one, deleted when the session ends
+func sessionToken(s ssh.Session, u serveUser, serverClient *api.Client) (string, error) {
+	if u.Token != "" {
+		return u.Token, nil
+	}
+	token, err := nomad.CreateRoleToken(*serverClient, u.Role, fmt.Sprintf("wander serve %s", s.User()))
+	if err != nil {
+		return "", fmt.Errorf("could not create token for role %s: %v", u.Role, err)
+	}
+	go func() {
+		<-s.Context().Done()
+		if err := nomad.DeleteToken(*serverClient, token.AccessorID); err != nil {
+			log.Printf("could not delete token %s for %s: %v\n", token.AccessorID, s.User(), err)
+		}
+	}()
+	return token.SecretID, nil
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/robinovitch61/wander/internal/tui/nomad"
+	"github.com/spf13/cobra"
+	"os"
+)
+
+var (
+	tasksCmd = &cobra.Command{
+		Use:   "tasks",
+		Short: "List tasks",
+		Long:  `List the tasks of all allocations with the columns of the all tasks page, set with all-tasks-columns, as a table, json, csv or with a go template`,
+		Example: `
+  # list tasks as on the all tasks page
+  wander tasks
+
+  # output csv
+  wander tasks --output csv > tasks.csv
+
+  # output the tasks that aren't running with a go template
+  wander tasks --template '{{if ne .State "running"}}{{.Job}} {{index . "Task Name"}} is {{.State}}{{end}}' | grep .
+`,
+		Run: tasksEntrypoint,
+	}
+)
+
+func tasksEntrypoint(cmd *cobra.Command, _ []string) {
+	output := retrieveRowsOutput(cmd)
+
+	// can ignore storing rootOpts here as tasks just needs a client
+	config := getConfig(cmd, []string{}, "")
+	client, err := config.Client()
+	if err != nil {
+		fmt.Println(fmt.Errorf("could not get client: %v", err))
+		os.Exit(1)
+	}
+
+	rows, err := nomad.AllTasksRows(*client, config.AllTaskColumns)
+	if err != nil {
+		fmt.Println(fmt.Errorf("could not list tasks: %v", err))
+		os.Exit(1)
+	}
+	if err := output.print(config.AllTaskColumns, rows); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
@@ -7,8 +7,8 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/ssh"
 	"github.com/charmbracelet/wish"
-	"github.com/hashicorp/nomad/api"
 	"github.com/itchyny/gojq"
+	"github.com/moby/term"
 	"github.com/robinovitch61/wander/internal/tui/components/app"
 	"github.com/robinovitch61/wander/internal/tui/nomad"
 	"github.com/spf13/cobra"
@@ -16,6 +16,8 @@ import (
 	"github.com/spf13/viper"
 	"log"
 	"os"
+	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -64,6 +66,20 @@ func retrieveToken(cmd *cobra.Command) string {
 	return val
 }
 
+// retrieveTokenSource is nil if neither token-file nor token-command are set
+func retrieveTokenSource(cmd *cobra.Command) *nomad.TokenSource {
+	file := cmd.Flags().Lookup("token-file").Value.String()
+	command := cmd.Flags().Lookup("token-command").Value.String()
+	if file == "" && command == "" {
+		return nil
+	}
+	if file != "" {
+		// the file takes precedence, so the command isn't run
+		command = ""
+	}
+	return &nomad.TokenSource{File: file, Command: command}
+}
+
 func retrieveRegion(cmd *cobra.Command) string {
 	return cmd.Flags().Lookup("region").Value.String()
 }
@@ -107,49 +123,12 @@ func retrieveCopySavePath(cmd *cobra.Command) bool {
 }
 
 func retrieveEventTopics(cmd *cobra.Command) nomad.Topics {
-	matchTopic := func(t string) (api.Topic, error) {
-		switch t {
-		case "Deployment":
-			return api.TopicDeployment, nil
-		case "Evaluation":
-			return api.TopicEvaluation, nil
-		case "Allocation":
-			return api.TopicAllocation, nil
-		case "Job":
-			return api.TopicJob, nil
-		case "Node":
-			return api.TopicNode, nil
-		case "Service":
-			return api.TopicService, nil
-		case "*":
-			return api.TopicAll, nil
-		}
-		return "", fmt.Errorf("%s cannot be parsed into topic", t)
-	}
-
 	topicString := cmd.Flags().Lookup("event-topics").Value.String()
-	topics := make(nomad.Topics)
-	for _, t := range strings.Split(topicString, ",") {
-		split := strings.Split(strings.TrimSpace(t), ":")
-		suffix := "*"
-		if len(split) == 2 {
-			suffix = strings.TrimSpace(split[1])
-		}
-
-		topic, err := matchTopic(strings.TrimSpace(split[0]))
-		if err != nil {
-			fmt.Println(err.Error())
-			os.Exit(1)
-		}
-
-		suffixes, exists := topics[topic]
-		if exists {
-			topics[topic] = append(suffixes, suffix)
-		} else {
-			topics[topic] = []string{suffix}
-		}
+	topics, err := nomad.ParseTopics(topicString)
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
 	}
-
 	return topics
 }
 
@@ -157,34 +136,131 @@ func retrieveEventNamespace(cmd *cobra.Command) string {
 	return cmd.Flags().Lookup("event-namespace").Value.String()
 }
 
-func retrieveEventJQQuery(cmd *cobra.Command) *gojq.Code {
+func retrieveEventJQQuery(cmd *cobra.Command) (string, *gojq.Code) {
 	query := cmd.Flags().Lookup("event-jq-query").Value.String()
-	parsed, err := gojq.Parse(query)
+	code, err := nomad.CompileJQQuery(query)
+	if err != nil {
+		fmt.Printf("Error in event jq query: %s\n", err.Error())
+		os.Exit(1)
+	}
+	return query, code
+}
+
+func retrieveAllocEventJQQuery(cmd *cobra.Command) (string, *gojq.Code) {
+	query := cmd.Flags().Lookup("alloc-event-jq-query").Value.String()
+	code, err := nomad.CompileJQQuery(query)
 	if err != nil {
-		fmt.Printf("Error parsing event jq query: %s\n", err.Error())
+		fmt.Printf("Error in alloc event jq query: %s\n", err.Error())
 		os.Exit(1)
 	}
-	code, err := gojq.Compile(parsed)
+	return query, code
+}
+
+func retrieveEventSummaryJQQuery(cmd *cobra.Command) *gojq.Code {
+	query := cmd.Flags().Lookup("event-summary-jq-query").Value.String()
+	code, err := nomad.CompileJQQuery(query)
 	if err != nil {
-		fmt.Printf("Error compiling event jq query: %s\n", err.Error())
+		fmt.Printf("Error in event summary jq query: %s\n", err.Error())
 		os.Exit(1)
 	}
 	return code
 }
 
-func retrieveAllocEventJQQuery(cmd *cobra.Command) *gojq.Code {
-	query := cmd.Flags().Lookup("alloc-event-jq-query").Value.String()
-	parsed, err := gojq.Parse(query)
+func retrieveExecRecordDir(cmd *cobra.Command) string {
+	return cmd.Flags().Lookup("exec-record-dir").Value.String()
+}
+
+func retrieveExecEmbedded(cmd *cobra.Command) bool {
+	v := cmd.Flags().Lookup("exec-embedded").Value.String()
+	return trueIfTrue(v)
+}
+
+func retrieveExecScrollback(cmd *cobra.Command) int {
+	scrollbackString := cmd.Flags().Lookup("exec-scrollback").Value.String()
+	scrollback, err := strconv.Atoi(scrollbackString)
+	if err != nil || scrollback < 0 {
+		fmt.Println(fmt.Errorf("exec scrollback %s must be a non-negative integer", scrollbackString))
+		os.Exit(1)
+	}
+	return scrollback
+}
+
+func retrieveExecAllConcurrency(cmd *cobra.Command) int {
+	concurrencyString := cmd.Flags().Lookup("exec-all-concurrency").Value.String()
+	concurrency, err := strconv.Atoi(concurrencyString)
+	if err != nil || concurrency < 1 {
+		fmt.Println(fmt.Errorf("exec all concurrency %s must be a positive integer", concurrencyString))
+		os.Exit(1)
+	}
+	return concurrency
+}
+
+func retrieveAlertTopics(cmd *cobra.Command) nomad.Topics {
+	topicString := cmd.Flags().Lookup("alert-topics").Value.String()
+	topics, err := nomad.ParseTopics(topicString)
 	if err != nil {
-		fmt.Printf("Error parsing alloc event jq query: %s\n", err.Error())
+		fmt.Println(err.Error())
 		os.Exit(1)
 	}
-	code, err := gojq.Compile(parsed)
+	return topics
+}
+
+func retrieveAlertRules() []nomad.AlertRule {
+	rules, err := parseAlertRules()
 	if err != nil {
-		fmt.Printf("Error compiling alloc event jq query: %s\n", err.Error())
+		fmt.Println(err.Error())
 		os.Exit(1)
 	}
-	return code
+	return rules
+}
+
+func parseAlertRules() ([]nomad.AlertRule, error) {
+	var ruleConfigs []struct {
+		Name   string   `mapstructure:"name"`
+		Query  string   `mapstructure:"query"`
+		Notify []string `mapstructure:"notify"`
+	}
+	if err := viper.UnmarshalKey(rootNameToArg["alert-rules"].cfgFileEnvVar, &ruleConfigs); err != nil {
+		return nil, fmt.Errorf("alert rules cannot be parsed: %v", err)
+	}
+	var rules []nomad.AlertRule
+	for _, c := range ruleConfigs {
+		rule, err := nomad.NewAlertRule(c.Name, c.Query, c.Notify)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func retrieveEventPresets() []nomad.EventsPreset {
+	presets, err := parseEventPresets()
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	return presets
+}
+
+func parseEventPresets() ([]nomad.EventsPreset, error) {
+	var presets []nomad.EventsPreset
+	if err := viper.UnmarshalKey(rootNameToArg["event-presets"].cfgFileEnvVar, &presets); err != nil {
+		return nil, fmt.Errorf("event presets cannot be parsed: %v", err)
+	}
+	return presets, nil
+}
+
+// retrieveConfigFilePath returns the config file in use, or the default one if there is none, e.g. to save presets to
+func retrieveConfigFilePath() string {
+	if cfgFile := viper.ConfigFileUsed(); cfgFile != "" {
+		return cfgFile
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".wander.yaml")
 }
 
 func retrieveUpdateSeconds(cmd *cobra.Command) int {
@@ -242,6 +318,71 @@ func retrieveLogTail(cmd *cobra.Command) bool {
 	return trueIfTrue(v)
 }
 
+func retrieveLogStructured(cmd *cobra.Command) bool {
+	v := cmd.Flags().Lookup("log-structured").Value.String()
+	return trueIfTrue(v)
+}
+
+func retrieveLogFields(cmd *cobra.Command) []string {
+	fieldsString := cmd.Flags().Lookup("log-fields").Value.String()
+	split := strings.Split(fieldsString, ",")
+	var trimmed []string
+	for _, s := range split {
+		if t := strings.TrimSpace(s); t != "" {
+			trimmed = append(trimmed, t)
+		}
+	}
+	return trimmed
+}
+
+func retrieveLogPreserveColors(cmd *cobra.Command) bool {
+	v := cmd.Flags().Lookup("log-preserve-colors").Value.String()
+	return trueIfTrue(v)
+}
+
+func retrieveLogGroupRegex(cmd *cobra.Command) *regexp.Regexp {
+	regexString := cmd.Flags().Lookup("log-group-regex").Value.String()
+	if regexString == "" {
+		return nil
+	}
+	re, err := regexp.Compile(regexString)
+	if err != nil {
+		fmt.Println(fmt.Errorf("log group regex %s is invalid: %v", regexString, err))
+		os.Exit(1)
+	}
+	return re
+}
+
+func retrieveLogRecordMaxBytes(cmd *cobra.Command) int {
+	maxBytesString := cmd.Flags().Lookup("log-record-max-bytes").Value.String()
+	maxBytes, err := strconv.Atoi(maxBytesString)
+	if err != nil {
+		fmt.Println(fmt.Errorf("log record max bytes %s cannot be converted to an integer", maxBytesString))
+		os.Exit(1)
+	}
+	return maxBytes
+}
+
+func retrieveLogMaxRows(cmd *cobra.Command) int {
+	maxRowsString := cmd.Flags().Lookup("log-max-rows").Value.String()
+	maxRows, err := strconv.Atoi(maxRowsString)
+	if err != nil {
+		fmt.Println(fmt.Errorf("log max rows %s cannot be converted to an integer", maxRowsString))
+		os.Exit(1)
+	}
+	return maxRows
+}
+
+func retrieveEventMaxRows(cmd *cobra.Command) int {
+	maxRowsString := cmd.Flags().Lookup("event-max-rows").Value.String()
+	maxRows, err := strconv.Atoi(maxRowsString)
+	if err != nil {
+		fmt.Println(fmt.Errorf("event max rows %s cannot be converted to an integer", maxRowsString))
+		os.Exit(1)
+	}
+	return maxRows
+}
+
 func retrieveStartCompact(cmd *cobra.Command) bool {
 	v := cmd.Flags().Lookup("compact-header").Value.String()
 	return trueIfTrue(v)
@@ -267,6 +408,11 @@ func retrieveFilterWithContext(cmd *cobra.Command) bool {
 	return trueIfTrue(v)
 }
 
+func retrieveReadOnly(cmd *cobra.Command) bool {
+	v := cmd.Flags().Lookup("read-only").Value.String()
+	return trueIfTrue(v)
+}
+
 // customLoggingMiddleware provides basic connection logging. Connects are logged with the
 // remote address, invoked command, TERM setting, window dimensions and if the
 // auth was public key based. Disconnect will log the remote address and
@@ -293,6 +439,18 @@ func getConfig(cmd *cobra.Command, rootOpts []string, overrideToken string) app.
 		}
 		nomadToken = overrideToken
 	}
+	var tokenSource *nomad.TokenSource
+	if nomadToken == "" {
+		tokenSource = retrieveTokenSource(cmd)
+	}
+	if tokenSource != nil {
+		token, err := tokenSource.Token()
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		nomadToken = token
+	}
 	region := retrieveRegion(cmd)
 	namespace := retrieveNamespace(cmd)
 	httpAuth := retrieveHTTPAuth(cmd)
@@ -304,11 +462,26 @@ func getConfig(cmd *cobra.Command, rootOpts []string, overrideToken string) app.
 	skipVerify := retrieveSkipVerify(cmd)
 	logOffset := retrieveLogOffset(cmd)
 	logTail := retrieveLogTail(cmd)
+	logStructured := retrieveLogStructured(cmd)
+	logFields := retrieveLogFields(cmd)
+	logPreserveColors := retrieveLogPreserveColors(cmd)
+	logGroupRegex := retrieveLogGroupRegex(cmd)
+	logRecordMaxBytes := retrieveLogRecordMaxBytes(cmd)
+	logMaxRows := retrieveLogMaxRows(cmd)
 	copySavePath := retrieveCopySavePath(cmd)
 	eventTopics := retrieveEventTopics(cmd)
 	eventNamespace := retrieveEventNamespace(cmd)
-	eventJQQuery := retrieveEventJQQuery(cmd)
-	allocEventJQQuery := retrieveAllocEventJQQuery(cmd)
+	eventMaxRows := retrieveEventMaxRows(cmd)
+	eventJQQueryString, eventJQQuery := retrieveEventJQQuery(cmd)
+	allocEventJQQueryString, allocEventJQQuery := retrieveAllocEventJQQuery(cmd)
+	eventSummaryJQQuery := retrieveEventSummaryJQQuery(cmd)
+	eventPresets := retrieveEventPresets()
+	alertTopics := retrieveAlertTopics(cmd)
+	alertRules := retrieveAlertRules()
+	execEmbedded := retrieveExecEmbedded(cmd)
+	execScrollback := retrieveExecScrollback(cmd)
+	execRecordDir := retrieveExecRecordDir(cmd)
+	execAllConcurrency := retrieveExecAllConcurrency(cmd)
 	updateSeconds := retrieveUpdateSeconds(cmd)
 	jobColumns := retrieveJobColumns(cmd)
 	allTaskColumns := retrieveAllTaskColumns(cmd)
@@ -319,15 +492,17 @@ func getConfig(cmd *cobra.Command, rootOpts []string, overrideToken string) app.
 	compactTables := retrieveCompactTables(cmd)
 	startFiltering := retrieveStartFiltering(cmd)
 	filterWithContext := retrieveFilterWithContext(cmd)
+	readOnly := retrieveReadOnly(cmd)
 
 	return app.Config{
-		RootOpts:  rootOpts,
-		Version:   getVersion(),
-		URL:       nomadAddr,
-		Token:     nomadToken,
-		Region:    region,
-		Namespace: namespace,
-		HTTPAuth:  httpAuth,
+		RootOpts:    rootOpts,
+		Version:     getVersion(),
+		URL:         nomadAddr,
+		Token:       nomadToken,
+		TokenSource: tokenSource,
+		Region:      region,
+		Namespace:   namespace,
+		HTTPAuth:    httpAuth,
 		TLS: app.TLSConfig{
 			CACert:     cacert,
 			CAPath:     capath,
@@ -337,16 +512,40 @@ func getConfig(cmd *cobra.Command, rootOpts []string, overrideToken string) app.
 			SkipVerify: skipVerify,
 		},
 		Log: app.LogConfig{
-			Offset: logOffset,
-			Tail:   logTail,
+			Offset:         logOffset,
+			Tail:           logTail,
+			Structured:     logStructured,
+			Fields:         logFields,
+			PreserveColors: logPreserveColors,
+			GroupRegex:     logGroupRegex,
+			RecordMaxBytes: logRecordMaxBytes,
+			MaxRows:        logMaxRows,
 		},
 		CopySavePath: copySavePath,
 		Event: app.EventConfig{
-			Topics:       eventTopics,
-			Namespace:    eventNamespace,
-			JQQuery:      eventJQQuery,
-			AllocJQQuery: allocEventJQQuery,
+			Topics:             eventTopics,
+			JobTopics:          eventTopics,
+			AllocTopics:        eventTopics,
+			Namespace:          eventNamespace,
+			JQQuery:            eventJQQuery,
+			AllocJQQuery:       allocEventJQQuery,
+			JQQueryString:      eventJQQueryString,
+			AllocJQQueryString: allocEventJQQueryString,
+			SummaryJQQuery:     eventSummaryJQQuery,
+			Presets:            eventPresets,
+			MaxRows:            eventMaxRows,
+		},
+		Alert: app.AlertConfig{
+			Topics: alertTopics,
+			Rules:  alertRules,
 		},
+		Exec: app.ExecConfig{
+			Embedded:       execEmbedded,
+			Scrollback:     execScrollback,
+			RecordDir:      execRecordDir,
+			AllConcurrency: execAllConcurrency,
+		},
+		ConfigFilePath:    retrieveConfigFilePath(),
 		UpdateSeconds:     time.Second * time.Duration(updateSeconds),
 		JobColumns:        jobColumns,
 		AllTaskColumns:    allTaskColumns,
@@ -357,6 +556,7 @@ func getConfig(cmd *cobra.Command, rootOpts []string, overrideToken string) app.
 		CompactTables:     compactTables,
 		StartFiltering:    startFiltering,
 		FilterWithContext: filterWithContext,
+		ReadOnly:          readOnly,
 	}
 }
 
@@ -377,7 +577,40 @@ func getRootOpts(cmd *cobra.Command) []string {
 	return opts
 }
 
-func setup(cmd *cobra.Command, rootOpts []string, overrideToken string) (app.Model, []tea.ProgramOption) {
-	initialModel := app.InitialModel(getConfig(cmd, rootOpts, overrideToken))
+// retrieveDeepLink parses and resolves a link to the page to start on, see nomad.ParseDeepLink
+func retrieveDeepLink(cmd *cobra.Command, link string) *nomad.DeepLink {
+	deepLink, err := nomad.ParseDeepLink(link)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	client, err := getConfig(cmd, []string{}, "").Client()
+	if err != nil {
+		fmt.Println(fmt.Errorf("could not get client: %v", err))
+		os.Exit(1)
+	}
+	var pick nomad.ExecCandidatePicker
+	if term.IsTerminal(os.Stdin.Fd()) {
+		pick = pickExecCandidate
+	}
+	deepLink, found, err := nomad.ResolveDeepLink(client, deepLink, pick)
+	if err != nil {
+		fmt.Println(fmt.Errorf("could not find what %s links to: %v", link, err))
+		os.Exit(1)
+	}
+	if !found {
+		os.Exit(1)
+	}
+	return &deepLink
+}
+
+// setup creates the model for a program rendering to a terminal if outputIsTerminal, starting on deepLink if it isn't
+// nil. setup is read-only if readOnly is true, e.g. for a serve user, or if the read-only option is set
+func setup(cmd *cobra.Command, rootOpts []string, overrideToken string, outputIsTerminal bool, deepLink *nomad.DeepLink, readOnly bool) (app.Model, []tea.ProgramOption) {
+	config := getConfig(cmd, rootOpts, overrideToken)
+	config.ReadOnly = config.ReadOnly || readOnly
+	config.OutputIsTerminal = outputIsTerminal
+	config.DeepLink = deepLink
+	initialModel := app.InitialModel(config)
 	return initialModel, []tea.ProgramOption{tea.WithAltScreen()}
 }
@@ -0,0 +1,175 @@
+package fileio
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// castEventType is the type of an asciinema event: output, input or resize
+type castEventType string
+
+const (
+	castOutput castEventType = "o"
+	castInput  castEventType = "i"
+	castResize castEventType = "r"
+)
+
+var unsafeFileNameChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// CastRecorder records a terminal session to an asciinema v2 file, see
+// https://docs.asciinema.org/manual/asciicast/v2/. It is safe to use from multiple goroutines, e.g. ones copying
+// output and input at the same time.
+type CastRecorder struct {
+	mu    sync.Mutex
+	path  string
+	file  *os.File
+	start time.Time
+	// pending are the bytes at the end of the last write of each event type that don't yet form a complete
+	// UTF-8 character, as events must be valid strings
+	pending map[castEventType][]byte
+	err     error
+}
+
+type castHeader struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// NewCastRecorder creates dir if needed and starts a recording in it, named after the time and name
+func NewCastRecorder(dir, name string, width, height int, title string) (*CastRecorder, error) {
+	if strings.Contains(dir, "~") {
+		currUser, err := user.Current()
+		if err != nil {
+			return nil, err
+		}
+		dir = strings.ReplaceAll(dir, "~", currUser.HomeDir)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	fileName := fmt.Sprintf("%s_%s.cast", start.Format("2006-01-02T15-04-05"), unsafeFileNameChars.ReplaceAllString(name, "_"))
+	path := filepath.Join(dir, fileName)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	header, err := json.Marshal(castHeader{
+		Version:   2,
+		Width:     width,
+		Height:    height,
+		Timestamp: start.Unix(),
+		Title:     title,
+		Env:       map[string]string{"SHELL": os.Getenv("SHELL"), "TERM": os.Getenv("TERM")},
+	})
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	if _, err := f.Write(append(header, '\n')); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return &CastRecorder{path: path, file: f, start: start, pending: make(map[castEventType][]byte)}, nil
+}
+
+// OutputWriter records everything written to it as output
+func (r *CastRecorder) OutputWriter() *CastWriter {
+	return &CastWriter{recorder: r, eventType: castOutput}
+}
+
+// InputWriter records everything written to it as input, e.g. with io.TeeReader on stdin
+func (r *CastRecorder) InputWriter() *CastWriter {
+	return &CastWriter{recorder: r, eventType: castInput}
+}
+
+func (r *CastRecorder) Resize(width, height int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.writeEvent(castResize, fmt.Sprintf("%dx%d", width, height))
+}
+
+func (r *CastRecorder) Path() string {
+	return r.path
+}
+
+// Close finishes the recording, returning the first error recording it, if any
+func (r *CastRecorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, eventType := range []castEventType{castOutput, castInput} {
+		if pending := r.pending[eventType]; len(pending) > 0 {
+			// an incomplete character at the very end, written as the replacement character
+			r.writeEvent(eventType, string(pending))
+		}
+	}
+	if err := r.file.Close(); err != nil && r.err == nil {
+		r.err = err
+	}
+	return r.err
+}
+
+func (r *CastRecorder) record(eventType castEventType, p []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	data := append(r.pending[eventType], p...)
+	complete := completeUTF8Len(data)
+	r.pending[eventType] = append([]byte(nil), data[complete:]...)
+	if complete > 0 {
+		r.writeEvent(eventType, string(data[:complete]))
+	}
+}
+
+// writeEvent must be called with mu held. Errors are kept for Close rather than interrupting the session.
+func (r *CastRecorder) writeEvent(eventType castEventType, data string) {
+	if r.err != nil {
+		return
+	}
+	elapsed := time.Since(r.start).Seconds()
+	event, err := json.Marshal([]interface{}{elapsed, eventType, data})
+	if err != nil {
+		r.err = err
+		return
+	}
+	if _, err := r.file.Write(append(event, '\n')); err != nil {
+		r.err = err
+	}
+}
+
+// completeUTF8Len is the length of p without an incomplete UTF-8 character at its end
+func completeUTF8Len(p []byte) int {
+	// a character is at most utf8.UTFMax bytes, so only the last few bytes can start an incomplete one
+	for i := len(p) - 1; i >= 0 && i >= len(p)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(p[i]) {
+			if !utf8.FullRune(p[i:]) {
+				return i
+			}
+			break
+		}
+	}
+	return len(p)
+}
+
+// CastWriter records what is written to it as one type of event
+type CastWriter struct {
+	recorder  *CastRecorder
+	eventType castEventType
+}
+
+func (w *CastWriter) Write(p []byte) (int, error) {
+	w.recorder.record(w.eventType, p)
+	return len(p), nil
+}
@@ -14,7 +14,35 @@ type SaveCompleteMessage struct {
 	FullPath, SuccessMessage, Err string
 }
 
+type RecordPathChosenMsg struct {
+	SaveDialogValue string
+}
+
 func SaveToFile(saveDialogValue string, fileContent []string) (string, error) {
+	pathWithFileName, err := getSavePath(saveDialogValue)
+	if err != nil {
+		return "", err
+	}
+
+	f, createErr := os.Create(pathWithFileName)
+	if createErr != nil {
+		return "", createErr
+	}
+	defer f.Close()
+
+	for _, line := range fileContent {
+		_, writeErr := f.WriteString(line)
+		if writeErr != nil {
+			return "", writeErr
+		}
+	}
+
+	return pathWithFileName, nil
+}
+
+// getSavePath resolves the save dialog value to a full file path that does not exist yet, creating any
+// directories needed along the way
+func getSavePath(saveDialogValue string) (string, error) {
 	var path, fileName string
 
 	if saveDialogValue == "" {
@@ -69,19 +97,6 @@ func SaveToFile(saveDialogValue string, fileContent []string) (string, error) {
 		return "", fileExistsErr
 	}
 
-	f, createErr := os.Create(pathWithFileName)
-	if createErr != nil {
-		return "", createErr
-	}
-	defer f.Close()
-
-	for _, line := range fileContent {
-		_, writeErr := f.WriteString(line)
-		if writeErr != nil {
-			return "", writeErr
-		}
-	}
-
 	return pathWithFileName, nil
 }
 
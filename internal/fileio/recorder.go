@@ -0,0 +1,67 @@
+package fileio
+
+import (
+	"fmt"
+	"os"
+)
+
+// Recorder appends content to a file as it arrives. If maxBytes is positive, the file is rotated before it would
+// grow past maxBytes, moving the full file to the same path suffixed with an incrementing number.
+type Recorder struct {
+	path      string
+	maxBytes  int64
+	file      *os.File
+	written   int64
+	rotations int
+}
+
+func NewRecorder(saveDialogValue string, maxBytes int64) (*Recorder, error) {
+	path, err := getSavePath(saveDialogValue)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{path: path, maxBytes: maxBytes, file: f}, nil
+}
+
+func (r *Recorder) Write(content string) error {
+	if r.maxBytes > 0 && r.written > 0 && r.written+int64(len(content)) > r.maxBytes {
+		if err := r.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := r.file.WriteString(content)
+	r.written += int64(n)
+	return err
+}
+
+func (r *Recorder) Close() error {
+	return r.file.Close()
+}
+
+func (r Recorder) Path() string {
+	return r.path
+}
+
+func (r Recorder) Rotations() int {
+	return r.rotations
+}
+
+func (r *Recorder) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	r.rotations++
+	if err := os.Rename(r.path, fmt.Sprintf("%s.%d", r.path, r.rotations)); err != nil {
+		return err
+	}
+	f, err := os.Create(r.path)
+	if err != nil {
+		return err
+	}
+	r.file, r.written = f, 0
+	return nil
+}
@@ -0,0 +1,68 @@
+package fileio
+
+import (
+	"bytes"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+)
+
+// AppendToYAMLList appends item to the list under the top level key of the yaml file at path, creating the file
+// or the list if they don't exist. The rest of the file, including comments, is kept.
+func AppendToYAMLList(path, key string, item interface{}) error {
+	perm := os.FileMode(0600)
+	content, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if info, statErr := os.Stat(path); statErr == nil {
+		perm = info.Mode().Perm()
+	}
+
+	var doc yaml.Node
+	if len(bytes.TrimSpace(content)) > 0 {
+		if err := yaml.Unmarshal(content, &doc); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	if len(doc.Content) == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("%s is not a yaml mapping", path)
+	}
+
+	var itemNode yaml.Node
+	if err := itemNode.Encode(item); err != nil {
+		return err
+	}
+
+	var list *yaml.Node
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == key {
+			list = root.Content[i+1]
+		}
+	}
+	if list == nil {
+		list = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, list)
+	} else if list.Kind == yaml.ScalarNode && list.Tag == "!!null" {
+		// an empty value, e.g. `key:` with nothing after it
+		*list = yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	} else if list.Kind != yaml.SequenceNode {
+		return fmt.Errorf("%s in %s is not a list", key, path)
+	}
+	list.Content = append(list.Content, &itemNode)
+
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return err
+	}
+	if err := encoder.Close(); err != nil {
+		return err
+	}
+	return os.WriteFile(path, out.Bytes(), perm)
+}
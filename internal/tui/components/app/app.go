@@ -4,14 +4,16 @@ import (
 	"fmt"
 	"github.com/robinovitch61/wander/internal/fileio"
 	"os"
-	"os/exec"
 	"path"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
 	"github.com/hashicorp/nomad/api"
 	"github.com/itchyny/gojq"
 	"github.com/robinovitch61/wander/internal/dev"
@@ -24,23 +26,76 @@ import (
 	"github.com/robinovitch61/wander/internal/tui/message"
 	"github.com/robinovitch61/wander/internal/tui/nomad"
 	"github.com/robinovitch61/wander/internal/tui/style"
+	"github.com/robinovitch61/wander/internal/tui/terminal"
 )
 
+// maxAlerts is how many of the most recent alerts are kept on the alerts page
+const maxAlerts = 1000
+
+// execSessionChromeHeight is the lines of the exec session page other than its terminal: the filter, the tabs and the
+// footer
+const execSessionChromeHeight = 3
+
+// alertNotificationFrame is how long alert notifications stay in the view for, longer than a frame of the renderer
+const alertNotificationFrame = 100 * time.Millisecond
+
+// minTokenRefreshInterval stops refreshing the token over and over if nomad doesn't accept new tokens either
+const minTokenRefreshInterval = 10 * time.Second
+
 type TLSConfig struct {
 	CACert, CAPath, ClientCert, ClientKey, ServerName string
 	SkipVerify                                        bool
 }
 
 type EventConfig struct {
-	Topics       nomad.Topics
-	Namespace    string
-	JQQuery      *gojq.Code
-	AllocJQQuery *gojq.Code
+	Topics nomad.Topics
+	// JobTopics and AllocTopics are the topics of the job and allocation events pages, edited separately from Topics,
+	// those of the all events page. Their keys are followed for the job or allocation shown
+	JobTopics, AllocTopics nomad.Topics
+	Namespace              string
+	JQQuery                *gojq.Code
+	AllocJQQuery           *gojq.Code
+	// JQQueryString and AllocJQQueryString are the queries JQQuery and AllocJQQuery were compiled from
+	JQQueryString, AllocJQQueryString string
+	// SummaryJQQuery groups events in the events summary by its output for each event
+	SummaryJQQuery *gojq.Code
+	Presets        []nomad.EventsPreset
+	// MaxRows is how many rows events pages keep, dropping the oldest ones, 0 for no maximum
+	MaxRows int
+	// ReplayPath is a recording of events to replay instead of connecting to the cluster, see nomad.RecordedEventsLine
+	ReplayPath string
+}
+
+type AlertConfig struct {
+	// Topics are followed in the background for events to check Rules against
+	Topics nomad.Topics
+	Rules  []nomad.AlertRule
+}
+
+type ExecConfig struct {
+	// Embedded runs exec sessions in a page, in tabs that stay open while browsing, rather than in the terminal
+	Embedded bool
+	// Scrollback is how many lines of scrollback embedded exec sessions keep
+	Scrollback int
+	// RecordDir is the directory embedded exec sessions are recorded to, empty to not record them
+	RecordDir string
+	// AllConcurrency is the most allocations a command runs in at once when run in every allocation of a job
+	AllConcurrency int
 }
 
 type LogConfig struct {
-	Offset int
-	Tail   bool
+	Offset     int
+	Tail       bool
+	Structured bool
+	Fields     []string
+	// PreserveColors keeps SGR color sequences in logs, stripping all other escape sequences
+	PreserveColors bool
+	// GroupRegex matches lines that continue the previous log entry, e.g. in stack traces, nil to not group lines
+	GroupRegex *regexp.Regexp
+	// RecordMaxBytes is the size at which files that logs are recorded to are rotated, 0 for no rotation
+	RecordMaxBytes int
+	// MaxRows is how many rows the logs page keeps, dropping the oldest ones, 0 for no maximum
+	MaxRows int
 }
 
 type Config struct {
@@ -48,20 +103,32 @@ type Config struct {
 	Version                       string
 	URL, Token, Region, Namespace string
 	HTTPAuth                      string
-	TLS                           TLSConfig
-	Event                         EventConfig
-	Log                           LogConfig
-	CopySavePath                  bool
-	UpdateSeconds                 time.Duration
-	JobColumns                    []string
-	AllTaskColumns                []string
-	JobTaskColumns                []string
-	LogoColor                     string
-	StartCompact                  bool
-	StartAllTasksView             bool
-	CompactTables                 bool
-	StartFiltering                bool
-	FilterWithContext             bool
+	// TokenSource, if not nil, gives a new token when nomad says the current one is expired or not found
+	TokenSource       *nomad.TokenSource
+	TLS               TLSConfig
+	Event             EventConfig
+	Alert             AlertConfig
+	Exec              ExecConfig
+	Log               LogConfig
+	CopySavePath      bool
+	UpdateSeconds     time.Duration
+	JobColumns        []string
+	AllTaskColumns    []string
+	JobTaskColumns    []string
+	LogoColor         string
+	StartCompact      bool
+	StartAllTasksView bool
+	CompactTables     bool
+	StartFiltering    bool
+	FilterWithContext bool
+	// OutputIsTerminal is true if the program renders to a terminal, which alert notifications like the bell are for
+	OutputIsTerminal bool
+	// ConfigFilePath is where changes to the config, like new event presets, are saved
+	ConfigFilePath string
+	// DeepLink, if not nil, is the page to start on, already resolved
+	DeepLink *nomad.DeepLink
+	// ReadOnly removes every action that changes the cluster or runs commands in it, like the admin menus and exec
+	ReadOnly bool
 }
 
 type Model struct {
@@ -83,13 +150,82 @@ type Model struct {
 
 	updateID int
 
+	// tokenRefreshedAt is when the token was last refreshed from config.TokenSource
+	tokenRefreshedAt time.Time
+	// reloadPageOnTokenRefresh is true if the current page's own fetch or stream needed the token being refreshed
+	reloadPageOnTokenRefresh bool
+
 	lastExecContent string
 
+	// execAllResults are the results of the last command run in every allocation of a job, or execAllErr if it
+	// couldn't be run
+	execAllResults []nomad.ExecAllResult
+	execAllErr     error
+	// execAllResultIdx is the index in execAllResults of the result being shown
+	execAllResultIdx int
+
+	// execSessions are the open embedded exec sessions, one per tab
+	execSessions []*nomad.ExecSession
+	// execSessionIdx is the index in execSessions of the session shown on the exec session page
+	execSessionIdx int
+	// execSessionAttached sends all keys but the detach key to the session shown rather than handling them
+	execSessionAttached bool
+	// execSessionsOrigin is the page the list of exec sessions goes back to
+	execSessionsOrigin nomad.Page
+
+	// copyProgress is the progress of the copy running in the background, nil if there isn't one
+	copyProgress    *nomad.CopyProgress
+	copyDescription string
+	// copyID invalidates progress ticks scheduled for previous copies
+	copyID int
+
 	eventsStream nomad.EventsStream
 	event        string
+	// eventsReconnectAttempt counts failed attempts to reconnect eventsStream since it closed, 0 if connected
+	eventsReconnectAttempt int
+	// eventsRecorder is non-nil while recording the events page's stream
+	eventsRecorder *fileio.Recorder
+	eventsReplay   eventsReplay
+	// eventsEditOrigin is the events page that the pages editing its topics and jq query were opened from
+	eventsEditOrigin nomad.Page
+	// editedEventTopics are the topics entered on the topics page, applied along with the jq query
+	editedEventTopics nomad.Topics
+
+	// eventsSummary counts the events of eventsSummaryStream, which follows the topics of eventsSummaryOrigin
+	eventsSummary       nomad.EventsSummary
+	eventsSummaryStream nomad.EventsStream
+	eventsSummaryOrigin nomad.Page
+	// eventsSummaryReconnectAttempt counts failed attempts to reconnect eventsSummaryStream since it closed, 0 if
+	// connected
+	eventsSummaryReconnectAttempt int
+	// eventsSummaryTickID invalidates ticks scheduled for previously opened summaries
+	eventsSummaryTickID int
+	// eventsGroup is the key of the summary's group being drilled into
+	eventsGroup string
+
+	// alertsStream is followed in the background, whatever the current page, to check alert rules against
+	alertsStream           nomad.EventsStream
+	alertsReconnectAttempt int
+	// alerts are the most recently fired alerts, oldest first
+	alerts []nomad.Alert
+	// unseenAlerts counts alerts fired since the alerts page was last open
+	unseenAlerts int
+	// notification has the escape sequences of alert notifications for the view to write, see View
+	notification string
 
 	logsStream      nomad.LogsStream
 	lastLogFinished bool
+	structuredLogs  bool
+	warnLogsOnly    bool
+	// lastLogLine is the last raw line received from logsStream if it's shown unstructured and ungrouped, to which
+	// the start of the next chunk is added if it's unfinished
+	lastLogLine string
+	// logTable has the rows of logsStream if it's structured or grouped
+	logTable *nomad.LogTable
+
+	// logRecorder is non-nil while recording, and keeps recording recordingLogsStream when leaving the logs page
+	logRecorder         *fileio.Recorder
+	recordingLogsStream nomad.LogsStream
 
 	// adminAction is a key of AllocAdminActions (or JobAdminActions, when it exists)
 	adminAction nomad.AdminAction
@@ -101,7 +237,11 @@ type Model struct {
 
 func getFirstPage(c Config) nomad.Page {
 	firstPage := nomad.JobsPage
-	if c.StartAllTasksView {
+	if c.Event.ReplayPath != "" {
+		firstPage = nomad.ReplayEventsPage
+	} else if c.DeepLink != nil {
+		firstPage = c.DeepLink.Page
+	} else if c.StartAllTasksView {
 		firstPage = nomad.AllTasksPage
 	}
 	return firstPage
@@ -109,20 +249,35 @@ func getFirstPage(c Config) nomad.Page {
 
 func InitialModel(c Config) Model {
 	firstPage := getFirstPage(c)
+	logType := nomad.StdOut
+	if c.DeepLink != nil {
+		logType = c.DeepLink.LogType
+	}
 	initialHeader := header.New(
 		constants.LogoString,
 		c.LogoColor,
 		c.URL,
 		c.Version,
-		nomad.GetPageKeyHelp(firstPage, false, false, false, nomad.StdOut, false, !c.StartAllTasksView),
+		nomad.GetPageKeyHelp(firstPage, false, false, false, logType, c.Log.Structured, false, false, false, !c.StartAllTasksView, false, false, c.ReadOnly),
 	)
-	return Model{
-		config:      c,
-		header:      initialHeader,
-		currentPage: firstPage,
-		updateID:    nextUpdateID(),
-		inJobsMode:  !c.StartAllTasksView,
+	m := Model{
+		config:         c,
+		header:         initialHeader,
+		currentPage:    firstPage,
+		updateID:       nextUpdateID(),
+		inJobsMode:     !c.StartAllTasksView,
+		logType:        logType,
+		structuredLogs: c.Log.Structured,
+		eventsReplay:   newEventsReplay(),
+	}
+	if c.DeepLink != nil {
+		// what the pages back from the first page show, so going back from it is the same as if navigated to
+		m.jobID, m.jobNamespace = c.DeepLink.JobID, c.DeepLink.JobNamespace
+		if c.DeepLink.Alloc != nil {
+			m.alloc, m.taskName = *c.DeepLink.Alloc, c.DeepLink.Task
+		}
 	}
+	return m
 }
 
 func (m Model) Init() tea.Cmd {
@@ -136,6 +291,9 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		cmds []tea.Cmd
 	)
 
+	// notifications stay in the view until the next message, so are written in one frame at most
+	m.notification = ""
+
 	currentPageModel := m.getCurrentPageModel()
 	if currentPageModel != nil && currentPageModel.EnteringInput() {
 		*currentPageModel, cmd = currentPageModel.Update(msg)
@@ -144,18 +302,60 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case message.CleanupCompleteMsg:
+		if m.logRecorder != nil {
+			_ = m.logRecorder.Close()
+		}
+		if m.eventsRecorder != nil {
+			_ = m.eventsRecorder.Close()
+		}
+		if m.alertsStream.Cancel != nil {
+			m.alertsStream.Cancel()
+		}
+		m.cancelEventsSummaryStream()
+		for _, s := range m.execSessions {
+			s.Close()
+		}
 		return m, tea.Quit
 
 	case tea.KeyMsg:
+		if m.currentPage == nomad.ExecSessionPage && m.execSessionAttached && !m.currentPageLoading() {
+			// checked before the exit key so keys like ctrl+c go to the session
+			if key.Matches(msg, keymap.KeyMap.ExecDetach) {
+				m.execSessionAttached = false
+				m.refreshExecSession()
+			} else {
+				s := m.execSessions[m.execSessionIdx]
+				s.Input(terminal.KeyBytes(msg, s.Terminal.AppCursorKeys()))
+			}
+			m.updateKeyHelp()
+			return m, nil
+		}
 		cmd = m.handleKeyMsg(msg)
 		if cmd != nil {
 			return m, cmd
 		}
 
 	case message.ErrMsg:
+		if cmd := m.refreshToken(msg.Err, true); cmd != nil {
+			return m, cmd
+		}
 		m.err = msg
 		return m, nil
 
+	case nomad.TokenRefreshedMsg:
+		if msg.Err != nil {
+			m.err = msg.Err
+			return m, nil
+		}
+		m.client.SetSecretID(msg.Token)
+		m.config.Token = msg.Token
+		// streams that closed reconnect themselves, resuming where they left off
+		if m.reloadPageOnTokenRefresh {
+			m.reloadPageOnTokenRefresh = false
+			return m, m.getCurrentPageCmd()
+		}
+		return m, nil
+
 	case tea.WindowSizeMsg:
 		m.width, m.height = msg.Width, msg.Height
 		if !m.initialized {
@@ -164,7 +364,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.err = err
 				return m, nil
 			}
-			cmds = append(cmds, m.getCurrentPageCmd())
+			cmds = append(cmds, m.getCurrentPageCmd(), m.openAlertsStream())
 		} else {
 			m.setPageWindowSize()
 		}
@@ -196,21 +396,52 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 
 			switch m.currentPage {
-			case nomad.JobEventsPage, nomad.AllEventsPage:
-				m.eventsStream = msg.EventsStream
-				cmds = append(cmds, nomad.ReadEventsStreamNextMessage(m.eventsStream, m.config.Event.JQQuery))
-			case nomad.AllocEventsPage:
+			case nomad.JobEventsPage, nomad.AllocEventsPage, nomad.AllEventsPage:
+				m.cancelEventsStream()
 				m.eventsStream = msg.EventsStream
-				cmds = append(cmds, nomad.ReadEventsStreamNextMessage(m.eventsStream, m.config.Event.AllocJQQuery))
+				m.eventsStream.ID = nextStreamID()
+				cmds = append(cmds, nomad.ReadEventsStreamNextMessage(m.eventsStream, m.getEventsJQQuery()))
 			case nomad.LogsPage:
 				m.getCurrentPageModel().SetViewportSelectionToBottom()
 				if m.config.Log.Tail {
 					m.logsStream = msg.LogsStream
+					m.logsStream.ID = nextStreamID()
 					m.lastLogFinished = true
+					m.lastLogLine = ""
+					m.logTable = nomad.NewLogTable(m.logsStream.LogType, m.logsStream.Fields, m.logsStream.GroupContinuation)
 					cmds = append(cmds, nomad.ReadLogsStreamNextMessage(m.logsStream))
 				}
+			case nomad.ReplayEventsPage:
+				m.eventsReplay.recorded = msg.RecordedEvents
+				m.eventsReplay.replayed = min(m.eventsReplay.replayed, len(msg.RecordedEvents))
+				m.getCurrentPageModel().SetViewportSelectionToBottom()
+				cmds = append(cmds, m.eventsReplay.nextTick())
+			case nomad.AlertsPage:
+				m.getCurrentPageModel().SetViewportSelectionToBottom()
+			case nomad.EventsSummaryPage:
+				m.getCurrentPageModel().SelectRowByKey(m.eventsGroup)
+			case nomad.ExecSessionsPage:
+				m.getCurrentPageModel().SelectRowByKey(strconv.Itoa(m.execSessionIdx))
+			case nomad.ExecSessionPage:
+				if m.execSessionAttached {
+					m.getCurrentPageModel().ScrollViewportToBottom()
+				}
 			case nomad.ExecPage:
 				m.getCurrentPageModel().SetInputPrefix("Enter command: ")
+			case nomad.CopyPage:
+				m.getCurrentPageModel().SetInputPrefix("Copy, download <task path> [local path] or upload <local path> <task path>: ")
+			case nomad.ExecAllPage:
+				m.getCurrentPageModel().SetInputPrefix(fmt.Sprintf("Command to run in %s in every %s allocation: ", m.taskName, m.alloc.TaskGroup))
+			case nomad.EventsTopicsPage:
+				m.getCurrentPageModel().SetInputPrefix("Topics, e.g. Job:my-job,Allocation,Node: ")
+			case nomad.EventsJQQueryPage:
+				if m.eventsEditOrigin == nomad.AllocEventsPage {
+					m.getCurrentPageModel().SetInputPrefix("jq query for allocation events: ")
+				} else {
+					m.getCurrentPageModel().SetInputPrefix("jq query for events: ")
+				}
+			case nomad.EventsPresetNamePage:
+				m.getCurrentPageModel().SetInputPrefix("Preset name: ")
 			case nomad.AllocAdminConfirmPage, nomad.JobAdminConfirmPage:
 				// always make user go down one to confirm
 				m.getCurrentPageModel().SetViewportSelectionToTop()
@@ -219,52 +450,185 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 
 	case nomad.EventsStreamMsg:
-		if m.currentPage == nomad.JobEventsPage || m.currentPage == nomad.AllocEventsPage || m.currentPage == nomad.AllEventsPage {
-			if fmt.Sprint(msg.Topics) == fmt.Sprint(m.eventsStream.Topics) {
-				// sticky scroll down, i.e. if at bottom already, keep scrolling to bottom as new ones are added
-				scrollDown := m.getCurrentPageModel().ViewportSelectionAtBottom()
-				for _, event := range msg.Events {
-					if event.CompleteValue == "{}" {
-						continue
-					}
-					m.getCurrentPageModel().AppendToViewport([]page.Row{{Key: event.CompleteValue, Row: event.JQValue}}, true)
+		if msg.StreamID == m.eventsSummaryStream.ID {
+			if msg.Index > m.eventsSummaryStream.LastIndex {
+				m.eventsSummaryStream.LastIndex = msg.Index
+			}
+			// the summary page is re-rendered on the next tick rather than for every batch of events
+			if err := m.eventsSummary.Add(msg.Raw, time.Now()); err != nil {
+				m.err = err
+				return m, nil
+			}
+			cmds = append(cmds, nomad.ReadEventsStreamNextMessage(m.eventsSummaryStream, m.getEventsJQQuery()))
+		} else if m.currentPage.StreamsEvents() && msg.StreamID == m.eventsStream.ID {
+			if msg.Index > m.eventsStream.LastIndex {
+				m.eventsStream.LastIndex = msg.Index
+			}
+			// recordings skip heartbeats, which would otherwise be most of the lines of quiet streams
+			if m.eventsRecorder != nil && !msg.Empty {
+				if err := m.recordEvents(msg.Raw); err != nil {
+					recordPath, _ := m.stopRecordingEvents()
+					newToast := toast.New(fmt.Sprintf("Error: stopped recording to %s: %s", recordPath, err))
+					m.getCurrentPageModel().SetToast(newToast, style.ErrorToast)
+					cmds = append(cmds, tea.Tick(newToast.Timeout, func(t time.Time) tea.Msg { return toast.TimeoutMsg{ID: newToast.ID} }))
 				}
-				if scrollDown {
-					m.getCurrentPageModel().ScrollViewportToBottom()
+			}
+			// sticky scroll down, i.e. if at bottom already, keep scrolling to bottom as new ones are added
+			scrollDown := m.getCurrentPageModel().ViewportSelectionAtBottom()
+			for _, event := range msg.Events {
+				if event.CompleteValue == "{}" {
+					continue
 				}
+				m.getCurrentPageModel().AppendToViewport([]page.Row{{Key: event.CompleteValue, Row: event.JQValue}}, true)
 			}
-			query := m.config.Event.JQQuery
-			if m.currentPage == nomad.AllocEventsPage {
-				query = m.config.Event.AllocJQQuery
+			if scrollDown {
+				m.getCurrentPageModel().ScrollViewportToBottom()
 			}
-			cmds = append(cmds, nomad.ReadEventsStreamNextMessage(m.eventsStream, query))
+			cmds = append(cmds, nomad.ReadEventsStreamNextMessage(m.eventsStream, m.getEventsJQQuery()))
 		}
 
-	case nomad.LogsStreamMsg:
-		if m.currentPage == nomad.LogsPage && m.logType == msg.Type {
-			logLines := strings.Split(msg.Value, "\n")
-
-			// finish with the last log line if necessary
-			if !m.lastLogFinished {
-				m.getCurrentPageModel().AppendToViewport([]page.Row{{Row: logLines[0]}}, false)
-				logLines = logLines[1:]
-			}
-
-			// append all the new log rows in this chunk to the viewport at once
+	case nomad.ReplayEventsTickMsg:
+		if m.currentPage == nomad.ReplayEventsPage && msg.ID == m.eventsReplay.tickID && !m.eventsReplay.finished() {
 			scrollDown := m.getCurrentPageModel().ViewportSelectionAtBottom()
-			var allRows []page.Row
-			for _, logLine := range logLines {
-				allRows = append(allRows, page.Row{Row: logLine})
+			next := m.eventsReplay.recorded[m.eventsReplay.replayed : m.eventsReplay.replayed+1]
+			if rows := nomad.RecordedEventsAsRows(next, m.config.Event.JQQuery); len(rows) > 0 {
+				m.getCurrentPageModel().AppendToViewport(rows, true)
 			}
-			m.getCurrentPageModel().AppendToViewport(allRows, true)
+			m.eventsReplay.replayed++
 			if scrollDown {
 				m.getCurrentPageModel().ScrollViewportToBottom()
 			}
+			cmds = append(cmds, m.eventsReplay.nextTick())
+		}
+
+	case nomad.EventsSummaryTickMsg:
+		if msg.ID == m.eventsSummaryTickID && m.currentPage.SummarizesEvents() {
+			if m.currentPage == nomad.EventsSummaryPage && !m.currentPageLoading() {
+				m.refreshEventsSummary()
+			}
+			cmds = append(cmds, m.eventsSummaryTick())
+		}
+
+	case nomad.AlertsMsg:
+		if msg.StreamID == m.alertsStream.ID {
+			if msg.Index > m.alertsStream.LastIndex {
+				m.alertsStream.LastIndex = msg.Index
+			}
+			cmds = append(cmds, m.fireAlerts(msg.Alerts))
+			cmds = append(cmds, nomad.ReadAlertsStreamNextMessage(m.alertsStream, m.config.Alert.Rules))
+		}
+
+	case nomad.EventsStreamClosedMsg:
+		var attempt int
+		if msg.StreamID == m.alertsStream.ID {
+			if m.alertsStream.Cancel != nil {
+				m.alertsStream.Cancel()
+			}
+			m.alertsReconnectAttempt++
+			attempt = m.alertsReconnectAttempt
+		} else if m.currentPage.SummarizesEvents() && msg.StreamID == m.eventsSummaryStream.ID {
+			if m.eventsSummaryStream.Cancel != nil {
+				m.eventsSummaryStream.Cancel()
+			}
+			m.eventsSummaryReconnectAttempt++
+			attempt = m.eventsSummaryReconnectAttempt
+		} else if m.currentPage.StreamsEvents() && msg.StreamID == m.eventsStream.ID {
+			m.cancelEventsStream()
+			m.eventsReconnectAttempt++
+			attempt = m.eventsReconnectAttempt
+		}
+		if attempt > 0 {
+			streamID := msg.StreamID
+			reconnect := tea.Tick(nomad.EventsReconnectDelay(attempt), func(t time.Time) tea.Msg {
+				return nomad.ReconnectEventsStreamMsg{StreamID: streamID}
+			})
+			// reconnects with a new token if nomad closed the stream as it no longer accepts the current one
+			cmds = append(cmds, tea.Sequence(m.refreshToken(msg.Err, false), reconnect))
+		}
+
+	case nomad.ReconnectEventsStreamMsg:
+		if msg.StreamID == m.alertsStream.ID {
+			cmds = append(cmds, nomad.ResumeEventsStream(m.client, m.alertsStream))
+		} else if m.currentPage.SummarizesEvents() && msg.StreamID == m.eventsSummaryStream.ID {
+			cmds = append(cmds, nomad.ResumeEventsStream(m.client, m.eventsSummaryStream))
+		} else if m.currentPage.StreamsEvents() && msg.StreamID == m.eventsStream.ID {
+			cmds = append(cmds, nomad.ResumeEventsStream(m.client, m.eventsStream))
+		}
+
+	case nomad.EventsStreamResumedMsg:
+		if msg.PrevStreamID == m.alertsStream.ID {
+			m.alertsStream = msg.EventsStream
+			m.alertsStream.ID = nextStreamID()
+			m.alertsReconnectAttempt = 0
+			cmds = append(cmds, nomad.ReadAlertsStreamNextMessage(m.alertsStream, m.config.Alert.Rules))
+		} else if m.currentPage.SummarizesEvents() && msg.PrevStreamID == m.eventsSummaryStream.ID {
+			m.eventsSummaryStream = msg.EventsStream
+			m.eventsSummaryStream.ID = nextStreamID()
+			m.eventsSummaryReconnectAttempt = 0
+			cmds = append(cmds, nomad.ReadEventsStreamNextMessage(m.eventsSummaryStream, m.getEventsJQQuery()))
+		} else if m.currentPage.StreamsEvents() && msg.PrevStreamID == m.eventsStream.ID {
+			m.eventsStream = msg.EventsStream
+			m.eventsStream.ID = nextStreamID()
+			m.eventsReconnectAttempt = 0
+			cmds = append(cmds, nomad.ReadEventsStreamNextMessage(m.eventsStream, m.getEventsJQQuery()))
+		} else {
+			// the page was left while reconnecting
+			msg.EventsStream.Cancel()
+		}
 
-			m.lastLogFinished = strings.HasSuffix(msg.Value, "\n")
+	case nomad.LogsStreamMsg:
+		recording := m.logRecorder != nil && msg.StreamID == m.recordingLogsStream.ID
+		if recording {
+			if err := m.logRecorder.Write(msg.Value); err != nil {
+				recordPath, _ := m.stopRecordingLogs()
+				newToast := toast.New(fmt.Sprintf("Error: stopped recording to %s: %s", recordPath, err))
+				m.getCurrentPageModel().SetToast(newToast, style.ErrorToast)
+				cmds = append(cmds, tea.Tick(newToast.Timeout, func(t time.Time) tea.Msg { return toast.TimeoutMsg{ID: newToast.ID} }))
+				recording = false
+			}
+		}
+		if m.currentPage == nomad.LogsPage && msg.StreamID == m.logsStream.ID {
+			m.appendLogs(msg.Value)
 			cmds = append(cmds, nomad.ReadLogsStreamNextMessage(m.logsStream))
+		} else if recording {
+			// keep recording while on other pages
+			cmds = append(cmds, nomad.ReadLogsStreamNextMessage(m.recordingLogsStream))
 		}
 
+	case nomad.LogsStreamClosedMsg:
+		if m.currentPage == nomad.LogsPage && msg.StreamID == m.logsStream.ID {
+			// refreshing the token reloads the page, opening the logs again
+			if cmd := m.refreshToken(msg.Err, true); cmd != nil {
+				return m, cmd
+			}
+			if msg.Err != nil {
+				m.err = msg.Err
+				return m, nil
+			}
+		}
+
+	case fileio.RecordPathChosenMsg:
+		var maxBytes int64
+		if m.currentPage == nomad.LogsPage {
+			maxBytes = int64(m.config.Log.RecordMaxBytes)
+		}
+		var toastMsg string
+		toastStyle := style.SuccessToast
+		if recorder, err := fileio.NewRecorder(msg.SaveDialogValue, maxBytes); err != nil {
+			toastMsg = fmt.Sprintf("Error: %s", err)
+			toastStyle = style.ErrorToast
+		} else if m.currentPage == nomad.LogsPage {
+			m.logRecorder = recorder
+			m.recordingLogsStream = m.logsStream
+			toastMsg = fmt.Sprintf("Recording %s %s logs to %s", m.taskName, m.logType.ShortString(), recorder.Path())
+		} else {
+			m.eventsRecorder = recorder
+			toastMsg = fmt.Sprintf("Recording events to %s", recorder.Path())
+		}
+		newToast := toast.New(toastMsg)
+		m.getCurrentPageModel().SetToast(newToast, toastStyle)
+		cmds = append(cmds, tea.Tick(newToast.Timeout, func(t time.Time) tea.Msg { return toast.TimeoutMsg{ID: newToast.ID} }))
+
 	case nomad.UpdatePageDataMsg:
 		if msg.ID == m.updateID && msg.Page == m.currentPage {
 			cmds = append(cmds, m.getCurrentPageCmd())
@@ -279,8 +643,48 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			cmds = append(cmds, m.getCurrentPageCmd())
 		}
 
+	case nomad.ExecSessionUpdatedMsg:
+		if idx := m.execSessionIndex(msg.ID); idx >= 0 {
+			if m.currentPage == nomad.ExecSessionPage && idx == m.execSessionIdx && !m.currentPageLoading() {
+				m.refreshExecSession()
+			}
+			cmds = append(cmds, nomad.ReadExecSessionNextUpdate(m.execSessions[idx]))
+		}
+
+	case nomad.ExecSessionClosedMsg:
+		if idx := m.execSessionIndex(msg.ID); idx >= 0 {
+			cmds = append(cmds, m.handleExecSessionClosed(idx, msg))
+		}
+
+	case nomad.CopyProgressTickMsg:
+		if msg.ID == m.copyID && m.copyProgress != nil {
+			// the header status shows the progress, updated after every message
+			cmds = append(cmds, nomad.CopyProgressTick(m.copyID))
+		}
+
+	case nomad.CopyCompleteMsg:
+		if msg.ID == m.copyID {
+			cmds = append(cmds, m.handleCopyComplete(msg))
+		}
+
+	case nomad.ExecAllCompleteMsg:
+		m.execAllResults, m.execAllErr = msg.Results, msg.Err
+		if m.currentPage == nomad.ExecAllResultsPage {
+			cmds = append(cmds, m.getCurrentPageCmd())
+		}
+
 	case message.PageInputReceivedMsg:
-		if m.currentPage == nomad.ExecPage {
+		switch m.currentPage {
+		case nomad.ExecPage:
+			if m.config.ReadOnly {
+				m.getCurrentPageModel().SetInputError(nomad.ErrReadOnly.Error())
+				break
+			}
+			if m.config.Exec.Embedded {
+				cmds = append(cmds, m.startExecSession(msg.Input))
+				break
+			}
+
 			// run the same wander executable even if there is a different one in the path
 			ex, err := os.Executable()
 			if err != nil {
@@ -289,17 +693,8 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 			dir := path.Dir(ex)
 
-			args := []string{"exec"}
 			// pass the same cli opts to wander exec as passed into the current wander root command
-			args = append(args, m.config.RootOpts...)
-			args = append(args, []string{
-				m.alloc.ID,
-				"--task",
-				m.taskName,
-				msg.Input,
-			}...)
-			c := exec.Command(fmt.Sprintf("%s/wander", dir), args...)
-			c.Env = os.Environ()
+			c := execCommand(fmt.Sprintf("%s/wander", dir), m.config.RootOpts, m.config.Token, os.Environ(), m.alloc.ID, "--task", m.taskName, msg.Input)
 
 			stdoutProxy := &nomad.StdoutProxy{}
 			c.Stdout = stdoutProxy
@@ -307,6 +702,65 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, tea.ExecProcess(c, func(err error) tea.Msg {
 				return nomad.ExecCompleteMsg{Output: string(stdoutProxy.SavedOutput)}
 			})
+
+		case nomad.ExecAllPage:
+			command, err := nomad.SplitCommand(msg.Input)
+			if err != nil {
+				m.getCurrentPageModel().SetInputError(err.Error())
+				break
+			}
+			m.getCurrentPageModel().SetDoesNeedNewInput()
+			m.execAllResults, m.execAllErr = nil, nil
+			m.setPage(nomad.ExecAllResultsPage)
+			cmds = append(cmds, nomad.FetchExecAll(m.client, m.alloc.JobID, m.alloc.Namespace, m.alloc.TaskGroup, m.taskName, command, m.config.Exec.AllConcurrency, m.config.ReadOnly))
+
+		case nomad.CopyPage:
+			upload, localPath, taskPath, err := nomad.ParseCopyInput(msg.Input)
+			if err != nil {
+				m.getCurrentPageModel().SetInputError(err.Error())
+				break
+			}
+			if m.copyProgress != nil {
+				m.getCurrentPageModel().SetInputError(fmt.Sprintf("already copying %s", m.copyDescription))
+				break
+			}
+			m.getCurrentPageModel().SetDoesNeedNewInput()
+			m.copyID++
+			m.copyProgress = &nomad.CopyProgress{}
+			if upload {
+				m.copyDescription = fmt.Sprintf("%s to %s:%s", localPath, m.taskName, taskPath)
+			} else {
+				m.copyDescription = fmt.Sprintf("%s:%s to %s", m.taskName, taskPath, localPath)
+			}
+			m.setPage(m.currentPage.Backward(m.inJobsMode))
+			cmds = append(
+				cmds,
+				m.getCurrentPageCmd(),
+				nomad.RunCopy(m.client, m.copyID, m.alloc.ID, m.taskName, upload, localPath, taskPath, m.copyProgress, m.config.ReadOnly),
+				nomad.CopyProgressTick(m.copyID),
+			)
+
+		case nomad.EventsTopicsPage:
+			topics, err := nomad.ParseTopics(msg.Input)
+			if err != nil {
+				m.getCurrentPageModel().SetInputError(err.Error())
+				break
+			}
+			m.editedEventTopics = topics
+			m.getCurrentPageModel().SetDoesNeedNewInput()
+			cmds = append(cmds, m.setEventsInputPage(nomad.EventsJQQueryPage, m.editedEventsJQQueryString()))
+
+		case nomad.EventsJQQueryPage:
+			code, err := nomad.CompileJQQuery(msg.Input)
+			if err != nil {
+				m.getCurrentPageModel().SetInputError(err.Error())
+				break
+			}
+			m.getCurrentPageModel().SetDoesNeedNewInput()
+			cmds = append(cmds, m.applyEventsQuery(m.editedEventTopics, msg.Input, code))
+
+		case nomad.EventsPresetNamePage:
+			cmds = append(cmds, m.saveEventsPreset(strings.TrimSpace(msg.Input)))
 		}
 
 	case fileio.SaveCompleteMessage:
@@ -363,11 +817,22 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		cmds = append(cmds, cmd)
 	}
 	m.updateKeyHelp()
+	m.updateHeaderStatus()
 
 	return m, tea.Batch(cmds...)
 }
 
 func (m Model) View() string {
+	// notifications go at the start of the first line, which is painted every frame. The renderer truncates lines to
+	// the window, which would end a notification part way through its sequence, so those too wide are just the bell
+	notification := m.notification
+	if notification != "" && lipgloss.Width(notification) > m.width {
+		notification = "\a"
+	}
+	return notification + m.pageView()
+}
+
+func (m Model) pageView() string {
 	if m.err != nil {
 		return fmt.Sprintf("Error: %v", m.err) + "\n\nif this seems wrong, consider opening an issue here: https://github.com/robinovitch61/wander/issues/new/choose" + "\n\nq/ctrl+c to quit"
 	} else if !m.initialized {
@@ -395,10 +860,20 @@ func (m *Model) initialize() error {
 		m.pageModels[k] = &p
 	}
 
+	m.pageModels[nomad.LogsPage].SetMaxRows(m.config.Log.MaxRows)
+	for _, p := range []nomad.Page{nomad.JobEventsPage, nomad.AllocEventsPage, nomad.AllEventsPage, nomad.ReplayEventsPage} {
+		m.pageModels[p].SetMaxRows(m.config.Event.MaxRows)
+	}
+
 	if m.config.StartCompact {
 		m.toggleCompact()
 	}
 
+	if m.logType == nomad.StdErr {
+		stdErrHeaderStyle := style.ViewportHeaderStyle.Copy().Inherit(style.StdErr)
+		m.pageModels[nomad.LogsPage].SetViewportStyle(stdErrHeaderStyle, style.StdErr)
+	}
+
 	m.getCurrentPageModel().SetFilterPrefix(m.getFilterPrefix(m.currentPage))
 
 	m.initialized = true
@@ -415,6 +890,13 @@ func (m *Model) setPageWindowSize() {
 	for _, pm := range m.pageModels {
 		pm.SetWindowSize(m.width, m.getPageHeight())
 	}
+	width, height := m.execTerminalSize()
+	for _, s := range m.execSessions {
+		s.Resize(width, height)
+	}
+	if m.currentPage == nomad.ExecSessionPage && !m.currentPageLoading() {
+		m.refreshExecSession()
+	}
 }
 
 func (m *Model) handleKeyMsg(msg tea.KeyMsg) tea.Cmd {
@@ -443,17 +925,38 @@ func (m *Model) handleKeyMsg(msg tea.KeyMsg) tea.Cmd {
 				switch m.currentPage {
 				case nomad.JobsPage:
 					m.jobID, m.jobNamespace = nomad.JobIDAndNamespaceFromKey(selectedPageRow.Key)
-				case nomad.JobEventsPage, nomad.AllocEventsPage, nomad.AllEventsPage:
+				case nomad.JobEventsPage, nomad.AllocEventsPage, nomad.AllEventsPage, nomad.ReplayEventsPage, nomad.AlertsPage, nomad.EventsGroupPage:
 					m.event = selectedPageRow.Key
+				case nomad.EventsSummaryPage:
+					m.eventsGroup = selectedPageRow.Key
+				case nomad.ExecAllResultsPage:
+					resultIdx, err := strconv.Atoi(selectedPageRow.Key)
+					if err != nil || resultIdx >= len(m.execAllResults) {
+						return nil
+					}
+					m.execAllResultIdx = resultIdx
+				case nomad.ExecSessionsPage:
+					sessionIdx, err := strconv.Atoi(selectedPageRow.Key)
+					if err != nil || sessionIdx >= len(m.execSessions) {
+						return nil
+					}
+					m.execSessionIdx = sessionIdx
+					m.execSessionAttached = !m.execSessions[sessionIdx].Exited()
+				case nomad.EventsPresetsPage:
+					presetIdx, err := strconv.Atoi(selectedPageRow.Key)
+					if err != nil || presetIdx >= len(m.config.Event.Presets) {
+						return nil
+					}
+					return m.applyEventsPreset(m.config.Event.Presets[presetIdx])
 				case nomad.LogsPage:
-					m.logline = selectedPageRow.Row
+					_, m.logline = nomad.LogLineFromKey(selectedPageRow.Key)
 				case nomad.AllocAdminPage:
 					m.adminAction = nomad.KeyToAdminAction(selectedPageRow.Key)
 				case nomad.AllocAdminConfirmPage:
 					if selectedPageRow.Key == constants.ConfirmationKey {
 						cmds = append(
 							cmds,
-							nomad.GetCmdForAllocAdminAction(m.client, m.adminAction, m.taskName, m.alloc.Name, m.alloc.ID),
+							nomad.GetCmdForAllocAdminAction(m.client, m.adminAction, m.taskName, m.alloc.Name, m.alloc.ID, m.config.ReadOnly),
 						)
 					} else {
 						backPage := m.currentPage.Backward(m.inJobsMode)
@@ -468,7 +971,7 @@ func (m *Model) handleKeyMsg(msg tea.KeyMsg) tea.Cmd {
 						cmds = append(
 							cmds,
 							nomad.GetCmdForJobAdminAction(
-								m.client, m.adminAction, m.jobID, m.jobNamespace),
+								m.client, m.adminAction, m.jobID, m.jobNamespace, m.config.ReadOnly),
 						)
 					} else {
 						backPage := m.currentPage.Backward(m.inJobsMode)
@@ -498,11 +1001,18 @@ func (m *Model) handleKeyMsg(msg tea.KeyMsg) tea.Cmd {
 		case key.Matches(msg, keymap.KeyMap.Back):
 			if !m.currentPageFilterApplied() {
 				switch m.currentPage {
-				case nomad.ExecPage:
+				case nomad.ExecPage, nomad.EventsTopicsPage, nomad.EventsJQQueryPage, nomad.EventsPresetNamePage, nomad.ExecAllPage, nomad.CopyPage:
 					m.getCurrentPageModel().SetDoesNeedNewInput()
 				}
 
 				backPage := m.currentPage.Backward(m.inJobsMode)
+				if backPage == m.currentPage && m.currentPage.EditsEvents() {
+					backPage = m.eventsEditOrigin
+				} else if m.currentPage == nomad.EventsSummaryPage {
+					backPage = m.eventsSummaryOrigin
+				} else if m.currentPage == nomad.ExecSessionsPage {
+					backPage = m.execSessionsOrigin
+				}
 				if backPage != m.currentPage {
 					m.setPage(backPage)
 					cmds = append(cmds, m.getCurrentPageCmd())
@@ -516,7 +1026,7 @@ func (m *Model) handleKeyMsg(msg tea.KeyMsg) tea.Cmd {
 				return m.getCurrentPageCmd()
 			}
 		}
-		if key.Matches(msg, keymap.KeyMap.Exec) {
+		if key.Matches(msg, keymap.KeyMap.Exec) && !m.config.ReadOnly {
 			if selectedPageRow, err := m.getCurrentPageModel().GetSelectedPageRow(); err == nil {
 				if m.currentPage.ShowsTasks() {
 					taskInfo, err := nomad.TaskInfoFromKey(selectedPageRow.Key)
@@ -533,6 +1043,80 @@ func (m *Model) handleKeyMsg(msg tea.KeyMsg) tea.Cmd {
 			}
 		}
 
+		if key.Matches(msg, keymap.KeyMap.ExecAll) && m.currentPage.ShowsTasks() && !m.config.ReadOnly {
+			if selectedPageRow, err := m.getCurrentPageModel().GetSelectedPageRow(); err == nil {
+				taskInfo, err := nomad.TaskInfoFromKey(selectedPageRow.Key)
+				if err != nil {
+					m.err = err
+					return nil
+				}
+				if taskInfo.Running {
+					m.alloc, m.taskName = taskInfo.Alloc, taskInfo.TaskName
+					m.jobID, m.jobNamespace = taskInfo.Alloc.JobID, taskInfo.Alloc.Namespace
+					m.setPage(nomad.ExecAllPage)
+					m.getCurrentPageModel().SetInputError("")
+					return m.getCurrentPageCmd()
+				}
+			}
+		}
+
+		if key.Matches(msg, keymap.KeyMap.Copy) && m.currentPage.ShowsTasks() && !m.config.ReadOnly {
+			if selectedPageRow, err := m.getCurrentPageModel().GetSelectedPageRow(); err == nil {
+				taskInfo, err := nomad.TaskInfoFromKey(selectedPageRow.Key)
+				if err != nil {
+					m.err = err
+					return nil
+				}
+				if taskInfo.Running {
+					m.alloc, m.taskName = taskInfo.Alloc, taskInfo.TaskName
+					m.setPage(nomad.CopyPage)
+					m.getCurrentPageModel().SetInputError("")
+					return m.getCurrentPageCmd()
+				}
+			}
+		}
+
+		if key.Matches(msg, keymap.KeyMap.ExecSessions) && len(m.execSessions) > 0 && m.currentPage.CanOpenExecSessions() {
+			if currentPageModel == nil || !currentPageModel.EnteringInput() {
+				m.execSessionsOrigin = m.currentPage
+				m.setPage(nomad.ExecSessionsPage)
+				return m.getCurrentPageCmd()
+			}
+		}
+
+		if m.currentPage == nomad.ExecSessionPage && !m.currentPageLoading() {
+			switch {
+			case key.Matches(msg, keymap.KeyMap.Forward):
+				if !m.execSessions[m.execSessionIdx].Exited() {
+					m.execSessionAttached = true
+					m.refreshExecSession()
+					return nil
+				}
+
+			case key.Matches(msg, keymap.KeyMap.ExecNextTab):
+				return m.showExecSession((m.execSessionIdx + 1) % len(m.execSessions))
+
+			case key.Matches(msg, keymap.KeyMap.ExecPrevTab):
+				return m.showExecSession((m.execSessionIdx + len(m.execSessions) - 1) % len(m.execSessions))
+
+			case key.Matches(msg, keymap.KeyMap.CloseExecTab):
+				return m.closeExecSession(m.execSessionIdx)
+			}
+		}
+
+		if key.Matches(msg, keymap.KeyMap.CloseExecTab) && m.currentPage == nomad.ExecSessionsPage {
+			if selectedPageRow, err := m.getCurrentPageModel().GetSelectedPageRow(); err == nil {
+				if sessionIdx, err := strconv.Atoi(selectedPageRow.Key); err == nil && sessionIdx < len(m.execSessions) {
+					return m.closeExecSession(sessionIdx)
+				}
+			}
+		}
+
+		if key.Matches(msg, keymap.KeyMap.CombinedOutput) && m.currentPage == nomad.ExecAllResultsPage && !m.currentPageLoading() {
+			m.setPage(nomad.ExecAllCombinedPage)
+			return m.getCurrentPageCmd()
+		}
+
 		if key.Matches(msg, keymap.KeyMap.Stats) {
 			if selectedPageRow, err := m.getCurrentPageModel().GetSelectedPageRow(); err == nil {
 				if m.currentPage.ShowsTasks() {
@@ -622,7 +1206,13 @@ func (m *Model) handleKeyMsg(msg tea.KeyMsg) tea.Cmd {
 			return m.getCurrentPageCmd()
 		}
 
-		if key.Matches(msg, keymap.KeyMap.AdminMenu) && m.currentPage.HasAdminMenu() {
+		if key.Matches(msg, keymap.KeyMap.Alerts) && m.currentPage.CanBeFirstPage() {
+			m.unseenAlerts = 0
+			m.setPage(nomad.AlertsPage)
+			return m.getCurrentPageCmd()
+		}
+
+		if key.Matches(msg, keymap.KeyMap.AdminMenu) && m.currentPage.HasAdminMenu() && !m.config.ReadOnly {
 			if selectedPageRow, err := m.getCurrentPageModel().GetSelectedPageRow(); err == nil {
 				// Get task info from the currently selected row
 
@@ -665,6 +1255,92 @@ func (m *Model) handleKeyMsg(msg tea.KeyMsg) tea.Cmd {
 					m.getCurrentPageModel().SetLoading(true)
 					return m.getCurrentPageCmd()
 				}
+
+			case key.Matches(msg, keymap.KeyMap.StructuredLogs):
+				if !m.currentPageLoading() {
+					m.structuredLogs = !m.structuredLogs
+					m.getCurrentPageModel().SetLoading(true)
+					return m.getCurrentPageCmd()
+				}
+
+			case key.Matches(msg, keymap.KeyMap.LogLevelFilter):
+				m.warnLogsOnly = !m.warnLogsOnly
+				m.setLogRowVisibility()
+				return nil
+
+			case key.Matches(msg, keymap.KeyMap.NextError):
+				m.getCurrentPageModel().SelectNextMatchingRow(nomad.LogRowAtLeast(nomad.ErrorLogLevel), true)
+				return nil
+
+			case key.Matches(msg, keymap.KeyMap.PrevError):
+				m.getCurrentPageModel().SelectNextMatchingRow(nomad.LogRowAtLeast(nomad.ErrorLogLevel), false)
+				return nil
+
+			case key.Matches(msg, keymap.KeyMap.Record):
+				if m.logRecorder != nil {
+					return m.showRecordingStoppedToast(m.stopRecordingLogs())
+				}
+				if !m.config.Log.Tail {
+					newToast := toast.New("Error: recording requires following logs, see the log-tail option")
+					m.getCurrentPageModel().SetToast(newToast, style.ErrorToast)
+					return tea.Tick(newToast.Timeout, func(t time.Time) tea.Msg { return toast.TimeoutMsg{ID: newToast.ID} })
+				}
+				if !m.currentPageLoading() {
+					return m.getCurrentPageModel().OpenViewportRecordDialog()
+				}
+			}
+		}
+
+		if key.Matches(msg, keymap.KeyMap.Record) && m.currentPage.StreamsEvents() {
+			if m.eventsRecorder != nil {
+				return m.showRecordingStoppedToast(m.stopRecordingEvents())
+			}
+			if !m.currentPageLoading() {
+				return m.getCurrentPageModel().OpenViewportRecordDialog()
+			}
+		}
+
+		if m.currentPage.StreamsEvents() {
+			switch {
+			case key.Matches(msg, keymap.KeyMap.EditEvents):
+				m.eventsEditOrigin = m.currentPage
+				return m.setEventsInputPage(nomad.EventsTopicsPage, nomad.FormatTopics(m.eventsTopics(m.currentPage)))
+
+			case key.Matches(msg, keymap.KeyMap.EventsPresets):
+				m.eventsEditOrigin = m.currentPage
+				m.setPage(nomad.EventsPresetsPage)
+				return m.getCurrentPageCmd()
+
+			case key.Matches(msg, keymap.KeyMap.EventsSummary):
+				if !m.currentPageLoading() {
+					return m.openEventsSummary()
+				}
+			}
+		}
+
+		if key.Matches(msg, keymap.KeyMap.NewPreset) && m.currentPage == nomad.EventsPresetsPage && !m.config.ReadOnly {
+			return m.setEventsInputPage(nomad.EventsPresetNamePage, "")
+		}
+
+		if m.currentPage == nomad.ReplayEventsPage && !m.currentPageLoading() {
+			switch {
+			case key.Matches(msg, keymap.KeyMap.ReplayPause):
+				m.eventsReplay.paused = !m.eventsReplay.paused
+				return m.eventsReplay.nextTick()
+
+			case key.Matches(msg, keymap.KeyMap.ReplayFaster):
+				m.eventsReplay.changeSpeed(true)
+				return m.eventsReplay.nextTick()
+
+			case key.Matches(msg, keymap.KeyMap.ReplaySlower):
+				m.eventsReplay.changeSpeed(false)
+				return m.eventsReplay.nextTick()
+
+			case key.Matches(msg, keymap.KeyMap.ReplaySeekBack):
+				return m.seekEventsReplay(-replaySeekDuration)
+
+			case key.Matches(msg, keymap.KeyMap.ReplaySeekAhead):
+				return m.seekEventsReplay(replaySeekDuration)
 			}
 		}
 	}
@@ -674,6 +1350,16 @@ func (m *Model) handleKeyMsg(msg tea.KeyMsg) tea.Cmd {
 
 func (m *Model) setPage(page nomad.Page) {
 	m.getCurrentPageModel().HideToast()
+	if m.currentPage.StreamsEvents() && page != m.currentPage {
+		m.cancelEventsStream()
+		m.eventsReconnectAttempt = 0
+		if m.eventsRecorder != nil {
+			_, _ = m.stopRecordingEvents()
+		}
+	}
+	if m.currentPage.SummarizesEvents() && !page.SummarizesEvents() {
+		m.cancelEventsSummaryStream()
+	}
 	m.currentPage = page
 	m.getCurrentPageModel().SetFilterPrefix(m.getFilterPrefix(page))
 	if page.DoesLoad() {
@@ -688,10 +1374,492 @@ func (m *Model) getCurrentPageModel() *page.Model {
 }
 
 func (m *Model) updateKeyHelp() {
-	newKeyHelp := nomad.GetPageKeyHelp(m.currentPage, m.currentPageFilterFocused(), m.currentPageFilterApplied(), m.currentPageViewportSaving(), m.logType, m.compact, m.inJobsMode)
+	newKeyHelp := nomad.GetPageKeyHelp(m.currentPage, m.currentPageFilterFocused(), m.currentPageFilterApplied(), m.currentPageViewportSaving(), m.logType, m.structuredLogs, m.warnLogsOnly, m.currentPageRecording(), m.compact, m.inJobsMode, len(m.execSessions) > 0, m.execSessionAttached, m.config.ReadOnly)
 	m.header.SetKeyHelp(newKeyHelp)
 }
 
+func (m *Model) updateHeaderStatus() {
+	var statuses []string
+	if m.logRecorder != nil {
+		recordingStatus := fmt.Sprintf("● REC %s", m.logRecorder.Path())
+		if rotations := m.logRecorder.Rotations(); rotations > 0 {
+			recordingStatus += fmt.Sprintf(" (rotated %d)", rotations)
+		}
+		statuses = append(statuses, recordingStatus)
+	}
+	if m.eventsRecorder != nil {
+		statuses = append(statuses, fmt.Sprintf("● REC events %s", m.eventsRecorder.Path()))
+	}
+	if (m.currentPage == nomad.ReplayEventsPage || m.currentPage == nomad.ReplayEventPage) && m.eventsReplay.loaded() {
+		statuses = append(statuses, m.eventsReplay.status())
+	}
+	if m.eventsReconnectAttempt > 0 {
+		statuses = append(statuses, fmt.Sprintf("Events stream closed, reconnecting (attempt %d)", m.eventsReconnectAttempt))
+	}
+	if m.eventsSummaryReconnectAttempt > 0 {
+		statuses = append(statuses, fmt.Sprintf("Events summary stream closed, reconnecting (attempt %d)", m.eventsSummaryReconnectAttempt))
+	}
+	if m.copyProgress != nil {
+		statuses = append(statuses, fmt.Sprintf("Copying %s: %s", m.copyDescription, m.copyProgress))
+	}
+	if m.unseenAlerts > 0 {
+		statuses = append(statuses, fmt.Sprintf("%d new alerts", m.unseenAlerts))
+	}
+	if m.alertsReconnectAttempt > 0 {
+		statuses = append(statuses, fmt.Sprintf("Alerts stream closed, reconnecting (attempt %d)", m.alertsReconnectAttempt))
+	}
+	status := strings.Join(statuses, "  ")
+	if m.initialized && status != m.header.Status() {
+		// the header height can change with its status, which changes the space left for pages
+		prevHeight := m.header.ViewHeight()
+		m.header.SetStatus(status)
+		if m.header.ViewHeight() != prevHeight {
+			m.setPageWindowSize()
+		}
+	}
+}
+
+func (m *Model) cancelEventsStream() {
+	if m.eventsStream.Cancel != nil {
+		m.eventsStream.Cancel()
+	}
+}
+
+func (m Model) getEventsJQQuery() *gojq.Code {
+	if m.currentPage == nomad.AllocEventsPage || (m.currentPage.SummarizesEvents() && m.eventsSummaryOrigin == nomad.AllocEventsPage) {
+		return m.config.Event.AllocJQQuery
+	}
+	return m.config.Event.JQQuery
+}
+
+// setEventsInputPage goes to one of the input pages editing events, starting with value as the input
+func (m *Model) setEventsInputPage(p nomad.Page, value string) tea.Cmd {
+	m.setPage(p)
+	m.getCurrentPageModel().SetInputValue(value)
+	m.getCurrentPageModel().SetInputError("")
+	m.getCurrentPageModel().SetDoesNeedNewInput()
+	return m.getCurrentPageCmd()
+}
+
+// eventsTopics are the topics of an events page, see EventConfig
+func (m Model) eventsTopics(p nomad.Page) nomad.Topics {
+	switch p {
+	case nomad.JobEventsPage:
+		return m.config.Event.JobTopics
+	case nomad.AllocEventsPage:
+		return m.config.Event.AllocTopics
+	}
+	return m.config.Event.Topics
+}
+
+// editedEventsJQQueryString is the jq query of the events page being edited
+func (m Model) editedEventsJQQueryString() string {
+	if m.eventsEditOrigin == nomad.AllocEventsPage {
+		return m.config.Event.AllocJQQueryString
+	}
+	return m.config.Event.JQQueryString
+}
+
+// applyEventsQuery changes the topics and jq query of the events page being edited, and goes back to it
+func (m *Model) applyEventsQuery(topics nomad.Topics, query string, code *gojq.Code) tea.Cmd {
+	switch m.eventsEditOrigin {
+	case nomad.JobEventsPage:
+		m.config.Event.JobTopics = topics
+	case nomad.AllocEventsPage:
+		m.config.Event.AllocTopics = topics
+	default:
+		m.config.Event.Topics = topics
+	}
+	if m.eventsEditOrigin == nomad.AllocEventsPage {
+		m.config.Event.AllocJQQuery, m.config.Event.AllocJQQueryString = code, query
+	} else {
+		m.config.Event.JQQuery, m.config.Event.JQQueryString = code, query
+	}
+	m.setPage(m.eventsEditOrigin)
+	return m.getCurrentPageCmd()
+}
+
+func (m *Model) applyEventsPreset(preset nomad.EventsPreset) tea.Cmd {
+	topics, err := nomad.ParseTopics(preset.Topics)
+	var code *gojq.Code
+	if err == nil {
+		code, err = nomad.CompileJQQuery(preset.JQQuery)
+	}
+	if err != nil {
+		newToast := toast.New(fmt.Sprintf("Error: preset %s: %s", preset.Name, err))
+		m.getCurrentPageModel().SetToast(newToast, style.ErrorToast)
+		return tea.Tick(newToast.Timeout, func(t time.Time) tea.Msg { return toast.TimeoutMsg{ID: newToast.ID} })
+	}
+	return m.applyEventsQuery(topics, preset.JQQuery, code)
+}
+
+// saveEventsPreset saves the current topics and jq query of the events page being edited to the config file
+func (m *Model) saveEventsPreset(name string) tea.Cmd {
+	preset := nomad.EventsPreset{
+		Name:    name,
+		Topics:  nomad.FormatTopics(m.eventsTopics(m.eventsEditOrigin)),
+		JQQuery: m.editedEventsJQQueryString(),
+	}
+	if err := fileio.AppendToYAMLList(m.config.ConfigFilePath, constants.EventPresetsConfigKey, preset); err != nil {
+		m.getCurrentPageModel().SetInputError(fmt.Sprintf("Error saving preset to %s: %s", m.config.ConfigFilePath, err))
+		return nil
+	}
+	m.config.Event.Presets = append(m.config.Event.Presets, preset)
+
+	m.getCurrentPageModel().SetDoesNeedNewInput()
+	m.setPage(nomad.EventsPresetsPage)
+	newToast := toast.New(fmt.Sprintf("Saved preset %s to %s", name, m.config.ConfigFilePath))
+	m.getCurrentPageModel().SetToast(newToast, style.SuccessToast)
+	return tea.Batch(
+		m.getCurrentPageCmd(),
+		tea.Tick(newToast.Timeout, func(t time.Time) tea.Msg { return toast.TimeoutMsg{ID: newToast.ID} }),
+	)
+}
+
+// openEventsSummary goes to a summary of the events of the current events page, counted from now on
+func (m *Model) openEventsSummary() tea.Cmd {
+	m.eventsSummaryOrigin = m.currentPage
+	m.eventsSummary = nomad.NewEventsSummary(m.config.Event.SummaryJQQuery)
+	m.eventsGroup = ""
+	// the summary follows its own stream so it keeps counting while drilling into its groups
+	m.eventsSummaryStream = nomad.EventsStream{
+		ID:        nextStreamID(),
+		Topics:    m.eventsStream.Topics,
+		Namespace: m.eventsStream.Namespace,
+	}
+	m.setPage(nomad.EventsSummaryPage)
+	m.eventsSummaryTickID++
+	return tea.Batch(
+		m.getCurrentPageCmd(),
+		nomad.ResumeEventsStream(m.client, m.eventsSummaryStream),
+		m.eventsSummaryTick(),
+	)
+}
+
+func (m *Model) cancelEventsSummaryStream() {
+	if m.eventsSummaryStream.Cancel != nil {
+		m.eventsSummaryStream.Cancel()
+	}
+	m.eventsSummaryStream = nomad.EventsStream{}
+	m.eventsSummaryReconnectAttempt = 0
+}
+
+func (m Model) eventsSummaryTick() tea.Cmd {
+	tickID := m.eventsSummaryTickID
+	return tea.Tick(time.Second, func(t time.Time) tea.Msg { return nomad.EventsSummaryTickMsg{ID: tickID} })
+}
+
+// refreshEventsSummary re-renders the summary, keeping the same group selected as the rows are re-sorted
+func (m *Model) refreshEventsSummary() {
+	selected, err := m.getCurrentPageModel().GetSelectedPageRow()
+	tableHeader, allPageRows := m.eventsSummary.AsTable(time.Now())
+	m.getCurrentPageModel().SetHeader(tableHeader)
+	m.getCurrentPageModel().SetAllPageRows(allPageRows)
+	if err == nil {
+		m.getCurrentPageModel().SelectRowByKey(selected.Key)
+	}
+}
+
+// startExecSession runs input as a command in an embedded exec session in a new tab, attached to it
+func (m *Model) startExecSession(input string) tea.Cmd {
+	command, err := nomad.SplitCommand(input)
+	if err != nil {
+		m.getCurrentPageModel().SetInputError(err.Error())
+		return nil
+	}
+	width, height := m.execTerminalSize()
+	s, err := nomad.StartExecSession(m.client, nextStreamID(), m.alloc.ID, m.alloc.Name, m.taskName, command, width, height, m.config.Exec.Scrollback, m.config.Exec.RecordDir, m.config.ReadOnly)
+	if err != nil {
+		m.getCurrentPageModel().SetInputError(err.Error())
+		return nil
+	}
+	m.getCurrentPageModel().SetDoesNeedNewInput()
+	m.execSessions = append(m.execSessions, s)
+	m.execSessionIdx = len(m.execSessions) - 1
+	m.execSessionAttached = true
+	m.execSessionsOrigin = m.currentPage.Backward(m.inJobsMode)
+	m.setPage(nomad.ExecSessionPage)
+	return tea.Batch(m.getCurrentPageCmd(), nomad.ReadExecSessionNextUpdate(s))
+}
+
+// execSessionIndex is the index in execSessions of the session with the id, or -1 if its tab was closed
+func (m Model) execSessionIndex(id int) int {
+	for i, s := range m.execSessions {
+		if s.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// execTerminalSize is the size of the terminal of embedded exec sessions that fits in the exec session page
+func (m Model) execTerminalSize() (int, int) {
+	return max(1, m.width), max(1, m.getPageHeight()-execSessionChromeHeight)
+}
+
+// refreshExecSession re-renders the exec session page from the terminal of the session shown
+func (m *Model) refreshExecSession() {
+	tableHeader, allPageRows := nomad.ExecSessionAsRows(m.execSessions, m.execSessionIdx, m.execSessionAttached)
+	m.getCurrentPageModel().SetHeader(tableHeader)
+	m.getCurrentPageModel().SetAllPageRows(allPageRows)
+	if m.execSessionAttached {
+		m.getCurrentPageModel().ScrollViewportToBottom()
+	}
+}
+
+// showExecSession switches the exec session page to the tab of another session, detached from it
+func (m *Model) showExecSession(idx int) tea.Cmd {
+	m.execSessionIdx = idx
+	m.execSessionAttached = false
+	m.getCurrentPageModel().SetFilterPrefix(m.getFilterPrefix(m.currentPage))
+	m.refreshExecSession()
+	return nil
+}
+
+// closeExecSession ends a session and closes its tab, leaving the exec session pages if it was the last one
+func (m *Model) closeExecSession(idx int) tea.Cmd {
+	m.execSessions[idx].Close()
+	m.execSessions = append(m.execSessions[:idx:idx], m.execSessions[idx+1:]...)
+	m.execSessionAttached = false
+	if len(m.execSessions) == 0 {
+		m.execSessionIdx = 0
+		m.setPage(m.execSessionsOrigin)
+		return m.getCurrentPageCmd()
+	}
+	if idx < m.execSessionIdx || m.execSessionIdx >= len(m.execSessions) {
+		m.execSessionIdx--
+	}
+	if m.currentPage == nomad.ExecSessionPage {
+		return m.showExecSession(m.execSessionIdx)
+	}
+	return m.getCurrentPageCmd()
+}
+
+// handleExecSessionClosed notes how the session exited at the end of its terminal and in a toast. Its tab stays open
+// to look through its output until closed.
+func (m *Model) handleExecSessionClosed(idx int, msg nomad.ExecSessionClosedMsg) tea.Cmd {
+	s := m.execSessions[idx]
+	toastMsg := fmt.Sprintf("Exec session in %s exited with code %d", s.Task, msg.ExitCode)
+	toastStyle := style.SuccessToast
+	if msg.Err != nil {
+		_, _ = s.Terminal.Write([]byte(fmt.Sprintf("\r\n[exec error: %s]", msg.Err)))
+		toastMsg = fmt.Sprintf("Error: exec session in %s: %s", s.Task, msg.Err)
+		toastStyle = style.ErrorToast
+	} else {
+		_, _ = s.Terminal.Write([]byte(fmt.Sprintf("\r\n[exited with code %d]", msg.ExitCode)))
+	}
+	if s.RecordPath != "" {
+		toastMsg += fmt.Sprintf(", recorded to %s", s.RecordPath)
+	}
+
+	if idx == m.execSessionIdx {
+		m.execSessionAttached = false
+	}
+	var cmds []tea.Cmd
+	if m.currentPage == nomad.ExecSessionPage && !m.currentPageLoading() {
+		m.refreshExecSession()
+	} else if m.currentPage == nomad.ExecSessionsPage {
+		cmds = append(cmds, m.getCurrentPageCmd())
+	}
+	newToast := toast.New(toastMsg)
+	m.getCurrentPageModel().SetToast(newToast, toastStyle)
+	cmds = append(cmds, tea.Tick(newToast.Timeout, func(t time.Time) tea.Msg { return toast.TimeoutMsg{ID: newToast.ID} }))
+	return tea.Batch(cmds...)
+}
+
+// handleCopyComplete shows how the copy running in the background went
+func (m *Model) handleCopyComplete(msg nomad.CopyCompleteMsg) tea.Cmd {
+	toastMsg := fmt.Sprintf("%s: %s", m.copyDescription, msg.Result.Summary())
+	toastStyle := style.SuccessToast
+	if msg.Err != nil {
+		toastMsg = fmt.Sprintf("Error copying %s: %s", m.copyDescription, msg.Err)
+		toastStyle = style.ErrorToast
+	}
+	m.copyProgress, m.copyDescription = nil, ""
+	newToast := toast.New(toastMsg)
+	m.getCurrentPageModel().SetToast(newToast, toastStyle)
+	return tea.Tick(newToast.Timeout, func(t time.Time) tea.Msg { return toast.TimeoutMsg{ID: newToast.ID} })
+}
+
+// openAlertsStream starts following events in the background to check alert rules against, if there are any
+func (m *Model) openAlertsStream() tea.Cmd {
+	if len(m.config.Alert.Rules) == 0 || m.config.Event.ReplayPath != "" {
+		return nil
+	}
+	m.alertsStream = nomad.EventsStream{
+		ID:        nextStreamID(),
+		Topics:    m.config.Alert.Topics,
+		Namespace: m.config.Event.Namespace,
+	}
+	// a stream that hasn't received any events resumes from the latest ones
+	return nomad.ResumeEventsStream(m.client, m.alertsStream)
+}
+
+// refreshToken gets a new token from the token source if err is from nomad no longer accepting the current one, nil if
+// there's no token source or it was refreshed too recently. reloadPage reloads the current page with the new token, for
+// errors from its own fetch or stream.
+func (m *Model) refreshToken(err error, reloadPage bool) tea.Cmd {
+	if m.config.TokenSource == nil || !nomad.IsACLTokenError(err) || time.Since(m.tokenRefreshedAt) <= minTokenRefreshInterval {
+		return nil
+	}
+	m.tokenRefreshedAt = time.Now()
+	m.reloadPageOnTokenRefresh = reloadPage
+	return nomad.RefreshToken(m.config.TokenSource)
+}
+
+// fireAlerts adds alerts to the history on the alerts page and notifies of them as their rules configure
+func (m *Model) fireAlerts(alerts []nomad.Alert) tea.Cmd {
+	if len(alerts) == 0 {
+		return nil
+	}
+	m.alerts = append(m.alerts, alerts...)
+	if len(m.alerts) > maxAlerts {
+		m.alerts = m.alerts[len(m.alerts)-maxAlerts:]
+	}
+	if m.currentPage == nomad.AlertsPage {
+		scrollDown := m.getCurrentPageModel().ViewportSelectionAtBottom()
+		tableHeader, allPageRows := nomad.AlertsAsTable(m.alerts)
+		m.getCurrentPageModel().SetHeader(tableHeader)
+		m.getCurrentPageModel().SetAllPageRows(allPageRows)
+		if scrollDown {
+			m.getCurrentPageModel().ScrollViewportToBottom()
+		}
+	} else {
+		m.unseenAlerts += len(alerts)
+	}
+
+	var cmds []tea.Cmd
+	var notification string
+	var toastSummaries []string
+	for _, a := range alerts {
+		notification += nomad.AlertNotification(a)
+		for _, n := range a.Notify {
+			if n == nomad.AlertNotifyToast {
+				toastSummaries = append(toastSummaries, a.Summary())
+			}
+		}
+	}
+	if notification != "" && m.config.OutputIsTerminal {
+		// written by the view rather than directly, as that would interleave with the program's rendering
+		m.notification += notification
+		cmds = append(cmds, tea.Tick(alertNotificationFrame, func(time.Time) tea.Msg { return nomad.AlertNotificationWrittenMsg{} }))
+	}
+	if len(toastSummaries) > 0 {
+		toastMsg := fmt.Sprintf("Alert %s", toastSummaries[0])
+		if len(toastSummaries) > 1 {
+			toastMsg += fmt.Sprintf(" (+%d more)", len(toastSummaries)-1)
+		}
+		newToast := toast.New(toastMsg)
+		m.getCurrentPageModel().SetToast(newToast, style.ErrorToast)
+		cmds = append(cmds, tea.Tick(newToast.Timeout, func(t time.Time) tea.Msg { return toast.TimeoutMsg{ID: newToast.ID} }))
+	}
+	return tea.Batch(cmds...)
+}
+
+// currentPageRecording is true if the stream shown on the current page is being recorded
+func (m Model) currentPageRecording() bool {
+	if m.currentPage == nomad.LogsPage {
+		return m.logRecorder != nil
+	}
+	return m.currentPage.StreamsEvents() && m.eventsRecorder != nil
+}
+
+func (m *Model) showRecordingStoppedToast(recordPath string, err error) tea.Cmd {
+	toastMsg := fmt.Sprintf("Stopped recording to %s", recordPath)
+	toastStyle := style.SuccessToast
+	if err != nil {
+		toastMsg = fmt.Sprintf("Error: stopped recording to %s: %s", recordPath, err)
+		toastStyle = style.ErrorToast
+	}
+	newToast := toast.New(toastMsg)
+	m.getCurrentPageModel().SetToast(newToast, toastStyle)
+	return tea.Tick(newToast.Timeout, func(t time.Time) tea.Msg { return toast.TimeoutMsg{ID: newToast.ID} })
+}
+
+func (m *Model) recordEvents(raw string) error {
+	line, err := nomad.RecordedEventsLine(raw, time.Now())
+	if err != nil {
+		return err
+	}
+	return m.eventsRecorder.Write(line)
+}
+
+// stopRecordingEvents closes the events recorder, returning the path that was recorded to
+func (m *Model) stopRecordingEvents() (string, error) {
+	recordPath := m.eventsRecorder.Path()
+	err := m.eventsRecorder.Close()
+	m.eventsRecorder = nil
+	return recordPath, err
+}
+
+// seekEventsReplay shows the replayed events as they were recorded d from the current position
+func (m *Model) seekEventsReplay(d time.Duration) tea.Cmd {
+	m.eventsReplay.replayed = m.eventsReplay.seek(d)
+	replayed := m.eventsReplay.recorded[:m.eventsReplay.replayed]
+	m.getCurrentPageModel().SetAllPageRows(nomad.RecordedEventsAsRows(replayed, m.config.Event.JQQuery))
+	m.getCurrentPageModel().SetViewportSelectionToBottom()
+	return m.eventsReplay.nextTick()
+}
+
+// stopRecordingLogs closes the log recorder, returning the path that was recorded to
+func (m *Model) stopRecordingLogs() (string, error) {
+	recordPath := m.logRecorder.Path()
+	err := m.logRecorder.Close()
+	m.logRecorder = nil
+	m.recordingLogsStream = nomad.LogsStream{}
+	return recordPath, err
+}
+
+// appendLogs adds streamed log data to the logs page. Structured and grouped logs go through logTable, as new
+// lines can widen the columns or fold into the last entry, and the rest are appended as they are.
+func (m *Model) appendLogs(value string) {
+	scrollDown := m.getCurrentPageModel().ViewportSelectionAtBottom()
+	if len(m.logsStream.Fields) > 0 || m.logsStream.GroupContinuation != nil {
+		m.logTable.Append(value)
+		m.getCurrentPageModel().SetHeader(m.logTable.Header())
+		// the page drops its rows beyond the maximum, keeping the selection on the same rows
+		m.getCurrentPageModel().SetAllPageRows(m.logTable.Rows())
+		m.logTable.DropOldest(m.config.Log.MaxRows)
+	} else {
+		newLines := strings.Split(value, "\n")
+		finished := strings.HasSuffix(value, "\n")
+		if finished {
+			newLines = newLines[:len(newLines)-1]
+		}
+
+		// finish with the last log line if necessary
+		if !m.lastLogFinished && len(newLines) > 0 {
+			m.lastLogLine += newLines[0]
+			finishedRow := nomad.LogLineAsRow(m.lastLogLine)
+			finishedRow.Row = newLines[0]
+			m.getCurrentPageModel().AppendToViewport([]page.Row{finishedRow}, false)
+			newLines = newLines[1:]
+		}
+
+		// append all the new log rows in this chunk to the viewport at once
+		var allRows []page.Row
+		for _, logLine := range newLines {
+			allRows = append(allRows, nomad.LogLineAsRow(logLine))
+		}
+		if len(newLines) > 0 {
+			m.lastLogLine = newLines[len(newLines)-1]
+		}
+		m.getCurrentPageModel().AppendToViewport(allRows, true)
+		m.lastLogFinished = finished
+	}
+	if scrollDown {
+		m.getCurrentPageModel().ScrollViewportToBottom()
+	}
+}
+
+func (m *Model) setLogRowVisibility() {
+	if m.warnLogsOnly {
+		m.pageModels[nomad.LogsPage].SetRowVisible(nomad.LogRowAtLeast(nomad.WarnLogLevel))
+	} else {
+		m.pageModels[nomad.LogsPage].SetRowVisible(nil)
+	}
+}
+
 func (m *Model) toggleCompact() {
 	m.compact = !m.compact
 	m.header.ToggleCompact()
@@ -711,19 +1879,33 @@ func (m Model) getCurrentPageCmd() tea.Cmd {
 	case nomad.JobSpecPage:
 		return nomad.FetchJobSpec(m.client, m.jobID, m.jobNamespace)
 	case nomad.JobEventsPage:
-		return nomad.FetchEventsStream(m.client, nomad.TopicsForJob(m.config.Event.Topics, m.jobID), m.jobNamespace, nomad.JobEventsPage)
+		return nomad.FetchEventsStream(m.client, nomad.TopicsForJob(m.config.Event.JobTopics, m.jobID), m.jobNamespace, nomad.JobEventsPage)
 	case nomad.JobEventPage:
 		return nomad.PrettifyLine(m.event, nomad.JobEventPage)
 	case nomad.JobMetaPage:
 		return nomad.FetchJobMeta(m.client, m.jobID, m.jobNamespace)
 	case nomad.AllocEventsPage:
-		return nomad.FetchEventsStream(m.client, nomad.TopicsForAlloc(m.config.Event.Topics, m.alloc.ID), m.jobNamespace, nomad.AllocEventsPage)
+		return nomad.FetchEventsStream(m.client, nomad.TopicsForAlloc(m.config.Event.AllocTopics, m.alloc.ID), m.jobNamespace, nomad.AllocEventsPage)
 	case nomad.AllocEventPage:
 		return nomad.PrettifyLine(m.event, nomad.AllocEventPage)
 	case nomad.AllEventsPage:
 		return nomad.FetchEventsStream(m.client, m.config.Event.Topics, m.config.Event.Namespace, nomad.AllEventsPage)
 	case nomad.AllEventPage:
 		return nomad.PrettifyLine(m.event, nomad.AllEventPage)
+	case nomad.ReplayEventsPage:
+		return nomad.FetchEventsReplay(m.config.Event.ReplayPath, m.eventsReplay.replayed, m.config.Event.JQQuery)
+	case nomad.ReplayEventPage:
+		return nomad.PrettifyLine(m.event, nomad.ReplayEventPage)
+	case nomad.AlertsPage:
+		return nomad.FetchAlerts(m.alerts)
+	case nomad.AlertPage:
+		return nomad.PrettifyLine(m.event, nomad.AlertPage)
+	case nomad.EventsSummaryPage:
+		return nomad.FetchEventsSummary(m.eventsSummary)
+	case nomad.EventsGroupPage:
+		return nomad.FetchEventsGroup(m.eventsSummary, m.eventsGroup, m.getEventsJQQuery())
+	case nomad.EventsGroupEventPage:
+		return nomad.PrettifyLine(m.event, nomad.EventsGroupEventPage)
 	case nomad.JobTasksPage:
 		return nomad.FetchTasksForJob(m.client, m.jobID, m.jobNamespace, m.config.JobTaskColumns)
 	case nomad.ExecPage:
@@ -731,6 +1913,24 @@ func (m Model) getCurrentPageCmd() tea.Cmd {
 			// this does no async work, just moves to request the command input
 			return nomad.PageLoadedMsg{Page: nomad.ExecPage, TableHeader: []string{}, AllPageRows: []page.Row{}}
 		}
+	case nomad.EventsTopicsPage, nomad.EventsJQQueryPage, nomad.EventsPresetNamePage, nomad.ExecAllPage, nomad.CopyPage:
+		p := m.currentPage
+		return func() tea.Msg {
+			// this does no async work, just moves to request the input
+			return nomad.PageLoadedMsg{Page: p, TableHeader: []string{}, AllPageRows: []page.Row{}}
+		}
+	case nomad.EventsPresetsPage:
+		return nomad.FetchEventsPresets(m.config.Event.Presets)
+	case nomad.ExecAllResultsPage:
+		return nomad.FetchExecAllResults(m.execAllResults, m.execAllErr)
+	case nomad.ExecAllResultPage:
+		return nomad.FetchExecAllOutput(m.execAllResults[m.execAllResultIdx:m.execAllResultIdx+1], false, nomad.ExecAllResultPage)
+	case nomad.ExecAllCombinedPage:
+		return nomad.FetchExecAllOutput(m.execAllResults, true, nomad.ExecAllCombinedPage)
+	case nomad.ExecSessionsPage:
+		return nomad.FetchExecSessions(m.execSessions)
+	case nomad.ExecSessionPage:
+		return nomad.FetchExecSession(m.execSessions, m.execSessionIdx, m.execSessionAttached)
 	case nomad.ExecCompletePage:
 		return func() tea.Msg {
 			// this does no async work, just shows the output of the prior exec session
@@ -747,7 +1947,11 @@ func (m Model) getCurrentPageCmd() tea.Cmd {
 	case nomad.AllocSpecPage:
 		return nomad.FetchAllocSpec(m.client, m.alloc.ID)
 	case nomad.LogsPage:
-		return nomad.FetchLogs(m.client, m.alloc, m.taskName, m.logType, m.config.Log.Offset, m.config.Log.Tail)
+		var structuredFields []string
+		if m.structuredLogs {
+			structuredFields = m.config.Log.Fields
+		}
+		return nomad.FetchLogs(m.client, m.alloc, m.taskName, m.logType, m.config.Log.Offset, m.config.Log.Tail, structuredFields, m.config.Log.PreserveColors, m.config.Log.GroupRegex)
 	case nomad.LoglinePage:
 		return nomad.PrettifyLine(m.logline, nomad.LoglinePage)
 	case nomad.StatsPage:
@@ -848,5 +2052,17 @@ func (m Model) currentPageViewportSaving() bool {
 }
 
 func (m Model) getFilterPrefix(page nomad.Page) string {
-	return page.GetFilterPrefix(m.config.Namespace, m.jobID, m.taskName, m.alloc.Name, m.alloc.ID, m.config.Event.Topics, m.config.Event.Namespace)
+	allocName, allocID := m.alloc.Name, m.alloc.ID
+	if page == nomad.ExecAllResultPage && m.execAllResultIdx < len(m.execAllResults) {
+		// the allocation of the result shown rather than the one the command was run from
+		result := m.execAllResults[m.execAllResultIdx]
+		allocName, allocID = result.AllocName, result.AllocID
+	}
+	taskName := m.taskName
+	if page == nomad.ExecSessionPage && m.execSessionIdx < len(m.execSessions) {
+		// the task of the session shown rather than the one last selected
+		s := m.execSessions[m.execSessionIdx]
+		taskName, allocName, allocID = s.Task, s.AllocName, s.AllocID
+	}
+	return page.GetFilterPrefix(m.config.Namespace, m.jobID, taskName, allocName, allocID, m.eventsTopics(page), m.config.Event.Namespace)
 }
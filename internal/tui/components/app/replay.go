@@ -0,0 +1,95 @@
+package app
+
+import (
+	"fmt"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/robinovitch61/wander/internal/tui/formatter"
+	"github.com/robinovitch61/wander/internal/tui/nomad"
+	"time"
+)
+
+// replaySpeeds are the speeds events can be replayed at, as multiples of the speed they were recorded at
+var replaySpeeds = []float64{0.25, 0.5, 1, 2, 4, 8, 16, 32, 64}
+
+const (
+	defaultReplaySpeedIdx = 2
+	replaySeekDuration    = 10 * time.Second
+)
+
+type eventsReplay struct {
+	recorded []nomad.RecordedEvents
+	// replayed is the number of recorded batches of events shown so far
+	replayed int
+	paused   bool
+	speedIdx int
+	// tickID invalidates ticks scheduled before the replay was paused, seeked or changed speed
+	tickID int
+}
+
+func newEventsReplay() eventsReplay {
+	return eventsReplay{speedIdx: defaultReplaySpeedIdx}
+}
+
+func (r eventsReplay) loaded() bool {
+	return len(r.recorded) > 0
+}
+
+func (r eventsReplay) finished() bool {
+	return r.replayed >= len(r.recorded)
+}
+
+func (r eventsReplay) speed() float64 {
+	return replaySpeeds[r.speedIdx]
+}
+
+// position is the time the replayed events were recorded up to
+func (r eventsReplay) position() time.Time {
+	if r.replayed == 0 {
+		return r.recorded[0].RecordedAt
+	}
+	return r.recorded[r.replayed-1].RecordedAt
+}
+
+// nextTick schedules replaying the next batch of events after the time between it and the last batch
+// when recorded, adjusted for speed
+func (r *eventsReplay) nextTick() tea.Cmd {
+	r.tickID++
+	if r.paused || r.finished() {
+		return nil
+	}
+	var delay time.Duration
+	if r.replayed > 0 {
+		delay = r.recorded[r.replayed].RecordedAt.Sub(r.position())
+	}
+	delay = time.Duration(float64(delay) / r.speed())
+	tickID := r.tickID
+	return tea.Tick(delay, func(t time.Time) tea.Msg { return nomad.ReplayEventsTickMsg{ID: tickID} })
+}
+
+// seek returns how many batches of events were recorded up to d from the current position
+func (r eventsReplay) seek(d time.Duration) int {
+	target := r.position().Add(d)
+	n := 0
+	for n < len(r.recorded) && !r.recorded[n].RecordedAt.After(target) {
+		n++
+	}
+	return n
+}
+
+func (r *eventsReplay) changeSpeed(faster bool) {
+	if faster && r.speedIdx < len(replaySpeeds)-1 {
+		r.speedIdx++
+	} else if !faster && r.speedIdx > 0 {
+		r.speedIdx--
+	}
+}
+
+func (r eventsReplay) status() string {
+	state := fmt.Sprintf("%gx", r.speed())
+	if r.finished() {
+		state = "finished"
+	} else if r.paused {
+		state = "paused"
+	}
+	return fmt.Sprintf("Replay at %s (%d/%d) %s", formatter.FormatTime(r.position()), r.replayed, len(r.recorded), state)
+}
@@ -2,6 +2,7 @@ package app
 
 import (
 	"github.com/hashicorp/nomad/api"
+	"os/exec"
 	"strings"
 	"sync"
 )
@@ -18,6 +19,19 @@ func nextUpdateID() int {
 	return updateID
 }
 
+var (
+	streamID    int
+	streamIDMtx sync.Mutex
+)
+
+// nextStreamID identifies a newly opened logs or events stream
+func nextStreamID() int {
+	streamIDMtx.Lock()
+	defer streamIDMtx.Unlock()
+	streamID++
+	return streamID
+}
+
 func (c Config) Client() (*api.Client, error) {
 	config := &api.Config{
 		Address:   c.URL,
@@ -52,3 +66,27 @@ func (c Config) Client() (*api.Client, error) {
 
 	return api.NewClient(config)
 }
+
+// execCommand runs wander exec with rootOpts, the options this wander was run with, and token as its nomad token. The
+// token options in rootOpts and environ are replaced, as for wander serve they're the server's, not the session's.
+func execCommand(wander string, rootOpts []string, token string, environ []string, args ...string) *exec.Cmd {
+	execArgs := []string{"exec"}
+	for _, opt := range rootOpts {
+		if !strings.HasPrefix(opt, "--token=") && !strings.HasPrefix(opt, "--token-file=") && !strings.HasPrefix(opt, "--token-command=") {
+			execArgs = append(execArgs, opt)
+		}
+	}
+	// wander exec only reads its flags, not the config file or env vars, so an empty token means no token
+	execArgs = append(execArgs, "--token="+token)
+
+	var env []string
+	for _, e := range environ {
+		if !strings.HasPrefix(e, "NOMAD_TOKEN=") {
+			env = append(env, e)
+		}
+	}
+
+	c := exec.Command(wander, append(execArgs, args...)...)
+	c.Env = env
+	return c
+}
@@ -0,0 +1,43 @@
+package app
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+const (
+	serverToken  = "11111111-1111-1111-1111-111111111111"
+	sessionToken = "22222222-2222-2222-2222-222222222222"
+)
+
+func TestExecCommandUsesSessionToken(t *testing.T) {
+	rootOpts := []string{"--addr=http://nomad:4646", "--token=" + serverToken, "--token-file=/etc/wander/token", "--token-command=cat /etc/wander/token"}
+	environ := []string{"HOME=/home/wander", "NOMAD_TOKEN=" + serverToken}
+
+	c := execCommand("wander", rootOpts, sessionToken, environ, "alloc", "--task", "task", "sh")
+
+	for _, s := range append(c.Args, c.Env...) {
+		if strings.Contains(s, serverToken) || strings.Contains(s, "/etc/wander/token") {
+			t.Errorf("exec gets the server's credentials in %q", s)
+		}
+	}
+	want := []string{"wander", "exec", "--addr=http://nomad:4646", "--token=" + sessionToken, "alloc", "--task", "task", "sh"}
+	if !slices.Equal(c.Args, want) {
+		t.Errorf("exec args are %q, want %q", c.Args, want)
+	}
+	if !slices.Equal(c.Env, []string{"HOME=/home/wander"}) {
+		t.Errorf("exec env is %q, want the environment without NOMAD_TOKEN", c.Env)
+	}
+}
+
+func TestExecCommandWithoutSessionToken(t *testing.T) {
+	c := execCommand("wander", []string{"--token=" + serverToken}, "", []string{"NOMAD_TOKEN=" + serverToken}, "alloc")
+
+	if want := []string{"wander", "exec", "--token=", "alloc"}; !slices.Equal(c.Args, want) {
+		t.Errorf("exec args are %q, want %q", c.Args, want)
+	}
+	if len(c.Env) > 0 {
+		t.Errorf("exec env is %q, want it empty", c.Env)
+	}
+}
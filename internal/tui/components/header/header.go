@@ -7,7 +7,9 @@ import (
 
 type Model struct {
 	logo, logoColor, nomadUrl, version, keyHelp string
-	compact                                     bool
+	// status is shown under the cluster url, e.g. to show that logs are being recorded
+	status  string
+	compact bool
 }
 
 func New(logo string, logoColor string, nomadUrl, version, keyHelp string) (m Model) {
@@ -21,16 +23,24 @@ func (m Model) View() string {
 	}
 	clusterUrl := style.ClusterUrl.Render(m.nomadUrl)
 	if m.compact {
-		return lipgloss.JoinHorizontal(
+		compactHeader := lipgloss.JoinHorizontal(
 			lipgloss.Center,
 			logoStyle.Padding(0).Margin(0).Render("WANDER"),
 			style.KeyHelp.Render(m.keyHelp),
 			style.Regular.Copy().Padding(0, 2, 0, 0).Render(m.version),
 			clusterUrl,
 		)
+		if m.status != "" {
+			compactHeader = lipgloss.JoinHorizontal(lipgloss.Center, compactHeader, style.HeaderStatus.Copy().PaddingLeft(2).Render(m.status))
+		}
+		return compactHeader
 	}
 	logo := logoStyle.Render(m.logo)
-	left := style.Header.Render(lipgloss.JoinVertical(lipgloss.Center, logo, m.version, clusterUrl))
+	leftLines := []string{logo, m.version, clusterUrl}
+	if m.status != "" {
+		leftLines = append(leftLines, style.HeaderStatus.Render(m.status))
+	}
+	left := style.Header.Render(lipgloss.JoinVertical(lipgloss.Center, leftLines...))
 	styledKeyHelp := style.KeyHelp.Render(m.keyHelp)
 	return lipgloss.JoinHorizontal(lipgloss.Center, left, styledKeyHelp)
 }
@@ -43,6 +53,14 @@ func (m *Model) SetKeyHelp(keyHelp string) {
 	m.keyHelp = keyHelp
 }
 
+func (m Model) Status() string {
+	return m.status
+}
+
+func (m *Model) SetStatus(status string) {
+	m.status = status
+}
+
 func (m *Model) ToggleCompact() {
 	m.compact = !m.compact
 }
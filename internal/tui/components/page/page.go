@@ -15,8 +15,10 @@ import (
 	"github.com/robinovitch61/wander/internal/tui/components/toast"
 	"github.com/robinovitch61/wander/internal/tui/components/viewport"
 	"github.com/robinovitch61/wander/internal/tui/constants"
+	"github.com/robinovitch61/wander/internal/tui/formatter"
 	"github.com/robinovitch61/wander/internal/tui/keymap"
 	"github.com/robinovitch61/wander/internal/tui/message"
+	"github.com/robinovitch61/wander/internal/tui/style"
 )
 
 type Config struct {
@@ -25,6 +27,8 @@ type Config struct {
 	SelectionEnabled, WrapText, RequestInput bool
 	CompactTableContent                      bool
 	ViewportConditionalStyle                 map[string]lipgloss.Style
+	// RowStyle optionally styles individual rows, taking precedence over ViewportConditionalStyle
+	RowStyle func(Row) (lipgloss.Style, bool)
 }
 
 type Model struct {
@@ -38,11 +42,20 @@ type Model struct {
 	loadingString string
 	loading       bool
 
+	rowStyle func(Row) (lipgloss.Style, bool)
+	// rowVisible hides rows for which it returns false, before any filter text is applied
+	rowVisible func(Row) bool
+	// maxRows is how many rows are kept, dropping the oldest ones. 0 keeps all rows.
+	maxRows int
+	// droppedRows counts rows dropped since the page last loaded
+	droppedRows int
+
 	copySavePath bool
 
 	doesRequestInput bool
 	textinput        textinput.Model
 	inputPrefix      string
+	inputError       string
 	initialized      bool
 
 	// if FilterWithContext is true, filtering doesn't remove rows, just highlights the matching text
@@ -75,6 +88,7 @@ func New(c Config, copySavePath, startFiltering, filterWithContext bool) Model {
 		filter:            pageFilter,
 		loadingString:     c.LoadingString,
 		loading:           true,
+		rowStyle:          c.RowStyle,
 		copySavePath:      copySavePath,
 		doesRequestInput:  c.RequestInput,
 		textinput:         pageTextInput,
@@ -196,6 +210,9 @@ func (m Model) View() string {
 	} else {
 		if m.EnteringInput() {
 			content = m.inputPrefix + m.textinput.View()
+			if m.inputError != "" {
+				content += "\n\n" + style.InputError.Render(m.inputError)
+			}
 		} else {
 			content = m.viewport.View()
 		}
@@ -216,6 +233,17 @@ func (m *Model) SetInputPrefix(p string) {
 	m.inputPrefix = p
 }
 
+// SetInputValue replaces the text being input, e.g. to edit an existing value
+func (m *Model) SetInputValue(v string) {
+	m.textinput.SetValue(v)
+	m.textinput.CursorEnd()
+}
+
+// SetInputError shows an error below the text being input, e.g. if it's invalid. Empty to remove it.
+func (m *Model) SetInputError(e string) {
+	m.inputError = e
+}
+
 func (m *Model) SetViewportStyle(headerStyle, contentStyle lipgloss.Style) {
 	m.viewport.HeaderStyle = headerStyle
 	m.viewport.ContentStyle = contentStyle
@@ -223,11 +251,69 @@ func (m *Model) SetViewportStyle(headerStyle, contentStyle lipgloss.Style) {
 
 func (m *Model) SetLoading(isLoading bool) {
 	m.loading = isLoading
+	if isLoading {
+		m.droppedRows = 0
+		m.viewport.SetDroppedRows(0)
+	}
 }
 
+// SetAllPageRows sets the rows of the page, dropping the oldest ones beyond the maximum, see SetMaxRows
 func (m *Model) SetAllPageRows(allPageRows []Row) {
 	m.pageData.AllRows = allPageRows
 	m.updateViewport()
+	if m.maxRows > 0 && len(allPageRows) > m.maxRows {
+		m.EvictRows(len(allPageRows) - m.maxRows)
+	}
+}
+
+// SetMaxRows bounds the memory used by pages that keep getting rows, e.g. streamed logs. 0 keeps all rows.
+func (m *Model) SetMaxRows(n int) {
+	m.maxRows = n
+}
+
+// EvictRows drops the first n rows, keeping the same rows selected and matched by the filter
+func (m *Model) EvictRows(n int) {
+	n = min(n, len(m.pageData.AllRows))
+	if n <= 0 {
+		return
+	}
+	var evictedShown, evictedMatches int
+	for _, row := range m.pageData.AllRows[:n] {
+		if m.rowVisible != nil && !m.rowVisible(row) {
+			continue
+		}
+		matches := m.filter.HasFilterText() && m.rowMatchesFilter(row)
+		if matches {
+			evictedMatches++
+		}
+		if matches || !m.filter.HasFilterText() || m.FilterWithContext {
+			evictedShown++
+		}
+	}
+	selectedContentIdx := m.viewport.SelectedContentIdx()
+
+	// copy the rows kept so the evicted ones can be garbage collected
+	m.pageData.AllRows = append([]Row(nil), m.pageData.AllRows[n:]...)
+	m.droppedRows += n
+	m.viewport.SetDroppedRows(m.droppedRows)
+	m.updateViewport()
+
+	m.viewport.SetSelectedContentIdx(selectedContentIdx - evictedShown)
+	if m.FilterWithContext && m.filter.HasFilterText() {
+		if m.pageData.CurrentFilteredContentIdx < evictedShown {
+			// the current match was evicted
+			m.pageData.FilteredSelectionNum = 0
+		} else {
+			m.pageData.FilteredSelectionNum = max(0, m.pageData.FilteredSelectionNum-evictedMatches)
+		}
+		m.pageData.CurrentFilteredContentIdx = -1
+		if numMatches := len(m.pageData.FilteredContentIdxs); numMatches > 0 {
+			m.pageData.FilteredSelectionNum = min(m.pageData.FilteredSelectionNum, numMatches-1)
+			m.pageData.CurrentFilteredContentIdx = m.pageData.FilteredContentIdxs[m.pageData.FilteredSelectionNum]
+		}
+		m.viewport.SpecialContentIdx = m.pageData.CurrentFilteredContentIdx
+		m.updateFilter()
+	}
 }
 
 func (m *Model) SetFilterPrefix(prefix string) {
@@ -269,6 +355,10 @@ func (m *Model) AppendToViewport(rows []Row, startOnNewLine bool) {
 					currentLastEntry = m.pageData.AllRows[len(m.pageData.AllRows)-1]
 				}
 				newLastEntry := Row{Key: currentLastEntry.Key, Row: currentLastEntry.Row + r.Row}
+				if r.Key != "" {
+					// the appended row's key describes the combined row
+					newLastEntry.Key = r.Key
+				}
 				newPageRows = append(allButLastEntry, newLastEntry)
 			} else {
 				newPageRows = append(newPageRows, r)
@@ -278,6 +368,46 @@ func (m *Model) AppendToViewport(rows []Row, startOnNewLine bool) {
 	m.SetAllPageRows(newPageRows)
 }
 
+// SetRowVisible sets a function that hides rows for which it returns false. nil shows all rows.
+func (m *Model) SetRowVisible(visible func(Row) bool) {
+	m.rowVisible = visible
+	m.updateViewport()
+}
+
+// SelectNextMatchingRow moves the selection to the closest row after the selected row that matches, or before
+// it if forward is false. Returns false if no row matches.
+func (m *Model) SelectNextMatchingRow(matches func(Row) bool, forward bool) bool {
+	if !m.viewport.SelectionEnabled() {
+		return false
+	}
+	step := 1
+	if !forward {
+		step = -1
+	}
+	rows := m.pageData.FilteredRows
+	for i := m.viewport.SelectedContentIdx() + step; i >= 0 && i < len(rows); i += step {
+		if matches(rows[i]) {
+			m.viewport.SetSelectedContentIdx(i)
+			return true
+		}
+	}
+	return false
+}
+
+// SelectRowByKey selects the first shown row with the key. Returns false if no shown row has it.
+func (m *Model) SelectRowByKey(key string) bool {
+	if !m.viewport.SelectionEnabled() {
+		return false
+	}
+	for i, row := range m.pageData.FilteredRows {
+		if row.Key == key {
+			m.viewport.SetSelectedContentIdx(i)
+			return true
+		}
+	}
+	return false
+}
+
 func (m *Model) SetDoesNeedNewInput() {
 	if !m.doesRequestInput {
 		return
@@ -350,6 +480,10 @@ func (m Model) FilterApplied() bool {
 	return len(m.filter.Value()) > 0
 }
 
+func (m *Model) OpenViewportRecordDialog() tea.Cmd {
+	return m.viewport.OpenRecordDialog()
+}
+
 func (m Model) ViewportSaving() bool {
 	return m.viewport.Saving()
 }
@@ -368,25 +502,44 @@ func (m *Model) updateViewport() {
 	m.viewport.SetStringToHighlight(m.filter.Value())
 	m.updateFilteredData()
 	m.viewport.SetContent(rowsToStrings(m.pageData.FilteredRows))
+	if rowStyle := m.rowStyle; rowStyle != nil {
+		filteredRows := m.pageData.FilteredRows
+		m.viewport.StyleForContentIdx = func(contentIdx int) (lipgloss.Style, bool) {
+			if contentIdx < 0 || contentIdx >= len(filteredRows) {
+				return lipgloss.Style{}, false
+			}
+			return rowStyle(filteredRows[contentIdx])
+		}
+	}
 }
 
 func (m *Model) updateFilteredData() {
+	visibleRows := m.pageData.AllRows
+	if m.rowVisible != nil {
+		visibleRows = nil
+		for _, entry := range m.pageData.AllRows {
+			if m.rowVisible(entry) {
+				visibleRows = append(visibleRows, entry)
+			}
+		}
+	}
+
 	if !m.filter.HasFilterText() {
-		m.pageData.FilteredRows = m.pageData.AllRows
+		m.pageData.FilteredRows = visibleRows
 		m.setIndexesOfFilteredRows([]int{})
 	} else if m.FilterWithContext {
-		m.pageData.FilteredRows = m.pageData.AllRows
+		m.pageData.FilteredRows = visibleRows
 		var indexesOfFilteredRows []int
-		for i, entry := range m.pageData.AllRows {
-			if strings.Contains(entry.Row, m.filter.Value()) {
+		for i, entry := range visibleRows {
+			if m.rowMatchesFilter(entry) {
 				indexesOfFilteredRows = append(indexesOfFilteredRows, i)
 			}
 		}
 		m.setIndexesOfFilteredRows(indexesOfFilteredRows)
 	} else {
 		var filteredData []Row
-		for _, entry := range m.pageData.AllRows {
-			if strings.Contains(entry.Row, m.filter.Value()) {
+		for _, entry := range visibleRows {
+			if m.rowMatchesFilter(entry) {
 				filteredData = append(filteredData, entry)
 			}
 		}
@@ -394,6 +547,15 @@ func (m *Model) updateFilteredData() {
 	}
 }
 
+// rowMatchesFilter ignores any color sequences in the row so they can't split up matching text
+func (m Model) rowMatchesFilter(row Row) bool {
+	text := row.Row
+	if strings.Contains(text, "\x1b") {
+		text = formatter.StripANSI(text)
+	}
+	return strings.Contains(text, m.filter.Value())
+}
+
 func (m *Model) updateFilter() {
 	if !m.FilterWithContext {
 		return
@@ -0,0 +1,31 @@
+package picker
+
+import "github.com/charmbracelet/bubbles/key"
+
+type pickerKeyMap struct {
+	Up     key.Binding
+	Down   key.Binding
+	Select key.Binding
+	Cancel key.Binding
+}
+
+func getKeyMap() pickerKeyMap {
+	return pickerKeyMap{
+		Up: key.NewBinding(
+			key.WithKeys("up", "ctrl+p", "ctrl+k"),
+			key.WithHelp("↑", "up"),
+		),
+		Down: key.NewBinding(
+			key.WithKeys("down", "ctrl+n", "ctrl+j"),
+			key.WithHelp("↓", "down"),
+		),
+		Select: key.NewBinding(
+			key.WithKeys("enter"),
+			key.WithHelp("enter", "select"),
+		),
+		Cancel: key.NewBinding(
+			key.WithKeys("esc", "ctrl+c"),
+			key.WithHelp("esc", "cancel"),
+		),
+	}
+}
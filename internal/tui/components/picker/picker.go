@@ -0,0 +1,203 @@
+package picker
+
+import (
+	"fmt"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/robinovitch61/wander/internal/tui/style"
+	"os"
+	"sort"
+	"strings"
+)
+
+const (
+	// defaultVisibleRows is how many rows are shown before the terminal height is known
+	defaultVisibleRows = 10
+	// nonRowLines are the lines of the view other than the rows: title, prompt, header and help
+	nonRowLines = 4
+)
+
+var (
+	keyMap = getKeyMap()
+)
+
+// Model is a standalone program to choose one of a list of rows by fuzzy searching them
+type Model struct {
+	title     string
+	header    string
+	rows      []string
+	keyMap    pickerKeyMap
+	textinput textinput.Model
+	// matches are the indexes of the rows matching the search, best first
+	matches []int
+	cursor  int
+	height  int
+	chosen  int
+	done    bool
+}
+
+func New(title, header string, rows []string) Model {
+	ti := textinput.New()
+	ti.Prompt = "> "
+	ti.Placeholder = "type to search"
+	ti.Focus()
+	m := Model{
+		title:     title,
+		header:    header,
+		rows:      rows,
+		keyMap:    keyMap,
+		textinput: ti,
+		chosen:    -1,
+	}
+	m.updateMatches()
+	return m
+}
+
+// Run shows the picker until a row is chosen or it is cancelled, returning the index of the chosen row or -1
+func Run(title, header string, rows []string) (int, error) {
+	// render to stderr so stdout only has the output of what is run after picking
+	final, err := tea.NewProgram(New(title, header, rows), tea.WithOutput(os.Stderr)).Run()
+	if err != nil {
+		return -1, err
+	}
+	return final.(Model).Chosen(), nil
+}
+
+func (m Model) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.height = msg.Height
+		return m, nil
+
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, m.keyMap.Cancel):
+			m.done = true
+			return m, tea.Quit
+
+		case key.Matches(msg, m.keyMap.Select):
+			if len(m.matches) == 0 {
+				return m, nil
+			}
+			m.chosen = m.matches[m.cursor]
+			m.done = true
+			return m, tea.Quit
+
+		case key.Matches(msg, m.keyMap.Up):
+			if m.cursor > 0 {
+				m.cursor--
+			}
+			return m, nil
+
+		case key.Matches(msg, m.keyMap.Down):
+			if m.cursor < len(m.matches)-1 {
+				m.cursor++
+			}
+			return m, nil
+		}
+	}
+
+	prevValue := m.textinput.Value()
+	var cmd tea.Cmd
+	m.textinput, cmd = m.textinput.Update(msg)
+	if m.textinput.Value() != prevValue {
+		m.updateMatches()
+	}
+	return m, cmd
+}
+
+func (m Model) View() string {
+	if m.done {
+		// leave nothing behind once done
+		return ""
+	}
+
+	var lines []string
+	lines = append(lines, style.Bold.Render(m.title))
+	lines = append(lines, m.textinput.View())
+	lines = append(lines, style.ViewportHeaderStyle.Render(m.header))
+
+	start, end := m.visibleRange()
+	for i := start; i < end; i++ {
+		row := m.rows[m.matches[i]]
+		if i == m.cursor {
+			row = style.ViewportSelectedRowStyle.Render(row)
+		}
+		lines = append(lines, row)
+	}
+
+	help := fmt.Sprintf("%d/%d  ", len(m.matches), len(m.rows))
+	for i, binding := range []key.Binding{m.keyMap.Up, m.keyMap.Down, m.keyMap.Select, m.keyMap.Cancel} {
+		if i > 0 {
+			help += "  "
+		}
+		help += fmt.Sprintf("%s %s", binding.Help().Key, binding.Help().Desc)
+	}
+	lines = append(lines, style.ViewportFooterStyle.Render(help))
+	return strings.Join(lines, "\n")
+}
+
+// Chosen is the index of the chosen row, or -1 if none was chosen
+func (m Model) Chosen() int {
+	return m.chosen
+}
+
+// visibleRange is the range of matches shown, keeping the cursor in view
+func (m Model) visibleRange() (int, int) {
+	visible := defaultVisibleRows
+	if m.height > 0 {
+		visible = max(1, m.height-nonRowLines)
+	}
+	start := 0
+	if m.cursor >= visible {
+		start = m.cursor - visible + 1
+	}
+	return start, min(len(m.matches), start+visible)
+}
+
+func (m *Model) updateMatches() {
+	terms := strings.Fields(strings.ToLower(m.textinput.Value()))
+	m.matches = []int{}
+	scores := make(map[int]int)
+	for i, row := range m.rows {
+		if score, ok := fuzzyScore(strings.ToLower(row), terms); ok {
+			m.matches = append(m.matches, i)
+			scores[i] = score
+		}
+	}
+	sort.SliceStable(m.matches, func(i, j int) bool {
+		return scores[m.matches[i]] < scores[m.matches[j]]
+	})
+	m.cursor = 0
+}
+
+// fuzzyScore matches a row if every term is a subsequence of it. Lower scores are better matches: terms found as
+// substrings score nothing, and other terms score how spread out their characters are in the row.
+func fuzzyScore(row string, terms []string) (int, bool) {
+	score := 0
+	for _, term := range terms {
+		if strings.Contains(row, term) {
+			continue
+		}
+		first, last := -1, -1
+		pos := 0
+		for _, r := range term {
+			idx := strings.IndexRune(row[pos:], r)
+			if idx < 0 {
+				return 0, false
+			}
+			if first < 0 {
+				first = pos + idx
+			}
+			last = pos + idx
+			pos += idx + len(string(r))
+		}
+		score += 1 + last - first
+	}
+	return score, true
+}
@@ -6,12 +6,16 @@ import (
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/mattn/go-runewidth"
+	"github.com/muesli/reflow/ansi"
 	"github.com/robinovitch61/wander/internal/dev"
 	"github.com/robinovitch61/wander/internal/fileio"
 	"github.com/robinovitch61/wander/internal/tui/components/toast"
 	"github.com/robinovitch61/wander/internal/tui/constants"
+	"github.com/robinovitch61/wander/internal/tui/formatter"
 	"github.com/robinovitch61/wander/internal/tui/style"
 	"strings"
+	"unicode/utf8"
 )
 
 const lineContinuationIndicator = "..."
@@ -56,10 +60,14 @@ type Model struct {
 	xOffset int
 
 	saveDialog textinput.Model
-	toast      toast.Model
+	// recordDialog is true if the save dialog is choosing a file to record to rather than saving the current content
+	recordDialog bool
+	toast        toast.Model
 
 	compactTableContent bool
 	showPrompt          bool
+	// droppedRows counts rows removed from the start of the content, shown in the footer
+	droppedRows int
 
 	// SpecialContentIdx can be used to highlight a specific item in the content, e.g. the
 	// currently selected item in a set of filtered results
@@ -74,6 +82,8 @@ type Model struct {
 	FooterStyle           lipgloss.Style
 	// ConditionalStyle styles lines containing key with corresponding style in value
 	ConditionalStyle map[string]lipgloss.Style
+	// StyleForContentIdx optionally styles the item at an index of content, taking precedence over ConditionalStyle
+	StyleForContentIdx func(contentIdx int) (lipgloss.Style, bool)
 }
 
 func New(width, height int, compactTableContent bool) (m Model) {
@@ -119,7 +129,11 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 			confirm := key.Matches(msg, m.keyMap.ConfirmSave)
 			if cancel || confirm {
 				if confirm {
-					cmds = append(cmds, m.getSaveCommand())
+					if m.recordDialog {
+						cmds = append(cmds, m.getRecordCommand())
+					} else {
+						cmds = append(cmds, m.getSaveCommand())
+					}
 				}
 
 				m.saveDialog.Blur()
@@ -199,6 +213,7 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 				}
 
 			case key.Matches(msg, m.keyMap.Save):
+				m.setRecordDialog(false)
 				m.saveDialog.Focus()
 				cmds = append(cmds, textinput.Blink)
 			}
@@ -283,10 +298,21 @@ func (m Model) View() string {
 				lineStyle = v
 			}
 		}
+		if m.StyleForContentIdx != nil {
+			if s, ok := m.StyleForContentIdx(contentIdx); ok {
+				lineStyle = s
+			}
+		}
 		if isSelected {
 			lineStyle = m.SelectedContentStyle
 		}
 		contentViewLine := m.getVisiblePartOfLine(line)
+		if strings.Contains(contentViewLine, "\x1b") {
+			// colors in the content would break up the selection style and highlighted matches
+			if stripped := formatter.StripANSI(contentViewLine); isSelected || (!hasNoHighlight && strings.Contains(stripped, m.stringToHighlight)) {
+				contentViewLine = stripped
+			}
+		}
 
 		if hasNoHighlight {
 			addLineToViewString(lineStyle.Render(contentViewLine))
@@ -390,6 +416,13 @@ func (m *Model) SetXOffset(n int) {
 	m.xOffset = max(0, min(maxXOffset, n))
 }
 
+// OpenRecordDialog focuses the save dialog to choose a file to record to, see fileio.RecordPathChosenMsg
+func (m *Model) OpenRecordDialog() tea.Cmd {
+	m.setRecordDialog(true)
+	m.saveDialog.Focus()
+	return textinput.Blink
+}
+
 func (m *Model) SetStringToHighlight(h string) {
 	m.stringToHighlight = h
 }
@@ -399,6 +432,11 @@ func (m *Model) ScrollToBottom() {
 	m.viewDown(len(m.content))
 }
 
+func (m *Model) SetDroppedRows(n int) {
+	m.droppedRows = n
+	m.updateContentHeight()
+}
+
 func (m *Model) SetShowPrompt(v bool) {
 	m.showPrompt = v
 }
@@ -553,10 +591,19 @@ func (m *Model) viewRight(n int) {
 	m.SetXOffset(m.xOffset + n)
 }
 
+func (m *Model) setRecordDialog(recordDialog bool) {
+	m.recordDialog = recordDialog
+	m.updateSaveDialogPlaceholder()
+}
+
 func (m *Model) updateSaveDialogPlaceholder() {
-	padding := m.width - stringWidth(constants.SaveDialogPlaceholder) - stringWidth(m.saveDialog.Prompt)
+	placeholderText := constants.SaveDialogPlaceholder
+	if m.recordDialog {
+		placeholderText = constants.RecordDialogPlaceholder
+	}
+	padding := m.width - stringWidth(placeholderText) - stringWidth(m.saveDialog.Prompt)
 	padding = max(0, padding)
-	placeholder := constants.SaveDialogPlaceholder + strings.Repeat(" ", padding)
+	placeholder := placeholderText + strings.Repeat(" ", padding)
 	m.saveDialog.Placeholder = placeholder[:min(stringWidth(placeholder), m.width)]
 }
 
@@ -630,13 +677,14 @@ func (m Model) getVisiblePartOfLine(line string) string {
 	rightTrimmedLineLength := stringWidth(strings.TrimRight(line, " "))
 	end := min(stringWidth(line), m.xOffset+m.width)
 	start := min(end, m.xOffset)
-	line = line[start:end]
+	line = substringByWidth(line, start, end)
 	if m.xOffset+m.width < rightTrimmedLineLength {
 		truncate := max(0, stringWidth(line)-lenLineContinuationIndicator)
-		line = line[:truncate] + lineContinuationIndicator
+		line = substringByWidth(line, 0, truncate) + lineContinuationIndicator
 	}
 	if m.xOffset > 0 {
-		line = lineContinuationIndicator + line[min(stringWidth(line), lenLineContinuationIndicator):]
+		lineWidth := stringWidth(line)
+		line = lineContinuationIndicator + substringByWidth(line, min(lineWidth, lenLineContinuationIndicator), lineWidth)
 	}
 	return line
 }
@@ -705,9 +753,12 @@ func (m Model) getFooter() (string, int) {
 		denominator = totalNumLines
 	}
 
-	if totalNumLines >= m.height-len(m.getHeader()) {
+	if totalNumLines >= m.height-len(m.getHeader()) || m.droppedRows > 0 {
 		percentScrolled := percent(numerator, denominator)
 		footerString := fmt.Sprintf("%d%% (%d/%d)", percentScrolled, numerator, denominator)
+		if m.droppedRows > 0 {
+			footerString += fmt.Sprintf(", %d rows dropped", m.droppedRows)
+		}
 		renderedFooterString := m.FooterStyle.Copy().MaxWidth(m.width).Render(footerString)
 		footerHeight := lipgloss.Height(renderedFooterString)
 		return renderedFooterString, footerHeight
@@ -731,6 +782,13 @@ func (m Model) getSaveCommand() tea.Cmd {
 	}
 }
 
+func (m Model) getRecordCommand() tea.Cmd {
+	saveDialogValue := m.saveDialog.Value()
+	return func() tea.Msg {
+		return fileio.RecordPathChosenMsg{SaveDialogValue: saveDialogValue}
+	}
+}
+
 func min(a, b int) int {
 	if a < b {
 		return a
@@ -751,24 +809,71 @@ func percent(a, b int) int {
 
 func splitLineIntoSizedChunks(line string, chunkSize int) []string {
 	var wrappedLines []string
-	for {
-		lineWidth := stringWidth(line)
-		if lineWidth == 0 {
-			break
-		}
-
-		width := chunkSize
-		if lineWidth < chunkSize {
-			width = lineWidth
-		}
-
-		wrappedLines = append(wrappedLines, line[0:width])
-		line = line[width:]
+	lineWidth := stringWidth(line)
+	for start := 0; start < lineWidth; start += chunkSize {
+		wrappedLines = append(wrappedLines, substringByWidth(line, start, min(start+chunkSize, lineWidth)))
 	}
 	return wrappedLines
 }
 
-// stringWidth is a function in case in the future something like utf8.RuneCountInString or lipgloss.Width is better
+// stringWidth is the number of terminal cells s takes up, ignoring ANSI escape sequences
 func stringWidth(s string) int {
+	if isPlainASCII(s) {
+		return len(s)
+	}
+	return ansi.PrintableRuneWidth(s)
+}
+
+// substringByWidth returns the runes of s that start between terminal cells start and end. ANSI escape sequences
+// are all kept so that colors set before start still apply, and styles are reset at the end if there were any
+func substringByWidth(s string, start, end int) string {
+	if isPlainASCII(s) {
+		end = min(end, len(s))
+		return s[min(start, end):end]
+	}
+
+	var b strings.Builder
+	hasEscapes := false
+	cell := 0
+	for i := 0; i < len(s) && cell < end; {
+		if s[i] == '\x1b' {
+			seqLen := escapeSequenceLength(s[i:])
+			b.WriteString(s[i : i+seqLen])
+			hasEscapes = true
+			i += seqLen
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if cell >= start {
+			b.WriteString(s[i : i+size])
+		}
+		cell += runewidth.RuneWidth(r)
+		i += size
+	}
+	if hasEscapes {
+		b.WriteString("\x1b[0m")
+	}
+	return b.String()
+}
+
+// escapeSequenceLength is the number of bytes in the escape sequence at the start of s
+func escapeSequenceLength(s string) int {
+	if len(s) < 2 || s[1] != '[' {
+		return min(len(s), 2)
+	}
+	for i := 2; i < len(s); i++ {
+		if s[i] >= 0x40 && s[i] <= 0x7e {
+			return i + 1
+		}
+	}
 	return len(s)
 }
+
+func isPlainASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf || s[i] == '\x1b' {
+			return false
+		}
+	}
+	return true
+}
@@ -16,6 +16,8 @@ const ToastDuration = time.Second * 5
 
 const SaveDialogPlaceholder = "Output file name (path optional)"
 
+const RecordDialogPlaceholder = "Record to file name (path optional)"
+
 const TableSeparator = "|【=◈︿◈=】|"
 
 const TablePadding = "   "
@@ -29,9 +31,15 @@ var TasksTableStatusStyles = JobsTableStatusStyles
 
 const DefaultPageInput = "/bin/sh"
 
+// EventPresetsConfigKey is the config file key that event presets are read from and saved to
+const EventPresetsConfigKey = "wander_event_presets"
+
 // DefaultEventJQQuery is a single line as this shows up verbatim in `wander --help`
 const DefaultEventJQQuery = `.Events[] | {"1:Index": .Index, "2:Topic": .Topic, "3:Type": .Type, "4:Name": .Payload | (.Job // .Allocation // .Deployment // .Evaluation) | (.JobID // .ID), "5:ID": .Payload | (.Job.ID // (.Allocation // .Deployment // .Evaluation).ID[:8])}`
 
+// DefaultEventSummaryJQQuery groups events by topic and type in the events summary
+const DefaultEventSummaryJQQuery = `"\(.Topic) \(.Type)"`
+
 // DefaultAllocEventJQQuery is a single line as this shows up verbatim in `wander --help`
 const DefaultAllocEventJQQuery = `.Index as $index | .Events[] | .Type as $type | .Payload.Allocation | .DeploymentStatus.Healthy as $healthy | .ClientStatus as $clientStatus | .Name as $allocName | (.TaskStates // {"":{"Events": [{}]}}) | to_entries[] | .key as $k | .value.Events[] | {"0:Index": $index, "1:AllocName": $allocName, "2:TaskName": $k, "3:Type": $type, "4:Time": ((.Time // 0) / 1000000000 | todate), "5:Msg": .DisplayMessage, "6:Healthy": $healthy, "7:ClientStatus": $clientStatus}`
 
@@ -8,18 +8,23 @@ import (
 	"github.com/robinovitch61/wander/internal/tui/constants"
 	"math"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 )
 
 const (
-	ansi  = "[\u001B\u009B][[\\]()#;?]*(?:(?:(?:[a-zA-Z\\d]*(?:;[a-zA-Z\\d]*)*)?\u0007)|(?:(?:\\d{1,4}(?:;\\d{0,4})*)?[\\dA-PRZcf-ntqry=><~]))"
-	osCmd = "[\u001B]]0.*[\a\u001B](?:\\\\)?"
+	ansi     = "[\u001B\u009B][[\\]()#;?]*(?:(?:(?:[a-zA-Z\\d]*(?:;[a-zA-Z\\d]*)*)?\u0007)|(?:(?:\\d{1,4}(?:;\\d{0,4})*)?[\\dA-PRZcf-ntqry=><~]))"
+	osCmd    = "[\u001B]]0.*[\a\u001B](?:\\\\)?"
+	anyOSCmd = "[\u001B]][^\a\u001B]*(?:\a|\u001B\\\\)"
+	sgr      = "^[\u001B\u009B]\\[?[\\d;]*m$"
 )
 
 var (
-	ansiRe  = regexp.MustCompile(ansi)
-	osCmdRe = regexp.MustCompile(osCmd)
+	ansiRe     = regexp.MustCompile(ansi)
+	osCmdRe    = regexp.MustCompile(osCmd)
+	anyOSCmdRe = regexp.MustCompile(anyOSCmd)
+	sgrRe      = regexp.MustCompile(sgr)
 )
 
 func prettyPrint(b []byte) ([]byte, error) {
@@ -31,7 +36,7 @@ func prettyPrint(b []byte) ([]byte, error) {
 func PrettyJsonStringAsLines(logline string) []string {
 	pretty, err := prettyPrint([]byte(logline))
 	if err != nil {
-		return []string{logline}
+		return strings.Split(logline, "\n")
 	}
 
 	var splitLines []string
@@ -42,6 +47,104 @@ func PrettyJsonStringAsLines(logline string) []string {
 	return splitLines
 }
 
+// ParseStructuredLogLine parses a JSON object or logfmt log line into its top level fields.
+// The second return value is false if the line is neither.
+func ParseStructuredLogLine(line string) (map[string]string, bool) {
+	trimmed := strings.TrimSpace(line)
+	if strings.HasPrefix(trimmed, "{") {
+		var parsed map[string]interface{}
+		if err := json.Unmarshal([]byte(trimmed), &parsed); err != nil {
+			return nil, false
+		}
+		fields := make(map[string]string)
+		for k, v := range parsed {
+			fields[k] = jsonValueAsString(v)
+		}
+		return fields, true
+	}
+	return parseLogfmt(trimmed)
+}
+
+func jsonValueAsString(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return t
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(t)
+	default:
+		b, err := json.Marshal(t)
+		if err != nil {
+			return fmt.Sprintf("%v", t)
+		}
+		return string(b)
+	}
+}
+
+// parseLogfmt only accepts lines where every token is a key=value pair, as
+// otherwise most plain text would parse as logfmt bare keys
+func parseLogfmt(line string) (map[string]string, bool) {
+	fields := make(map[string]string)
+	i := 0
+	for i < len(line) {
+		for i < len(line) && line[i] == ' ' {
+			i++
+		}
+		if i >= len(line) {
+			break
+		}
+
+		keyStart := i
+		for i < len(line) && line[i] != '=' && line[i] != ' ' && line[i] != '"' {
+			i++
+		}
+		if i == keyStart || i >= len(line) || line[i] != '=' {
+			return nil, false
+		}
+		key := line[keyStart:i]
+		i++ // skip =
+
+		var value string
+		if i < len(line) && line[i] == '"' {
+			i++
+			var b strings.Builder
+			closed := false
+			for i < len(line) {
+				if line[i] == '\\' && i+1 < len(line) {
+					b.WriteByte(line[i+1])
+					i += 2
+					continue
+				}
+				if line[i] == '"' {
+					closed = true
+					i++
+					break
+				}
+				b.WriteByte(line[i])
+				i++
+			}
+			if !closed {
+				return nil, false
+			}
+			value = b.String()
+		} else {
+			valueStart := i
+			for i < len(line) && line[i] != ' ' {
+				i++
+			}
+			value = line[valueStart:i]
+		}
+		fields[key] = value
+	}
+	if len(fields) == 0 {
+		return nil, false
+	}
+	return fields, true
+}
+
 type Table struct {
 	HeaderRows, ContentRows []string
 }
@@ -82,6 +185,49 @@ func GetRenderedTableAsString(columns []string, data [][]string) Table {
 	return Table{headerRows, contentRows}
 }
 
+// GrowTableWidths widens the column widths to fit cells, returning whether any grew
+func GrowTableWidths(widths []int, cells []string) bool {
+	grew := false
+	for i, cell := range cells {
+		if w := tablewriter.DisplayWidth(cell); w > widths[i] {
+			widths[i] = w
+			grew = true
+		}
+	}
+	return grew
+}
+
+// GetRenderedTableHeader is the header GetRenderedTableAsString renders for columns of the given widths
+func GetRenderedTableHeader(columns []string, widths []int) []string {
+	var b strings.Builder
+	for i, column := range columns {
+		b.WriteString(padTableCell(column, widths[i]))
+		if i == len(columns)-1 {
+			b.WriteString(" ")
+		} else {
+			b.WriteString(constants.TableSeparator)
+		}
+	}
+	return []string{b.String() + constants.TableSeparator}
+}
+
+// GetRenderedTableRow is a content row as GetRenderedTableAsString renders it for columns of the given widths, so
+// that rows can be added to a table without rendering all of it again
+func GetRenderedTableRow(cells []string, widths []int) string {
+	var b strings.Builder
+	for i, cell := range cells {
+		b.WriteString(padTableCell(cell, widths[i]))
+		if len(cells) > 1 {
+			b.WriteString(constants.TableSeparator)
+		}
+	}
+	return b.String()
+}
+
+func padTableCell(cell string, width int) string {
+	return cell + strings.Repeat(" ", max(0, width-tablewriter.DisplayWidth(cell)))
+}
+
 func ShortAllocID(allocID string) string {
 	firstN := 8
 	if len(allocID) < firstN {
@@ -90,6 +236,20 @@ func ShortAllocID(allocID string) string {
 	return allocID[:firstN]
 }
 
+// FormatBytes formats a number of bytes in the largest binary unit it has at least one of, e.g. 1.5 MiB
+func FormatBytes(n int64) string {
+	if n < 1024 {
+		return fmt.Sprintf("%d B", n)
+	}
+	value := float64(n)
+	unit := 0
+	units := []string{"KiB", "MiB", "GiB", "TiB"}
+	for value /= 1024; value >= 1024 && unit < len(units)-1; value /= 1024 {
+		unit++
+	}
+	return fmt.Sprintf("%.1f %s", value, units[unit])
+}
+
 func FormatTime(t time.Time) string {
 	if t.IsZero() {
 		return "-"
@@ -157,6 +317,23 @@ func StripOSCommandSequences(str string) string {
 	return osCmdRe.ReplaceAllString(str, "")
 }
 
+// StripANSIExceptColors removes operating system commands, cursor movement and other escape sequences,
+// keeping only SGR sequences that set colors and text styles
+func StripANSIExceptColors(str string) string {
+	str = anyOSCmdRe.ReplaceAllString(str, "")
+	return ansiRe.ReplaceAllStringFunc(str, func(seq string) string {
+		if sgrRe.MatchString(seq) {
+			return seq
+		}
+		return ""
+	})
+}
+
 func CleanLogs(logs string) string {
 	return StripANSI(strings.ReplaceAll(logs, "\t", "    "))
 }
+
+// CleanLogsPreservingColors is CleanLogs, but keeps color sequences
+func CleanLogsPreservingColors(logs string) string {
+	return StripANSIExceptColors(strings.ReplaceAll(logs, "\t", "    "))
+}
@@ -7,6 +7,14 @@ import (
 type keyMap struct {
 	Back            key.Binding
 	Exec            key.Binding
+	ExecAll         key.Binding
+	Copy            key.Binding
+	CombinedOutput  key.Binding
+	ExecSessions    key.Binding
+	ExecDetach      key.Binding
+	ExecNextTab     key.Binding
+	ExecPrevTab     key.Binding
+	CloseExecTab    key.Binding
 	Exit            key.Binding
 	Compact         key.Binding
 	JobsMode        key.Binding
@@ -23,6 +31,21 @@ type keyMap struct {
 	Stats           key.Binding
 	StdOut          key.Binding
 	StdErr          key.Binding
+	StructuredLogs  key.Binding
+	LogLevelFilter  key.Binding
+	NextError       key.Binding
+	PrevError       key.Binding
+	Record          key.Binding
+	ReplayPause     key.Binding
+	ReplayFaster    key.Binding
+	ReplaySlower    key.Binding
+	ReplaySeekBack  key.Binding
+	ReplaySeekAhead key.Binding
+	EditEvents      key.Binding
+	EventsPresets   key.Binding
+	NewPreset       key.Binding
+	EventsSummary   key.Binding
+	Alerts          key.Binding
 	Spec            key.Binding
 	Wrap            key.Binding
 	AdminMenu       key.Binding
@@ -37,6 +60,38 @@ var KeyMap = keyMap{
 		key.WithKeys("e"),
 		key.WithHelp("e", "exec"),
 	),
+	ExecAll: key.NewBinding(
+		key.WithKeys("E"),
+		key.WithHelp("E", "exec in all allocs"),
+	),
+	Copy: key.NewBinding(
+		key.WithKeys("C"),
+		key.WithHelp("C", "copy files"),
+	),
+	CombinedOutput: key.NewBinding(
+		key.WithKeys("O"),
+		key.WithHelp("O", "combined output"),
+	),
+	ExecSessions: key.NewBinding(
+		key.WithKeys("T"),
+		key.WithHelp("T", "exec sessions"),
+	),
+	ExecDetach: key.NewBinding(
+		key.WithKeys("ctrl+]"),
+		key.WithHelp("ctrl+]", "detach"),
+	),
+	ExecNextTab: key.NewBinding(
+		key.WithKeys("]"),
+		key.WithHelp("]", "next tab"),
+	),
+	ExecPrevTab: key.NewBinding(
+		key.WithKeys("["),
+		key.WithHelp("[", "prev tab"),
+	),
+	CloseExecTab: key.NewBinding(
+		key.WithKeys("x"),
+		key.WithHelp("x", "close tab"),
+	),
 	Exit: key.NewBinding(
 		key.WithKeys("q", "ctrl+c"),
 		key.WithHelp("q/ctrl+c", "exit"),
@@ -101,6 +156,66 @@ var KeyMap = keyMap{
 		key.WithKeys("e"),
 		key.WithHelp("e", "stderr"),
 	),
+	StructuredLogs: key.NewBinding(
+		key.WithKeys("t"),
+		key.WithHelp("t", "structured"),
+	),
+	LogLevelFilter: key.NewBinding(
+		key.WithKeys("w"),
+		key.WithHelp("w", "warn+ only"),
+	),
+	NextError: key.NewBinding(
+		key.WithKeys("]"),
+		key.WithHelp("]", "next error"),
+	),
+	PrevError: key.NewBinding(
+		key.WithKeys("["),
+		key.WithHelp("[", "prev error"),
+	),
+	Record: key.NewBinding(
+		key.WithKeys("R"),
+		key.WithHelp("R", "record"),
+	),
+	ReplayPause: key.NewBinding(
+		key.WithKeys("P"),
+		key.WithHelp("P", "pause/resume"),
+	),
+	ReplayFaster: key.NewBinding(
+		key.WithKeys("+", "="),
+		key.WithHelp("+", "faster"),
+	),
+	ReplaySlower: key.NewBinding(
+		key.WithKeys("-"),
+		key.WithHelp("-", "slower"),
+	),
+	ReplaySeekBack: key.NewBinding(
+		key.WithKeys(","),
+		key.WithHelp(",", "back 10s"),
+	),
+	ReplaySeekAhead: key.NewBinding(
+		key.WithKeys("."),
+		key.WithHelp(".", "ahead 10s"),
+	),
+	EditEvents: key.NewBinding(
+		key.WithKeys("E"),
+		key.WithHelp("E", "edit topics/query"),
+	),
+	EventsPresets: key.NewBinding(
+		key.WithKeys("L"),
+		key.WithHelp("L", "presets"),
+	),
+	NewPreset: key.NewBinding(
+		key.WithKeys("a"),
+		key.WithHelp("a", "save current as preset"),
+	),
+	EventsSummary: key.NewBinding(
+		key.WithKeys("S"),
+		key.WithHelp("S", "summary"),
+	),
+	Alerts: key.NewBinding(
+		key.WithKeys("!"),
+		key.WithHelp("!", "alerts"),
+	),
 	Spec: key.NewBinding(
 		key.WithKeys("p"),
 		key.WithHelp("p", "spec"),
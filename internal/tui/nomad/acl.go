@@ -0,0 +1,27 @@
+package nomad
+
+import (
+	"github.com/hashicorp/nomad/api"
+	"time"
+)
+
+// roleTokenTTL is how long tokens created for a role last if they aren't deleted, within nomad's default maximum of 24h
+const roleTokenTTL = 12 * time.Hour
+
+// CreateRoleToken creates a client token with the policies of an ACL role, e.g. for the length of an ssh session. The
+// client's token needs acl:write
+func CreateRoleToken(client api.Client, role, name string) (*api.ACLToken, error) {
+	token, _, err := client.ACLTokens().Create(&api.ACLToken{
+		Name:          name,
+		Type:          "client",
+		Roles:         []*api.ACLTokenRoleLink{{Name: role}},
+		ExpirationTTL: roleTokenTTL,
+	}, nil)
+	return token, err
+}
+
+// DeleteToken deletes a token by its accessor id, e.g. once the session it was created for ends
+func DeleteToken(client api.Client, accessorID string) error {
+	_, err := client.ACLTokens().Delete(accessorID, nil)
+	return err
+}
@@ -0,0 +1,195 @@
+package nomad
+
+import (
+	"encoding/json"
+	"fmt"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/hashicorp/nomad/api"
+	"github.com/itchyny/gojq"
+	"github.com/robinovitch61/wander/internal/tui/components/page"
+	"github.com/robinovitch61/wander/internal/tui/formatter"
+	"strings"
+	"time"
+)
+
+type AlertNotify string
+
+const (
+	// AlertNotifyToast shows a toast on the current page
+	AlertNotifyToast AlertNotify = "toast"
+	// AlertNotifyBell rings the terminal bell
+	AlertNotifyBell AlertNotify = "bell"
+	// AlertNotifyDesktop sends a desktop notification with the OSC 9 escape sequence, supported by e.g. iTerm2 and kitty
+	AlertNotifyDesktop AlertNotify = "osc9"
+)
+
+// AlertRule alerts on events for which its jq predicate outputs true
+type AlertRule struct {
+	Name   string
+	Code   *gojq.Code
+	Notify []AlertNotify
+}
+
+type Alert struct {
+	Rule   string
+	Time   time.Time
+	Topic  string
+	Type   string
+	Key    string
+	Index  uint64
+	Notify []AlertNotify
+	// Event is the JSON of the event that matched the rule
+	Event string
+}
+
+type AlertsMsg struct {
+	StreamID int
+	Alerts   []Alert
+	Index    uint64
+}
+
+// AlertNotificationWrittenMsg follows alert notifications once the view has had time to write them, see
+// AlertNotification
+type AlertNotificationWrittenMsg struct{}
+
+// NewAlertRule compiles an alert rule, notifying with a toast if notify is empty
+func NewAlertRule(name, query string, notify []string) (AlertRule, error) {
+	if name == "" {
+		return AlertRule{}, fmt.Errorf("alert rule with query %s has no name", query)
+	}
+	code, err := CompileJQQuery(query)
+	if err != nil {
+		return AlertRule{}, fmt.Errorf("alert rule %s: %v", name, err)
+	}
+	rule := AlertRule{Name: name, Code: code}
+	for _, n := range notify {
+		switch notifyType := AlertNotify(strings.TrimSpace(n)); notifyType {
+		case AlertNotifyToast, AlertNotifyBell, AlertNotifyDesktop:
+			rule.Notify = append(rule.Notify, notifyType)
+		default:
+			return AlertRule{}, fmt.Errorf("alert rule %s: notify %q must be one of toast, bell, osc9", name, n)
+		}
+	}
+	if len(rule.Notify) == 0 {
+		rule.Notify = []AlertNotify{AlertNotifyToast}
+	}
+	return rule, nil
+}
+
+func (a Alert) Summary() string {
+	return fmt.Sprintf("%s: %s %s %s", a.Rule, a.Topic, a.Type, a.Key)
+}
+
+// ReadAlertsStreamNextMessage checks the next batch of events from an events stream against the alert rules
+func ReadAlertsStreamNextMessage(c EventsStream, rules []AlertRule) tea.Cmd {
+	return func() tea.Msg {
+		line, ok := <-c.Chan
+		if !ok {
+			return EventsStreamClosedMsg{StreamID: c.ID}
+		}
+		if line.Err != nil {
+			return EventsStreamClosedMsg{StreamID: c.ID, Err: line.Err}
+		}
+		var alerts []Alert
+		for _, event := range line.Events {
+			alerts = append(alerts, matchAlertRules(event, rules)...)
+		}
+		return AlertsMsg{StreamID: c.ID, Alerts: alerts, Index: line.Index}
+	}
+}
+
+func matchAlertRules(event api.Event, rules []AlertRule) []Alert {
+	eventBytes, err := json.Marshal(event)
+	if err != nil {
+		return nil
+	}
+	var eventObj map[string]interface{}
+	if err := json.Unmarshal(eventBytes, &eventObj); err != nil {
+		return nil
+	}
+
+	var alerts []Alert
+	for _, rule := range rules {
+		if !predicateMatches(rule.Code, eventObj) {
+			continue
+		}
+		alerts = append(alerts, Alert{
+			Rule:   rule.Name,
+			Time:   time.Now(),
+			Topic:  string(event.Topic),
+			Type:   event.Type,
+			Key:    event.Key,
+			Index:  event.Index,
+			Notify: rule.Notify,
+			Event:  string(eventBytes),
+		})
+	}
+	return alerts
+}
+
+// predicateMatches is true if any output of the jq query is true. Errors, e.g. from events the rule
+// wasn't written for, are not matches.
+func predicateMatches(code *gojq.Code, input map[string]interface{}) bool {
+	iter := code.Run(input)
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			return false
+		}
+		if b, isBool := v.(bool); isBool && b {
+			return true
+		}
+	}
+}
+
+// FetchAlerts lists the alerts, most recent last, keyed by their event JSON
+func FetchAlerts(alerts []Alert) tea.Cmd {
+	return func() tea.Msg {
+		tableHeader, allPageRows := AlertsAsTable(alerts)
+		return PageLoadedMsg{Page: AlertsPage, TableHeader: tableHeader, AllPageRows: allPageRows}
+	}
+}
+
+func AlertsAsTable(alerts []Alert) ([]string, []page.Row) {
+	if len(alerts) == 0 {
+		return []string{"No alerts yet"}, []page.Row{}
+	}
+	var data [][]string
+	for _, a := range alerts {
+		data = append(data, []string{
+			formatter.FormatTime(a.Time),
+			a.Rule,
+			a.Topic,
+			a.Type,
+			a.Key,
+			fmt.Sprint(a.Index),
+		})
+	}
+	table := formatter.GetRenderedTableAsString([]string{"Time", "Rule", "Topic", "Type", "Key", "Index"}, data)
+	var rows []page.Row
+	for idx, row := range table.ContentRows {
+		rows = append(rows, page.Row{Key: alerts[idx].Event, Row: row})
+	}
+	return table.HeaderRows, rows
+}
+
+// AlertNotification returns the escape sequences that notify of an alert in the terminal, if any
+func AlertNotification(a Alert) string {
+	var notification string
+	for _, n := range a.Notify {
+		switch n {
+		case AlertNotifyBell:
+			notification += "\a"
+		case AlertNotifyDesktop:
+			// the message can't contain control characters, which would end the sequence early
+			msg := strings.Map(func(r rune) rune {
+				if r < ' ' || r == 0x7f {
+					return -1
+				}
+				return r
+			}, "wander: "+a.Summary())
+			notification += fmt.Sprintf("\x1b]9;%s\a", msg)
+		}
+	}
+	return notification
+}
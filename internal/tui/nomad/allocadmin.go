@@ -44,7 +44,13 @@ func GetCmdForAllocAdminAction(
 	taskName,
 	allocName,
 	allocID string,
+	readOnly bool,
 ) tea.Cmd {
+	if readOnly {
+		return func() tea.Msg {
+			return AllocAdminActionCompleteMsg{Err: ErrReadOnly, TaskName: taskName, AllocName: allocName, AllocID: allocID}
+		}
+	}
 	switch adminAction {
 	case RestartTaskAction:
 		return RestartTask(client, taskName, allocName, allocID)
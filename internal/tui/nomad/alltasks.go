@@ -12,60 +12,83 @@ import (
 
 func FetchAllTasks(client api.Client, columns []string) tea.Cmd {
 	return func() tea.Msg {
-		allocations, _, err := client.Allocations().List(&api.QueryOptions{})
+		taskRowEntries, err := listAllTasks(client)
 		if err != nil {
 			return message.ErrMsg{Err: err}
 		}
+		tableHeader, allPageData := tasksAsTable(taskRowEntries, columns)
+		return PageLoadedMsg{Page: AllTasksPage, TableHeader: tableHeader, AllPageRows: allPageData}
+	}
+}
 
-		var taskRowEntries []taskRowEntry
-		for _, alloc := range allocations {
-			allocAsJSON, err := json.Marshal(alloc)
-			if err != nil {
-				return message.ErrMsg{Err: err}
-			}
+// AllTasksRows are the values of the columns for each task, as shown on the all tasks page
+func AllTasksRows(client api.Client, columns []string) ([][]string, error) {
+	taskRowEntries, err := listAllTasks(client)
+	if err != nil {
+		return nil, err
+	}
+	var rows [][]string
+	for _, row := range taskRowEntries {
+		rows = append(rows, getTaskRowFromColumns(row, columns))
+	}
+	return rows, nil
+}
 
-			for taskName, task := range alloc.TaskStates {
-				taskRowEntries = append(taskRowEntries, taskRowEntry{
-					FullAllocationAsJSON: string(allocAsJSON),
-					NodeID:               alloc.NodeID,
-					JobID:                alloc.JobID,
-					ID:                   alloc.ID,
-					TaskGroup:            alloc.TaskGroup,
-					Name:                 alloc.Name,
-					TaskName:             taskName,
-					State:                task.State,
-					StartedAt:            task.StartedAt.UTC(),
-					FinishedAt:           task.FinishedAt.UTC(),
-				})
-			}
+func listAllTasks(client api.Client) ([]taskRowEntry, error) {
+	allocations, _, err := client.Allocations().List(&api.QueryOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var taskRowEntries []taskRowEntry
+	for _, alloc := range allocations {
+		allocAsJSON, err := json.Marshal(alloc)
+		if err != nil {
+			return nil, err
+		}
+
+		for taskName, task := range alloc.TaskStates {
+			taskRowEntries = append(taskRowEntries, taskRowEntry{
+				FullAllocationAsJSON: string(allocAsJSON),
+				NodeID:               alloc.NodeID,
+				JobID:                alloc.JobID,
+				ID:                   alloc.ID,
+				TaskGroup:            alloc.TaskGroup,
+				Name:                 alloc.Name,
+				TaskName:             taskName,
+				State:                task.State,
+				StartedAt:            task.StartedAt.UTC(),
+				FinishedAt:           task.FinishedAt.UTC(),
+			})
 		}
+	}
 
-		sort.Slice(taskRowEntries, func(x, y int) bool {
-			firstTask := taskRowEntries[x]
-			secondTask := taskRowEntries[y]
-			if firstTask.JobID == secondTask.JobID {
-				if firstTask.TaskName == secondTask.TaskName {
-					if firstTask.Name == secondTask.Name {
-						if firstTask.State == secondTask.State {
-							if firstTask.StartedAt.Equal(secondTask.StartedAt) {
-								return firstTask.ID > secondTask.ID
-							}
-							return firstTask.StartedAt.After(secondTask.StartedAt)
+	sort.Slice(taskRowEntries, func(x, y int) bool {
+		firstTask := taskRowEntries[x]
+		secondTask := taskRowEntries[y]
+		if firstTask.JobID == secondTask.JobID {
+			if firstTask.TaskName == secondTask.TaskName {
+				if firstTask.Name == secondTask.Name {
+					if firstTask.State == secondTask.State {
+						if firstTask.StartedAt.Equal(secondTask.StartedAt) {
+							return firstTask.ID > secondTask.ID
 						}
-						return firstTask.State > secondTask.State
+						return firstTask.StartedAt.After(secondTask.StartedAt)
 					}
-					return firstTask.Name < secondTask.Name
+					return firstTask.State > secondTask.State
 				}
-				return firstTask.TaskName < secondTask.TaskName
+				return firstTask.Name < secondTask.Name
 			}
-			return firstTask.JobID < secondTask.JobID
-		})
-
-		tableHeader, allPageData := tasksAsTable(taskRowEntries, columns)
-		return PageLoadedMsg{Page: AllTasksPage, TableHeader: tableHeader, AllPageRows: allPageData}
-	}
+			return firstTask.TaskName < secondTask.TaskName
+		}
+		return firstTask.JobID < secondTask.JobID
+	})
+	return taskRowEntries, nil
 }
 
+// TaskColumns are the columns of the all tasks and tasks for job pages
+var TaskColumns = []string{"Node ID", "Job", "Alloc ID", "Task Group", "Alloc Name", "Task Name", "State", "Started", "Finished", "Uptime"}
+
 func getTaskRowFromColumns(row taskRowEntry, columns []string) []string {
 	knownColMap := map[string]string{
 		"Node ID":    formatter.ShortAllocID(row.NodeID),
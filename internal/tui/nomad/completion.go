@@ -0,0 +1,136 @@
+package nomad
+
+import (
+	"fmt"
+	"github.com/hashicorp/nomad/api"
+	"github.com/robinovitch61/wander/internal/tui/formatter"
+	"sort"
+	"strings"
+)
+
+// CompletionCandidates are the jobs and allocations that shell completions are made from, fetched together so that
+// one fetch can be cached for completing any argument
+type CompletionCandidates struct {
+	Jobs   []CompletionJob
+	Allocs []CompletionAlloc
+}
+
+type CompletionJob struct {
+	ID, Namespace, Status string
+}
+
+type CompletionAlloc struct {
+	ID, Name, JobID, Namespace, TaskGroup, ClientStatus string
+	Tasks                                               []string
+}
+
+// FetchCompletionCandidates lists the jobs and allocations in the client's namespace
+func FetchCompletionCandidates(client api.Client) (CompletionCandidates, error) {
+	var candidates CompletionCandidates
+	jobs, _, err := client.Jobs().List(nil)
+	if err != nil {
+		return candidates, err
+	}
+	for _, job := range jobs {
+		candidates.Jobs = append(candidates.Jobs, CompletionJob{ID: job.ID, Namespace: job.Namespace, Status: job.Status})
+	}
+	allocs, _, err := client.Allocations().List(nil)
+	if err != nil {
+		return candidates, err
+	}
+	for _, alloc := range allocs {
+		var tasks []string
+		for task := range alloc.TaskStates {
+			tasks = append(tasks, task)
+		}
+		sort.Strings(tasks)
+		candidates.Allocs = append(candidates.Allocs, CompletionAlloc{
+			ID:           alloc.ID,
+			Name:         alloc.Name,
+			JobID:        alloc.JobID,
+			Namespace:    alloc.Namespace,
+			TaskGroup:    alloc.TaskGroup,
+			ClientStatus: alloc.ClientStatus,
+			Tasks:        tasks,
+		})
+	}
+	return candidates, nil
+}
+
+// JobCompletions are the IDs of jobs starting with prefix, each with a description after a tab
+func (c CompletionCandidates) JobCompletions(prefix string) []string {
+	var completions []string
+	seen := make(map[string]bool)
+	for _, job := range c.Jobs {
+		if !strings.HasPrefix(job.ID, prefix) || seen[job.ID] {
+			continue
+		}
+		seen[job.ID] = true
+		completions = append(completions, fmt.Sprintf("%s\tjob in %s (%s)", job.ID, job.Namespace, job.Status))
+	}
+	return completions
+}
+
+// AllocCompletions are the short IDs of allocations starting with prefix, each with a description after a tab.
+// If runningOnly is true, only running allocations are included.
+func (c CompletionCandidates) AllocCompletions(prefix string, runningOnly bool) []string {
+	var completions []string
+	for _, alloc := range c.Allocs {
+		shortID := formatter.ShortAllocID(alloc.ID)
+		if !strings.HasPrefix(shortID, prefix) || (runningOnly && alloc.ClientStatus != "running") {
+			continue
+		}
+		completions = append(completions, fmt.Sprintf("%s\t%s in %s (%s)", shortID, alloc.Name, alloc.Namespace, alloc.ClientStatus))
+	}
+	return completions
+}
+
+// TaskCompletions are the names of the tasks starting with prefix in the allocations that target, a prefix of a job
+// or allocation ID as given to exec, could refer to
+func (c CompletionCandidates) TaskCompletions(target, prefix string, runningOnly bool) []string {
+	return c.allocValues(target, runningOnly, func(alloc CompletionAlloc) []string {
+		var tasks []string
+		for _, task := range alloc.Tasks {
+			if strings.HasPrefix(task, prefix) {
+				tasks = append(tasks, task)
+			}
+		}
+		return tasks
+	})
+}
+
+// TaskGroupCompletions are the names of the task groups starting with prefix in the allocations that target could
+// refer to, see TaskCompletions
+func (c CompletionCandidates) TaskGroupCompletions(target, prefix string, runningOnly bool) []string {
+	return c.allocValues(target, runningOnly, func(alloc CompletionAlloc) []string {
+		if strings.HasPrefix(alloc.TaskGroup, prefix) {
+			return []string{alloc.TaskGroup}
+		}
+		return nil
+	})
+}
+
+// allocValues are the unique, sorted values of the allocations that target could refer to
+func (c CompletionCandidates) allocValues(target string, runningOnly bool, values func(CompletionAlloc) []string) []string {
+	if target == "" {
+		return nil
+	}
+	seen := make(map[string]bool)
+	var unique []string
+	for _, alloc := range c.Allocs {
+		if !strings.HasPrefix(alloc.JobID, target) && !strings.HasPrefix(alloc.ID, target) {
+			continue
+		}
+		if runningOnly && alloc.ClientStatus != "running" {
+			continue
+		}
+		for _, v := range values(alloc) {
+			if !seen[v] {
+				seen[v] = true
+				unique = append(unique, v)
+			}
+		}
+	}
+	sort.Strings(unique)
+	return unique
+}
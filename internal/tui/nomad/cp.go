@@ -0,0 +1,588 @@
+package nomad
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/hashicorp/nomad/api"
+	"github.com/robinovitch61/wander/internal/tui/formatter"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// copyCheckScript exits like a shell does for a missing command if tar isn't in the task, and prints whether the
+	// path given is a directory or another kind of file, printing nothing if it doesn't exist
+	copyCheckScript = `command -v tar >/dev/null 2>&1 || exit 127; if [ -d "$1" ]; then echo dir; elif [ -e "$1" ]; then echo file; fi`
+	// copyExtractScript extracts the archive on stdin into the directory given, creating it if needed
+	copyExtractScript = `mkdir -p "$1" && tar -xf - -C "$1"`
+	// copyArchiveScript archives the file or directory given as the second argument in the directory given as the first
+	copyArchiveScript = `cd "$1" && tar -cf - "$2"`
+	// copyChecksumScript prints the sha256 checksum of every file in the file or directory given as the second argument,
+	// relative to the directory given as the first
+	copyChecksumScript = `cd "$1" && find "$2" -type f -exec sha256sum {} +`
+)
+
+// CopyProgress counts the bytes copied so far, safe to read while copying
+type CopyProgress struct {
+	done  atomic.Int64
+	total atomic.Int64
+}
+
+// String is the bytes copied, with the percentage of the total if it's known before copying
+func (p *CopyProgress) String() string {
+	done, total := p.done.Load(), p.total.Load()
+	if total <= 0 {
+		return formatter.FormatBytes(done)
+	}
+	return fmt.Sprintf("%s / %s (%d%%)", formatter.FormatBytes(done), formatter.FormatBytes(total), min(100, done*100/total))
+}
+
+type progressWriter struct {
+	w        io.Writer
+	progress *CopyProgress
+}
+
+func (w progressWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.progress.done.Add(int64(n))
+	return n, err
+}
+
+type CopyResult struct {
+	Files int
+	Bytes int64
+	// Verified is true if the checksum of every file copied matched on both sides, otherwise Unverified says why they
+	// weren't compared
+	Verified   bool
+	Unverified string
+	// Skipped counts what isn't a regular file or directory, like symlinks, which aren't copied
+	Skipped int
+}
+
+// Summary describes what was copied, e.g. to show once complete
+func (r CopyResult) Summary() string {
+	files := "files"
+	if r.Files == 1 {
+		files = "file"
+	}
+	summary := fmt.Sprintf("copied %d %s (%s)", r.Files, files, formatter.FormatBytes(r.Bytes))
+	if r.Verified {
+		summary += ", sha256 checksums verified"
+	} else if r.Unverified != "" {
+		summary += fmt.Sprintf(", not verified: %s", r.Unverified)
+	}
+	if r.Skipped > 0 {
+		summary += fmt.Sprintf(", skipped %d symlinks or special files", r.Skipped)
+	}
+	return summary
+}
+
+// ParseCopyPath splits a path in a task, written as <alloc id or job>:<path>, into its parts. Other paths are local.
+func ParseCopyPath(p string) (string, string, bool) {
+	i := strings.Index(p, ":")
+	if i <= 0 {
+		return "", p, false
+	}
+	target := p[:i]
+	if strings.ContainsAny(target, `/\`) {
+		return "", p, false
+	}
+	if len(target) == 1 && len(p) > 2 && (p[2] == '\\' || p[2] == '/') {
+		// a windows drive, e.g. C:\
+		return "", p, false
+	}
+	return target, p[i+1:], true
+}
+
+// CopyToTask uploads a local file or directory to taskPath in the task as a tar archive over exec, which needs sh and
+// tar in the task. If taskPath is a directory, or ends with /, it's copied into it, otherwise it's copied to taskPath.
+// Checksums are compared after copying if the task has sha256sum.
+func CopyToTask(ctx context.Context, client *api.Client, alloc *api.Allocation, task, localPath, taskPath string, progress *CopyProgress) (CopyResult, error) {
+	var result CopyResult
+	localPath, err := filepath.Abs(localPath)
+	if err != nil {
+		return result, err
+	}
+	total, err := localCopySize(localPath)
+	if err != nil {
+		return result, err
+	}
+	progress.total.Store(total)
+
+	kind, err := checkTaskForCopy(ctx, client, alloc, task, taskPath)
+	if err != nil {
+		return result, err
+	}
+	dir, name := taskPath, filepath.Base(localPath)
+	if kind != "dir" && !strings.HasSuffix(taskPath, "/") {
+		dir, name = path.Dir(taskPath), path.Base(taskPath)
+	}
+
+	sums := make(map[string]string)
+	archive, archiveWriter := io.Pipe()
+	archived := make(chan error, 1)
+	go func() {
+		err := writeCopyArchive(archiveWriter, localPath, name, sums, &result, progress)
+		_ = archiveWriter.CloseWithError(err)
+		archived <- err
+	}()
+	code, stderr, err := execForCopy(ctx, client, alloc, task, []string{"sh", "-c", copyExtractScript, "sh", dir}, archive, io.Discard)
+	// stops the archive being written if the command ended early
+	_ = archive.CloseWithError(io.ErrClosedPipe)
+	archiveErr := <-archived
+	if err != nil {
+		return result, err
+	}
+	if archiveErr != nil && !errors.Is(archiveErr, io.ErrClosedPipe) {
+		return result, archiveErr
+	}
+	if code != 0 {
+		return result, copyCommandError("extracting in", task, code, stderr)
+	}
+	return verifyCopy(ctx, client, alloc, task, dir, name, sums, result)
+}
+
+// CopyFromTask downloads a file or directory at taskPath in the task to localPath as a tar archive over exec, which
+// needs sh and tar in the task. If localPath is a directory, or ends with a separator, it's copied into it, otherwise
+// it's copied to localPath. Checksums are compared after copying if the task has sha256sum.
+func CopyFromTask(ctx context.Context, client *api.Client, alloc *api.Allocation, task, taskPath, localPath string, progress *CopyProgress) (CopyResult, error) {
+	var result CopyResult
+	kind, err := checkTaskForCopy(ctx, client, alloc, task, taskPath)
+	if err != nil {
+		return result, err
+	}
+	if kind == "" {
+		return result, fmt.Errorf("%s not found in task %s", taskPath, task)
+	}
+	taskPath = strings.TrimSuffix(taskPath, "/")
+	dir, name := path.Dir(taskPath), path.Base(taskPath)
+	localDir, localName, err := localCopyDestination(localPath, name)
+	if err != nil {
+		return result, err
+	}
+
+	sums := make(map[string]string)
+	archive, archiveWriter := io.Pipe()
+	extracted := make(chan error, 1)
+	go func() {
+		err := extractCopyArchive(archive, localDir, name, localName, sums, &result, progress)
+		if err == nil {
+			// tar pads the archive past its end
+			_, _ = io.Copy(io.Discard, archive)
+		}
+		// stops the command's output being read if extracting failed
+		_ = archive.CloseWithError(err)
+		extracted <- err
+	}()
+	code, stderr, err := execForCopy(ctx, client, alloc, task, []string{"sh", "-c", copyArchiveScript, "sh", dir, name}, nil, archiveWriter)
+	_ = archiveWriter.Close()
+	extractErr := <-extracted
+	if extractErr != nil {
+		return result, fmt.Errorf("could not extract %s: %v", taskPath, extractErr)
+	}
+	if err != nil {
+		return result, err
+	}
+	if code != 0 {
+		return result, copyCommandError("archiving in", task, code, stderr)
+	}
+	return verifyCopy(ctx, client, alloc, task, dir, name, sums, result)
+}
+
+// CopyFromAllocFS downloads a file or directory at fsPath in the allocation directory, as with nomad alloc fs, to
+// localPath, which needs nothing in the task. Files are only checked against their sizes, as the file system api has
+// no checksums.
+func CopyFromAllocFS(client *api.Client, alloc *api.Allocation, fsPath, localPath string, progress *CopyProgress) (CopyResult, error) {
+	result := CopyResult{Unverified: "checksums aren't available from the allocation file system, only sizes were compared"}
+	info, _, err := client.AllocFS().Stat(alloc, fsPath, nil)
+	if err != nil {
+		return result, err
+	}
+	fsPath = strings.TrimSuffix(fsPath, "/")
+	localDir, localName, err := localCopyDestination(localPath, path.Base(fsPath))
+	if err != nil {
+		return result, err
+	}
+
+	files := []allocFSFile{{path: fsPath, size: info.Size}}
+	if info.IsDir {
+		files, err = listAllocFSFiles(client, alloc, fsPath, "")
+		if err != nil {
+			return result, err
+		}
+		if err := os.MkdirAll(filepath.Join(localDir, localName), 0755); err != nil {
+			return result, err
+		}
+	}
+	var total int64
+	for _, f := range files {
+		total += f.size
+	}
+	progress.total.Store(total)
+
+	for _, f := range files {
+		target := filepath.Join(localDir, localName, filepath.FromSlash(f.rel))
+		n, err := copyAllocFSFile(client, alloc, f.path, target, progress)
+		if err != nil {
+			return result, err
+		}
+		if n != f.size {
+			return result, fmt.Errorf("%s is %d bytes but %d were copied", f.path, f.size, n)
+		}
+		result.Files++
+		result.Bytes += n
+	}
+	return result, nil
+}
+
+type allocFSFile struct {
+	path string
+	// rel is the path relative to the directory being copied, empty if a single file is
+	rel  string
+	size int64
+}
+
+func listAllocFSFiles(client *api.Client, alloc *api.Allocation, dir, rel string) ([]allocFSFile, error) {
+	infos, _, err := client.AllocFS().List(alloc, dir, nil)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	var files []allocFSFile
+	for _, info := range infos {
+		p, r := path.Join(dir, info.Name), path.Join(rel, info.Name)
+		if info.IsDir {
+			dirFiles, err := listAllocFSFiles(client, alloc, p, r)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, dirFiles...)
+			continue
+		}
+		files = append(files, allocFSFile{path: p, rel: r, size: info.Size})
+	}
+	return files, nil
+}
+
+func copyAllocFSFile(client *api.Client, alloc *api.Allocation, fsPath, target string, progress *CopyProgress) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return 0, err
+	}
+	r, err := client.AllocFS().Cat(alloc, fsPath, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+	f, err := os.Create(target)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(progressWriter{f, progress}, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return n, err
+}
+
+// execForCopy runs a non-interactive command in the task, returning its exit code and what it wrote to stderr
+func execForCopy(ctx context.Context, client *api.Client, alloc *api.Allocation, task string, command []string, stdin io.Reader, stdout io.Writer) (int, string, error) {
+	if stdin == nil {
+		stdin = strings.NewReader("")
+	}
+	var stderr bytes.Buffer
+	// a nil terminal size channel, as there is no tty
+	code, err := client.Allocations().Exec(ctx, alloc, task, false, command, stdin, stdout, &stderr, nil, nil)
+	return code, strings.TrimSpace(stderr.String()), err
+}
+
+// checkTaskForCopy fails clearly if the task can't be copied to or from, returning "dir" or "file" for what is at
+// taskPath, or "" if nothing is
+func checkTaskForCopy(ctx context.Context, client *api.Client, alloc *api.Allocation, task, taskPath string) (string, error) {
+	var stdout bytes.Buffer
+	code, stderr, err := execForCopy(ctx, client, alloc, task, []string{"sh", "-c", copyCheckScript, "sh", taskPath}, nil, &stdout)
+	if err != nil {
+		return "", err
+	}
+	if code != 0 {
+		return "", copyCommandError("checking", task, code, stderr)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+func copyCommandError(doing, task string, code int, stderr string) error {
+	if code == 126 || code == 127 {
+		return fmt.Errorf("tar not found in task %s: copying files needs sh and tar in the task's image", task)
+	}
+	if stderr == "" {
+		return fmt.Errorf("%s task %s failed with exit code %d", doing, task, code)
+	}
+	return fmt.Errorf("%s task %s failed with exit code %d: %s", doing, task, code, stderr)
+}
+
+// verifyCopy compares the checksums of the files copied with those in the task, leaving the result unverified if the
+// task can't compute them
+func verifyCopy(ctx context.Context, client *api.Client, alloc *api.Allocation, task, dir, name string, sums map[string]string, result CopyResult) (CopyResult, error) {
+	var stdout bytes.Buffer
+	code, stderr, err := execForCopy(ctx, client, alloc, task, []string{"sh", "-c", copyChecksumScript, "sh", dir, name}, nil, &stdout)
+	if err != nil {
+		return result, err
+	}
+	if code != 0 {
+		result.Unverified = fmt.Sprintf("could not compute checksums in task %s", task)
+		if stderr != "" {
+			result.Unverified += fmt.Sprintf(": %s", strings.SplitN(stderr, "\n", 2)[0])
+		}
+		return result, nil
+	}
+
+	taskSums := make(map[string]string)
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		if sum, file, found := strings.Cut(line, "  "); found {
+			taskSums[strings.TrimPrefix(file, "./")] = sum
+		}
+	}
+	var files []string
+	for file := range sums {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		taskSum, exists := taskSums[file]
+		if !exists {
+			return result, fmt.Errorf("%s is missing from task %s after copying", file, task)
+		}
+		if taskSum != sums[file] {
+			return result, fmt.Errorf("checksum mismatch for %s: %s locally but %s in task %s", file, sums[file], taskSum, task)
+		}
+	}
+	result.Verified = true
+	return result, nil
+}
+
+// localCopySize is the total size of the regular files at localPath
+func localCopySize(localPath string) (int64, error) {
+	var total int64
+	err := filepath.WalkDir(localPath, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			total += info.Size()
+		}
+		return nil
+	})
+	return total, err
+}
+
+// localCopyDestination is the directory that a file or directory named name is copied into locally, and its name
+// there
+func localCopyDestination(localPath, name string) (string, string, error) {
+	if strings.HasSuffix(localPath, string(os.PathSeparator)) || strings.HasSuffix(localPath, "/") {
+		if err := os.MkdirAll(localPath, 0755); err != nil {
+			return "", "", err
+		}
+		return localPath, name, nil
+	}
+	if info, err := os.Stat(localPath); err == nil && info.IsDir() {
+		return localPath, name, nil
+	}
+	return filepath.Dir(localPath), filepath.Base(localPath), nil
+}
+
+// writeCopyArchive writes localPath as a tar archive, named name in it, recording the checksum of each file in sums by
+// its path in the archive
+func writeCopyArchive(w io.Writer, localPath, name string, sums map[string]string, result *CopyResult, progress *CopyProgress) error {
+	tw := tar.NewWriter(w)
+	err := filepath.WalkDir(localPath, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(localPath, p)
+		if err != nil {
+			return err
+		}
+		entryName := name
+		if rel != "." {
+			entryName = path.Join(name, filepath.ToSlash(rel))
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case info.IsDir():
+			return tw.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeDir,
+				Name:     entryName + "/",
+				Mode:     int64(info.Mode().Perm()),
+				ModTime:  info.ModTime(),
+			})
+		case info.Mode().IsRegular():
+			err := tw.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeReg,
+				Name:     entryName,
+				Mode:     int64(info.Mode().Perm()),
+				Size:     info.Size(),
+				ModTime:  info.ModTime(),
+			})
+			if err != nil {
+				return err
+			}
+			f, err := os.Open(p)
+			if err != nil {
+				return err
+			}
+			hash := sha256.New()
+			n, err := io.Copy(io.MultiWriter(progressWriter{tw, progress}, hash), f)
+			_ = f.Close()
+			if err != nil {
+				return err
+			}
+			sums[entryName] = hex.EncodeToString(hash.Sum(nil))
+			result.Files++
+			result.Bytes += n
+		default:
+			result.Skipped++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// extractCopyArchive extracts a tar archive of a file or directory named name into dir, naming it localName,
+// recording the checksum of each file in sums by its path in the archive
+func extractCopyArchive(r io.Reader, dir, name, localName string, sums map[string]string, result *CopyResult, progress *CopyProgress) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		entryName := path.Clean(strings.TrimPrefix(hdr.Name, "./"))
+		rel := strings.TrimPrefix(entryName, name)
+		if !strings.HasPrefix(entryName, name) || (rel != "" && !strings.HasPrefix(rel, "/")) {
+			return fmt.Errorf("unexpected path %s in archive", hdr.Name)
+		}
+		for _, part := range strings.Split(rel, "/") {
+			if part == ".." {
+				return fmt.Errorf("unexpected path %s in archive", hdr.Name)
+			}
+		}
+		target := filepath.Join(dir, localName, filepath.FromSlash(rel))
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, hdr.FileInfo().Mode().Perm())
+			if err != nil {
+				return err
+			}
+			hash := sha256.New()
+			n, err := io.Copy(io.MultiWriter(progressWriter{f, progress}, hash), tr)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return err
+			}
+			sums[entryName] = hex.EncodeToString(hash.Sum(nil))
+			result.Files++
+			result.Bytes += n
+		default:
+			result.Skipped++
+		}
+	}
+}
+
+type CopyProgressTickMsg struct {
+	ID int
+}
+
+type CopyCompleteMsg struct {
+	ID     int
+	Result CopyResult
+	Err    error
+}
+
+// ParseCopyInput parses what to copy from the copy page, either "download <task path> [local path]" or
+// "upload <local path> <task path>"
+func ParseCopyInput(input string) (upload bool, localPath, taskPath string, err error) {
+	args, err := SplitCommand(input)
+	if err != nil {
+		return false, "", "", err
+	}
+	switch {
+	case args[0] == "download" && (len(args) == 2 || len(args) == 3):
+		localPath = "."
+		if len(args) == 3 {
+			localPath = args[2]
+		}
+		taskPath = args[1]
+	case args[0] == "upload" && len(args) == 3:
+		upload, localPath, taskPath = true, args[1], args[2]
+	default:
+		return false, "", "", fmt.Errorf("enter download <task path> [local path] or upload <local path> <task path>")
+	}
+	if localPath == "~" || strings.HasPrefix(localPath, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return false, "", "", err
+		}
+		localPath = filepath.Join(home, strings.TrimPrefix(localPath, "~"))
+	}
+	return upload, localPath, taskPath, nil
+}
+
+// RunCopy copies between the local path and the path in the task in the background, reporting progress to progress
+func RunCopy(client api.Client, id int, allocID, task string, upload bool, localPath, taskPath string, progress *CopyProgress, readOnly bool) tea.Cmd {
+	return func() tea.Msg {
+		if readOnly {
+			return CopyCompleteMsg{ID: id, Err: ErrReadOnly}
+		}
+		alloc, _, err := client.Allocations().Info(allocID, nil)
+		if err != nil {
+			return CopyCompleteMsg{ID: id, Err: err}
+		}
+		var result CopyResult
+		if upload {
+			result, err = CopyToTask(context.Background(), &client, alloc, task, localPath, taskPath, progress)
+		} else {
+			result, err = CopyFromTask(context.Background(), &client, alloc, task, taskPath, localPath, progress)
+		}
+		return CopyCompleteMsg{ID: id, Result: result, Err: err}
+	}
+}
+
+// CopyProgressTick schedules the next update of the progress of the copy with the id
+func CopyProgressTick(id int) tea.Cmd {
+	return tea.Tick(500*time.Millisecond, func(t time.Time) tea.Msg { return CopyProgressTickMsg{ID: id} })
+}
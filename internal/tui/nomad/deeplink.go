@@ -0,0 +1,101 @@
+package nomad
+
+import (
+	"fmt"
+	"github.com/hashicorp/nomad/api"
+	"net/url"
+	"strings"
+)
+
+// DeepLinkScheme starts links to the page to start wander on, e.g. wander://logs/my-job/my-task?type=stderr
+const DeepLinkScheme = "wander://"
+
+// DeepLink is a page to start on instead of the jobs page, along with what the pages back from it show
+type DeepLink struct {
+	Page Page
+	// Target is the job, or for the pages of a task, the job or allocation ID, either of which can be a prefix
+	Target    string
+	Task      string
+	Namespace string
+	LogType   LogType
+
+	// JobID, JobNamespace and Alloc are found by ResolveDeepLink, Alloc only for the pages of a task
+	JobID, JobNamespace string
+	Alloc               *api.Allocation
+}
+
+// ParseDeepLink parses a link to a page, one of
+//
+//	wander://job/<job>[?namespace=<namespace>]              the tasks of a job
+//	wander://events/<job>[?namespace=<namespace>]           the events of a job
+//	wander://logs/<alloc id or job>[/<task>][?type=stderr]  the logs of a task
+//	wander://stats/<alloc id or job>[/<task>]               the stats of an allocation
+func ParseDeepLink(link string) (DeepLink, error) {
+	if !strings.HasPrefix(link, DeepLinkScheme) {
+		return DeepLink{}, fmt.Errorf("%s is not a link to a page, which start with %s", link, DeepLinkScheme)
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return DeepLink{}, err
+	}
+	var parts []string
+	if path := strings.Trim(u.Path, "/"); path != "" {
+		parts = strings.Split(path, "/")
+	}
+	deepLink := DeepLink{Namespace: u.Query().Get("namespace")}
+
+	switch u.Host {
+	case "job", "events":
+		if len(parts) != 1 {
+			return DeepLink{}, fmt.Errorf("link %s should be %s%s/<job>", link, DeepLinkScheme, u.Host)
+		}
+		deepLink.Page = JobTasksPage
+		if u.Host == "events" {
+			deepLink.Page = JobEventsPage
+		}
+	case "logs", "stats":
+		if len(parts) != 1 && len(parts) != 2 {
+			return DeepLink{}, fmt.Errorf("link %s should be %s%s/<alloc id or job>[/<task>]", link, DeepLinkScheme, u.Host)
+		}
+		if len(parts) == 2 {
+			deepLink.Task = parts[1]
+		}
+		deepLink.Page = StatsPage
+		if u.Host == "logs" {
+			deepLink.Page = LogsPage
+			switch logType := u.Query().Get("type"); logType {
+			case "", StdOut.ShortString():
+			case StdErr.ShortString():
+				deepLink.LogType = StdErr
+			default:
+				return DeepLink{}, fmt.Errorf("log type in link %s should be stdout or stderr, not %s", link, logType)
+			}
+		}
+	default:
+		return DeepLink{}, fmt.Errorf("link %s should be to one of job, events, logs or stats, e.g. %sjob/<job>", link, DeepLinkScheme)
+	}
+	deepLink.Target = parts[0]
+	return deepLink, nil
+}
+
+// ResolveDeepLink finds the job, and for the pages of a task, the allocation and task that the link refers to, using
+// the same prefix matching as exec. found is false if the candidates for an ambiguous task were printed, see
+// FindExecTask.
+func ResolveDeepLink(client *api.Client, link DeepLink, pick ExecCandidatePicker) (DeepLink, bool, error) {
+	switch link.Page {
+	case LogsPage, StatsPage:
+		alloc, task, found, err := FindExecTask(client, link.Target, link.Task, pick)
+		if err != nil || !found {
+			return link, found, err
+		}
+		link.Alloc, link.Task = alloc, task
+		link.JobID, link.JobNamespace = alloc.JobID, alloc.Namespace
+	default:
+		job, err := findJob(*client, link.Target, link.Namespace)
+		if err != nil {
+			return link, false, err
+		}
+		link.JobID, link.JobNamespace = job.ID, job.Namespace
+	}
+	return link, true, nil
+}
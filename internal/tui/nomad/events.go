@@ -3,14 +3,19 @@ package nomad
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/hashicorp/nomad/api"
 	"github.com/itchyny/gojq"
 	"github.com/robinovitch61/wander/internal/tui/message"
+	"io"
 	"strings"
+	"time"
 )
 
+const maxEventsReconnectDelay = 30 * time.Second
+
 type Topics map[api.Topic][]string
 
 type Event struct {
@@ -18,23 +23,98 @@ type Event struct {
 }
 
 type EventsStreamMsg struct {
-	Events []Event
-	Topics Topics
+	StreamID int
+	Events   []Event
+	Topics   Topics
+	// Index is the raft index of the events
+	Index uint64
+	// Raw is the JSON of the whole batch of events, including any the jq query filtered out
+	Raw string
+	// Empty is true if the batch has no events, e.g. the heartbeats that keep the stream open
+	Empty bool
+}
+
+// EventsStreamClosedMsg is sent when an events stream errors or closes. Err is nil if it closed without error.
+type EventsStreamClosedMsg struct {
+	StreamID int
+	Err      error
+}
+
+// ReconnectEventsStreamMsg is sent once it's time to try reconnecting a closed events stream
+type ReconnectEventsStreamMsg struct {
+	StreamID int
+}
+
+// EventsStreamResumedMsg replaces an events stream that closed with one that continues from where it left off
+type EventsStreamResumedMsg struct {
+	// PrevStreamID is the ID of the stream that closed
+	PrevStreamID int
+	EventsStream EventsStream
 }
 
 func FetchEventsStream(client api.Client, topics Topics, namespace string, page Page) tea.Cmd {
 	return func() tea.Msg {
-		eventsChan, err := client.EventStream().Stream(context.Background(), topics, 0, &api.QueryOptions{Namespace: namespace})
+		eventsStream, err := openEventsStream(client, topics, namespace, 0)
 		if err != nil {
 			return message.ErrMsg{Err: err}
 		}
-		return PageLoadedMsg{Page: page, EventsStream: EventsStream{Chan: eventsChan, Topics: topics, Namespace: namespace}}
+		return PageLoadedMsg{Page: page, EventsStream: eventsStream}
+	}
+}
+
+// ResumeEventsStream reopens a closed events stream from the event after the last one it received
+func ResumeEventsStream(client api.Client, closed EventsStream) tea.Cmd {
+	return func() tea.Msg {
+		fromIndex := closed.LastIndex
+		if fromIndex > 0 {
+			fromIndex++
+		}
+		eventsStream, err := openEventsStream(client, closed.Topics, closed.Namespace, fromIndex)
+		if err != nil {
+			return EventsStreamClosedMsg{StreamID: closed.ID, Err: err}
+		}
+		eventsStream.LastIndex = closed.LastIndex
+		return EventsStreamResumedMsg{PrevStreamID: closed.ID, EventsStream: eventsStream}
+	}
+}
+
+func openEventsStream(client api.Client, topics Topics, namespace string, index uint64) (EventsStream, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	eventsChan, err := client.EventStream().Stream(ctx, topics, index, &api.QueryOptions{Namespace: namespace})
+	if err != nil {
+		cancel()
+		return EventsStream{}, err
 	}
+	return EventsStream{
+		Chan:      eventsChan,
+		Topics:    topics,
+		Namespace: namespace,
+		Cancel:    cancel,
+	}, nil
+}
+
+// EventsReconnectDelay is how long to wait before reconnecting a closed events stream, doubling with each
+// failed attempt up to a maximum
+func EventsReconnectDelay(attempt int) time.Duration {
+	delay := time.Second
+	for i := 1; i < attempt && delay < maxEventsReconnectDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxEventsReconnectDelay {
+		return maxEventsReconnectDelay
+	}
+	return delay
 }
 
 func ReadEventsStreamNextMessage(c EventsStream, code *gojq.Code) tea.Cmd {
 	return func() tea.Msg {
-		line := <-c.Chan
+		line, ok := <-c.Chan
+		if !ok {
+			return EventsStreamClosedMsg{StreamID: c.ID}
+		}
+		if line.Err != nil {
+			return EventsStreamClosedMsg{StreamID: c.ID, Err: line.Err}
+		}
 		lineBytes, err := json.Marshal(line)
 		if err != nil {
 			return message.ErrMsg{Err: err}
@@ -44,7 +124,7 @@ func ReadEventsStreamNextMessage(c EventsStream, code *gojq.Code) tea.Cmd {
 		if err != nil {
 			return message.ErrMsg{Err: err}
 		}
-		return EventsStreamMsg{Events: events, Topics: c.Topics}
+		return EventsStreamMsg{StreamID: c.ID, Events: events, Topics: c.Topics, Index: line.Index, Raw: trimmed, Empty: len(line.Events) == 0}
 	}
 }
 
@@ -104,3 +184,98 @@ func getEventsFromJQQuery(event string, code *gojq.Code) ([]Event, error) {
 	}
 	return events, nil
 }
+
+// errEventsCountReached stops StreamEvents once it has written the events asked for
+var errEventsCountReached = errors.New("events count reached")
+
+// StreamEvents writes each event of the topics from sinceIndex, or from now if it's 0, to out as a line of JSON
+// projected by code, until ctx is done or count events are written if count is more than 0. A stream that closes is
+// reopened from the event after the last one it received. jq errors are passed to onError rather than written.
+func StreamEvents(ctx context.Context, client api.Client, topics Topics, namespace string, sinceIndex uint64, code *gojq.Code, count int, out io.Writer, onReconnect, onError func(err error)) error {
+	written := 0
+	lastIndex := uint64(0)
+	opened := false
+	for attempt := 1; ; attempt++ {
+		fromIndex := sinceIndex
+		if lastIndex > 0 {
+			fromIndex = lastIndex + 1
+		}
+		eventsChan, err := client.EventStream().Stream(ctx, topics, fromIndex, &api.QueryOptions{Namespace: namespace})
+		if err != nil && !opened {
+			// e.g. not authorized, which reconnecting won't fix
+			return err
+		}
+		if err == nil {
+			opened = true
+			prevIndex := lastIndex
+			err = writeEvents(ctx, eventsChan, code, out, &lastIndex, onError, func() bool {
+				written++
+				return count > 0 && written >= count
+			})
+			if lastIndex != prevIndex {
+				attempt = 1
+			}
+		}
+		if errors.Is(err, errEventsCountReached) || ctx.Err() != nil {
+			return nil
+		}
+
+		onReconnect(err)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(EventsReconnectDelay(attempt)):
+		}
+	}
+}
+
+// writeEvents writes the events of eventsChan as they arrive, setting lastIndex to the index of each batch and calling
+// onWritten for each event written, which returns true to stop
+func writeEvents(ctx context.Context, eventsChan <-chan *api.Events, code *gojq.Code, out io.Writer, lastIndex *uint64, onError func(err error), onWritten func() bool) error {
+	for {
+		var line *api.Events
+		var ok bool
+		select {
+		case <-ctx.Done():
+			return nil
+		case line, ok = <-eventsChan:
+		}
+		if !ok {
+			return nil
+		}
+		if line.Err != nil {
+			return line.Err
+		}
+		*lastIndex = line.Index
+		lineBytes, err := json.Marshal(line)
+		if err != nil {
+			return err
+		}
+		batch := make(map[string]interface{})
+		if err := json.Unmarshal(lineBytes, &batch); err != nil {
+			return err
+		}
+		iter := code.RunWithContext(ctx, batch)
+		for {
+			v, ok := iter.Next()
+			if !ok {
+				break
+			}
+			if err, ok := v.(error); ok {
+				onError(fmt.Errorf("events jq error: %s", err))
+				continue
+			}
+			j, err := json.Marshal(v)
+			if err != nil {
+				onError(fmt.Errorf("events jq json error: %s", err))
+				continue
+			}
+			if _, err := fmt.Fprintf(out, "%s\n", j); err != nil {
+				return err
+			}
+			if onWritten() {
+				return errEventsCountReached
+			}
+		}
+	}
+}
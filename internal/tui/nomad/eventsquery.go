@@ -0,0 +1,122 @@
+package nomad
+
+import (
+	"fmt"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/hashicorp/nomad/api"
+	"github.com/itchyny/gojq"
+	"github.com/robinovitch61/wander/internal/tui/components/page"
+	"github.com/robinovitch61/wander/internal/tui/formatter"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// allTopics are the topics that can be followed in event streams. The api package doesn't define the ACL ones.
+var allTopics = []api.Topic{
+	api.TopicDeployment,
+	api.TopicEvaluation,
+	api.TopicAllocation,
+	api.TopicJob,
+	api.TopicNode,
+	api.TopicNodePool,
+	api.TopicService,
+	"ACLToken",
+	"ACLPolicy",
+	"ACLRole",
+	api.TopicAll,
+}
+
+// EventsPreset is a named set of event topics and jq query, saved in the config file
+type EventsPreset struct {
+	Name    string `mapstructure:"name" yaml:"name"`
+	Topics  string `mapstructure:"topics" yaml:"topics"`
+	JQQuery string `mapstructure:"jq_query" yaml:"jq_query"`
+}
+
+// ParseTopics parses comma-separated topics, each optionally followed by a key to filter on, e.g. Job:my-job,Node
+func ParseTopics(s string) (Topics, error) {
+	topics := make(Topics)
+	for _, t := range strings.Split(s, ",") {
+		split := strings.SplitN(strings.TrimSpace(t), ":", 2)
+		suffix := "*"
+		if len(split) == 2 {
+			suffix = strings.TrimSpace(split[1])
+		}
+		if suffix == "" {
+			return nil, fmt.Errorf("%s has no key after the colon", t)
+		}
+
+		topic, err := matchTopic(strings.TrimSpace(split[0]))
+		if err != nil {
+			return nil, err
+		}
+		topics[topic] = append(topics[topic], suffix)
+	}
+	return topics, nil
+}
+
+func matchTopic(t string) (api.Topic, error) {
+	for _, topic := range allTopics {
+		if strings.EqualFold(t, string(topic)) {
+			return topic, nil
+		}
+	}
+	var names []string
+	for _, topic := range allTopics {
+		names = append(names, string(topic))
+	}
+	return "", fmt.Errorf("%q cannot be parsed into topic, must be one of %s", t, strings.Join(names, ", "))
+}
+
+// FormatTopics is the inverse of ParseTopics, sorted by topic and key
+func FormatTopics(topics Topics) string {
+	var formatted []string
+	for topic, keys := range topics {
+		for _, k := range keys {
+			if k == "*" {
+				formatted = append(formatted, string(topic))
+			} else {
+				formatted = append(formatted, fmt.Sprintf("%s:%s", topic, k))
+			}
+		}
+	}
+	sort.Strings(formatted)
+	return strings.Join(formatted, ",")
+}
+
+// CompileJQQuery parses and compiles a jq query for events
+func CompileJQQuery(query string) (*gojq.Code, error) {
+	parsed, err := gojq.Parse(query)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing jq query: %v", err)
+	}
+	code, err := gojq.Compile(parsed)
+	if err != nil {
+		return nil, fmt.Errorf("error compiling jq query: %v", err)
+	}
+	return code, nil
+}
+
+// FetchEventsPresets lists the presets, keyed by their index in presets
+func FetchEventsPresets(presets []EventsPreset) tea.Cmd {
+	return func() tea.Msg {
+		if len(presets) == 0 {
+			return PageLoadedMsg{
+				Page:        EventsPresetsPage,
+				TableHeader: []string{"No presets yet"},
+				AllPageRows: []page.Row{},
+			}
+		}
+		var data [][]string
+		for _, p := range presets {
+			data = append(data, []string{p.Name, p.Topics, p.JQQuery})
+		}
+		table := formatter.GetRenderedTableAsString([]string{"Name", "Topics", "JQ Query"}, data)
+		var rows []page.Row
+		for idx, row := range table.ContentRows {
+			rows = append(rows, page.Row{Key: strconv.Itoa(idx), Row: row})
+		}
+		return PageLoadedMsg{Page: EventsPresetsPage, TableHeader: table.HeaderRows, AllPageRows: rows}
+	}
+}
@@ -0,0 +1,136 @@
+package nomad
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/itchyny/gojq"
+	"github.com/robinovitch61/wander/internal/tui/components/page"
+	"github.com/robinovitch61/wander/internal/tui/message"
+	"os"
+	"time"
+)
+
+// recordedAtKey is added to each batch of events in recordings with the time the batch was received
+const recordedAtKey = "RecordedAt"
+
+// maxRecordedEventsLineBytes is the longest line read from a recording, as a batch of events can be large
+const maxRecordedEventsLineBytes = 64 * 1024 * 1024
+
+// RecordedEvents is a batch of events as received from an events stream, recorded as a line of NDJSON
+type RecordedEvents struct {
+	RecordedAt time.Time
+	// Raw is the JSON of the batch as received from the stream, see EventsStreamMsg
+	Raw string
+}
+
+type ReplayEventsTickMsg struct {
+	ID int
+}
+
+// RecordedEventsLine returns the NDJSON line recording a batch of events received at recordedAt
+func RecordedEventsLine(raw string, recordedAt time.Time) (string, error) {
+	batch, err := decodeJSONObject([]byte(raw))
+	if err != nil {
+		return "", err
+	}
+	batch[recordedAtKey] = recordedAt.Format(time.RFC3339Nano)
+	line, err := json.Marshal(batch)
+	if err != nil {
+		return "", err
+	}
+	return string(line) + "\n", nil
+}
+
+// ReadRecordedEvents reads a recording of events, see RecordedEventsLine
+func ReadRecordedEvents(path string) ([]RecordedEvents, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var recorded []RecordedEvents
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, maxRecordedEventsLineBytes)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		batch, err := decodeJSONObject(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d of %s: %v", lineNum, path, err)
+		}
+		recordedAtString, _ := batch[recordedAtKey].(string)
+		recordedAt, err := time.Parse(time.RFC3339Nano, recordedAtString)
+		if err != nil {
+			return nil, fmt.Errorf("line %d of %s has no valid %s: %v", lineNum, path, recordedAtKey, err)
+		}
+		delete(batch, recordedAtKey)
+		raw, err := json.Marshal(batch)
+		if err != nil {
+			return nil, err
+		}
+		recorded = append(recorded, RecordedEvents{RecordedAt: recordedAt, Raw: string(raw)})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(recorded) == 0 {
+		return nil, fmt.Errorf("no recorded events in %s", path)
+	}
+	return recorded, nil
+}
+
+// FetchEventsReplay reads the recording at path, showing the events that have already been replayed
+func FetchEventsReplay(path string, replayed int, code *gojq.Code) tea.Cmd {
+	return func() tea.Msg {
+		recorded, err := ReadRecordedEvents(path)
+		if err != nil {
+			return message.ErrMsg{Err: err}
+		}
+		if replayed > len(recorded) {
+			replayed = len(recorded)
+		}
+		return PageLoadedMsg{
+			Page:           ReplayEventsPage,
+			TableHeader:    []string{},
+			AllPageRows:    RecordedEventsAsRows(recorded[:replayed], code),
+			RecordedEvents: recorded,
+		}
+	}
+}
+
+// RecordedEventsAsRows renders recorded events through the jq query the same way as events received from a stream
+func RecordedEventsAsRows(recorded []RecordedEvents, code *gojq.Code) []page.Row {
+	var rows []page.Row
+	for _, r := range recorded {
+		events, err := getEventsFromJQQuery(r.Raw, code)
+		if err != nil {
+			continue
+		}
+		for _, event := range events {
+			if event.CompleteValue == "{}" {
+				continue
+			}
+			rows = append(rows, page.Row{Key: event.CompleteValue, Row: event.JQValue})
+		}
+	}
+	return rows
+}
+
+// decodeJSONObject keeps numbers as they are, e.g. so large indexes don't lose precision
+func decodeJSONObject(b []byte) (map[string]interface{}, error) {
+	var obj map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	if err := decoder.Decode(&obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
@@ -0,0 +1,220 @@
+package nomad
+
+import (
+	"encoding/json"
+	"fmt"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/itchyny/gojq"
+	"github.com/robinovitch61/wander/internal/tui/components/page"
+	"github.com/robinovitch61/wander/internal/tui/formatter"
+	"sort"
+	"time"
+)
+
+// maxEventsPerSummaryGroup is how many of the most recent events are kept for each group to drill into
+const maxEventsPerSummaryGroup = 1000
+
+type EventsSummaryTickMsg struct {
+	ID int
+}
+
+// rateBuckets count events in a sliding window of 60 buckets, each bucketSize long
+type rateBuckets struct {
+	bucketSize time.Duration
+	counts     [60]int
+	// starts are the start times of the buckets, so stale counts from earlier windows are ignored
+	starts [60]time.Time
+}
+
+func (b *rateBuckets) add(t time.Time) {
+	start := t.Truncate(b.bucketSize)
+	idx := int(start.UnixNano()/int64(b.bucketSize)) % len(b.counts)
+	if !b.starts[idx].Equal(start) {
+		b.starts[idx] = start
+		b.counts[idx] = 0
+	}
+	b.counts[idx]++
+}
+
+// total counts events in the window ending at now
+func (b rateBuckets) total(now time.Time) int {
+	windowStart := now.Truncate(b.bucketSize).Add(-b.bucketSize * time.Duration(len(b.counts)-1))
+	total := 0
+	for i, start := range b.starts {
+		if !start.Before(windowStart) && !start.After(now) {
+			total += b.counts[i]
+		}
+	}
+	return total
+}
+
+type EventsGroup struct {
+	Key      string
+	Count    int
+	LastSeen time.Time
+	lastMin  rateBuckets
+	lastHour rateBuckets
+	// Events are the most recent events in the group, each as the JSON of a batch containing only that event
+	Events []string
+}
+
+// EventsSummary groups events by the output of a jq query run on each event, e.g. by topic and type
+type EventsSummary struct {
+	code   *gojq.Code
+	groups map[string]*EventsGroup
+}
+
+func NewEventsSummary(code *gojq.Code) EventsSummary {
+	return EventsSummary{code: code, groups: make(map[string]*EventsGroup)}
+}
+
+// Add counts the events in a batch received from an events stream, see EventsStreamMsg.Raw
+func (s *EventsSummary) Add(raw string, at time.Time) error {
+	var batch struct {
+		Index  uint64
+		Events []map[string]interface{}
+	}
+	if err := json.Unmarshal([]byte(raw), &batch); err != nil {
+		return err
+	}
+	for _, event := range batch.Events {
+		eventJSON, err := json.Marshal(map[string]interface{}{"Index": batch.Index, "Events": []interface{}{event}})
+		if err != nil {
+			return err
+		}
+		for _, key := range s.groupKeys(event) {
+			group, exists := s.groups[key]
+			if !exists {
+				group = &EventsGroup{
+					Key:      key,
+					lastMin:  rateBuckets{bucketSize: time.Second},
+					lastHour: rateBuckets{bucketSize: time.Minute},
+				}
+				s.groups[key] = group
+			}
+			group.Count++
+			group.LastSeen = at
+			group.lastMin.add(at)
+			group.lastHour.add(at)
+			group.Events = append(group.Events, string(eventJSON))
+			if len(group.Events) > maxEventsPerSummaryGroup {
+				group.Events = append([]string(nil), group.Events[len(group.Events)-maxEventsPerSummaryGroup:]...)
+			}
+		}
+	}
+	return nil
+}
+
+// groupKeys are the outputs of the summary's jq query for an event, so an event can be in more than one group
+func (s EventsSummary) groupKeys(event map[string]interface{}) []string {
+	var keys []string
+	iter := s.code.Run(event)
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			return keys
+		}
+		switch value := v.(type) {
+		case error:
+			keys = append(keys, fmt.Sprintf("jq error: %s", value))
+		case string:
+			keys = append(keys, value)
+		case nil:
+			keys = append(keys, "null")
+		default:
+			j, err := json.Marshal(value)
+			if err != nil {
+				keys = append(keys, fmt.Sprintf("jq json error: %s", err))
+				continue
+			}
+			keys = append(keys, string(j))
+		}
+	}
+}
+
+func (s EventsSummary) Group(key string) (EventsGroup, bool) {
+	group, exists := s.groups[key]
+	if !exists {
+		return EventsGroup{}, false
+	}
+	return *group, true
+}
+
+// AsTable shows the groups with the most events in the last minute first, then the most events overall
+func (s EventsSummary) AsTable(now time.Time) ([]string, []page.Row) {
+	if len(s.groups) == 0 {
+		return []string{"No events yet"}, []page.Row{}
+	}
+	type groupStats struct {
+		group             *EventsGroup
+		lastMin, lastHour int
+	}
+	var stats []groupStats
+	for _, g := range s.groups {
+		stats = append(stats, groupStats{g, g.lastMin.total(now), g.lastHour.total(now)})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].lastMin != stats[j].lastMin {
+			return stats[i].lastMin > stats[j].lastMin
+		}
+		if stats[i].group.Count != stats[j].group.Count {
+			return stats[i].group.Count > stats[j].group.Count
+		}
+		return stats[i].group.Key < stats[j].group.Key
+	})
+
+	var data [][]string
+	for _, st := range stats {
+		data = append(data, []string{
+			st.group.Key,
+			fmt.Sprint(st.group.Count),
+			fmt.Sprint(st.lastMin),
+			fmt.Sprint(st.lastHour),
+			formatter.FormatTime(st.group.LastSeen),
+		})
+	}
+	table := formatter.GetRenderedTableAsString([]string{"Group", "Count", "Last Minute", "Last Hour", "Last Seen"}, data)
+	var rows []page.Row
+	for idx, row := range table.ContentRows {
+		rows = append(rows, page.Row{Key: stats[idx].group.Key, Row: row})
+	}
+	return table.HeaderRows, rows
+}
+
+// FetchEventsSummary shows the summary as of now. The table is rendered before returning as the summary keeps
+// changing as events are received.
+func FetchEventsSummary(summary EventsSummary) tea.Cmd {
+	tableHeader, allPageRows := summary.AsTable(time.Now())
+	return func() tea.Msg {
+		return PageLoadedMsg{Page: EventsSummaryPage, TableHeader: tableHeader, AllPageRows: allPageRows}
+	}
+}
+
+// FetchEventsGroup shows the most recent events in a group of the summary, keyed by their complete JSON
+func FetchEventsGroup(summary EventsSummary, key string, code *gojq.Code) tea.Cmd {
+	group, exists := summary.Group(key)
+	return func() tea.Msg {
+		if !exists {
+			return PageLoadedMsg{Page: EventsGroupPage, TableHeader: []string{"Group not found"}, AllPageRows: []page.Row{}}
+		}
+		return PageLoadedMsg{Page: EventsGroupPage, TableHeader: []string{}, AllPageRows: EventsGroupAsRows(group, code)}
+	}
+}
+
+// EventsGroupAsRows renders the events in a group through the jq query the same way as events received from a stream
+func EventsGroupAsRows(group EventsGroup, code *gojq.Code) []page.Row {
+	var rows []page.Row
+	for _, e := range group.Events {
+		events, err := getEventsFromJQQuery(e, code)
+		if err != nil {
+			continue
+		}
+		for _, event := range events {
+			if event.CompleteValue == "{}" {
+				continue
+			}
+			rows = append(rows, page.Row{Key: event.CompleteValue, Row: event.JQValue})
+		}
+	}
+	return rows
+}
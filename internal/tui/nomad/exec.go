@@ -6,12 +6,15 @@ import (
 	"fmt"
 	"github.com/hashicorp/nomad/api"
 	"github.com/moby/term"
+	"github.com/robinovitch61/wander/internal/fileio"
 	"github.com/robinovitch61/wander/internal/tui/formatter"
 	"github.com/robinovitch61/wander/internal/tui/nomad/signals"
 	"golang.org/x/exp/maps"
 	"io"
 	"os"
 	"os/signal"
+	"sort"
+	"strings"
 	"syscall"
 	"time"
 )
@@ -45,7 +48,62 @@ func findAllocsForJobPrefix(client api.Client, jobName string) map[string][]*api
 	return allocs
 }
 
-func AllocExec(client *api.Client, allocID, task string, args []string) (int, error) {
+// ExecCandidate is a running task that an ambiguous job or allocation ID could refer to
+type ExecCandidate struct {
+	JobID     string
+	Namespace string
+	TaskGroup string
+	AllocID   string
+	AllocName string
+	NodeName  string
+	Task      string
+	StartedAt time.Time
+}
+
+// ExecCandidatePicker asks the user to choose one of the candidates, returning false if none was chosen
+type ExecCandidatePicker func(candidates []ExecCandidate) (ExecCandidate, bool, error)
+
+// AllocExec runs a command in a task, recording the session to recordDir as an asciinema file if it isn't empty.
+// If allocID or task are ambiguous and pick isn't nil, the user chooses the task with pick, otherwise the
+// candidates are printed.
+func AllocExec(client *api.Client, allocID, task string, args []string, recordDir string, pick ExecCandidatePicker) (int, error) {
+	alloc, task, found, err := FindExecTask(client, allocID, task, pick)
+	if err != nil || !found {
+		return 1, err
+	}
+
+	var recorder *fileio.CastRecorder
+	if recordDir != "" {
+		width, height := 80, 24
+		fd, _ := term.GetFdInfo(os.Stdin)
+		if size, err := term.GetWinsize(fd); err == nil {
+			width, height = int(size.Width), int(size.Height)
+		}
+		title := fmt.Sprintf("%s (%s), task %s: %s", alloc.Name, formatter.ShortAllocID(alloc.ID), task, strings.Join(args, " "))
+		recorder, err = fileio.NewCastRecorder(recordDir, fmt.Sprintf("%s_%s", alloc.Name, task), width, height, title)
+		if err != nil {
+			return 1, fmt.Errorf("could not start recording exec session: %v", err)
+		}
+	}
+
+	code, err := execImpl(client, alloc, task, args, "~", os.Stdin, os.Stdout, os.Stderr, recorder)
+	if recorder != nil {
+		if closeErr := recorder.Close(); closeErr != nil {
+			fmt.Printf("\nError recording exec session to %s: %v\n", recorder.Path(), closeErr)
+		} else {
+			fmt.Printf("\nRecorded exec session to %s\n", recorder.Path())
+		}
+	}
+	if err != nil {
+		return 1, err
+	}
+	return code, nil
+}
+
+// FindExecTask finds the allocation and task that allocID and task refer to, where allocID can also be a prefix of
+// an allocation ID or of a job ID. If they are ambiguous and pick isn't nil, the user chooses the task with pick,
+// otherwise the candidates are printed and found is false.
+func FindExecTask(client *api.Client, allocID, task string, pick ExecCandidatePicker) (*api.Allocation, string, bool, error) {
 	alloc, _, err := client.Allocations().Info(allocID, nil)
 	if err != nil {
 		// maybe allocID is actually a job name
@@ -54,6 +112,15 @@ func AllocExec(client *api.Client, allocID, task string, args []string) (int, er
 			if len(foundAllocs) == 1 && len(maps.Values(foundAllocs)[0]) == 1 && maps.Values(foundAllocs)[0][0] != nil {
 				// only one job with one allocation found, use that
 				alloc, _, _ = client.Allocations().Info(maps.Values(foundAllocs)[0][0].ID, nil)
+			} else if pick != nil {
+				var allocs []*api.AllocationListStub
+				for _, jobAllocs := range foundAllocs {
+					allocs = append(allocs, jobAllocs...)
+				}
+				alloc, task, err = pickExecCandidate(client, pick, execCandidatesForAllocs(allocs, task))
+				if err != nil {
+					return nil, "", false, err
+				}
 			} else {
 				// multiple jobs and/or allocations found, print them and exit
 				for job, jobAllocs := range foundAllocs {
@@ -62,25 +129,32 @@ func AllocExec(client *api.Client, allocID, task string, args []string) (int, er
 						fmt.Printf("  %s (%s in %s)\n", formatter.ShortAllocID(alloc.ID), alloc.Name, alloc.Namespace)
 					}
 				}
-				return 1, nil
+				return nil, "", false, nil
 			}
 		} else {
 			// maybe allocID is short form of id
 			shortIDAllocs, _, err := client.Allocations().List(&api.QueryOptions{Prefix: allocID})
 			if err != nil {
-				return 1, fmt.Errorf("no jobs or allocation id for %s found: %v", allocID, err)
+				return nil, "", false, fmt.Errorf("no jobs or allocation id for %s found: %v", allocID, err)
 			}
 			if len(shortIDAllocs) > 1 {
 				// rare but possible that uuid prefixes match
-				fmt.Printf("prefix %s matched multiple allocations:\n", allocID)
-				for _, alloc := range shortIDAllocs {
-					fmt.Printf("  %s (%s in %s)\n", formatter.ShortAllocID(alloc.ID), alloc.Name, alloc.Namespace)
+				if pick != nil {
+					alloc, task, err = pickExecCandidate(client, pick, execCandidatesForAllocs(shortIDAllocs, task))
+					if err != nil {
+						return nil, "", false, err
+					}
+				} else {
+					fmt.Printf("prefix %s matched multiple allocations:\n", allocID)
+					for _, alloc := range shortIDAllocs {
+						fmt.Printf("  %s (%s in %s)\n", formatter.ShortAllocID(alloc.ID), alloc.Name, alloc.Namespace)
+					}
+					return nil, "", false, err
 				}
-				return 1, err
 			} else if len(shortIDAllocs) == 1 {
 				alloc, _, _ = client.Allocations().Info(shortIDAllocs[0].ID, nil)
 			} else {
-				return 1, fmt.Errorf("no allocations found for alloc id %s", allocID)
+				return nil, "", false, fmt.Errorf("no allocations found for alloc id %s", allocID)
 			}
 		}
 	}
@@ -92,22 +166,119 @@ func AllocExec(client *api.Client, allocID, task string, args []string) (int, er
 			for taskName := range alloc.TaskStates {
 				task = taskName
 			}
+		} else if pick != nil {
+			candidates := execCandidatesForTasks(alloc.JobID, alloc.Namespace, alloc.TaskGroup, alloc.ID, alloc.Name, alloc.NodeName, alloc.TaskStates, "")
+			alloc, task, err = pickExecCandidate(client, pick, candidates)
+			if err != nil {
+				return nil, "", false, err
+			}
 		} else {
 			fmt.Printf("multiple tasks found in allocation %s (%s in %s)\n", formatter.ShortAllocID(alloc.ID), alloc.Name, alloc.Namespace)
 			for taskName := range alloc.TaskStates {
 				fmt.Printf("  %s\n", taskName)
 			}
+			return nil, "", false, nil
 		}
 	}
+	return alloc, task, true, nil
+}
 
-	code, err := execImpl(client, alloc, task, args, "~", os.Stdin, os.Stdout, os.Stderr)
+// pickExecCandidate uses pick to choose between the candidates if there are several, returning the chosen
+// allocation and task
+func pickExecCandidate(client *api.Client, pick ExecCandidatePicker, candidates []ExecCandidate) (*api.Allocation, string, error) {
+	if len(candidates) == 0 {
+		return nil, "", fmt.Errorf("no running tasks found")
+	}
+	chosen := candidates[0]
+	if len(candidates) > 1 {
+		var ok bool
+		var err error
+		chosen, ok, err = pick(candidates)
+		if err != nil {
+			return nil, "", err
+		}
+		if !ok {
+			return nil, "", fmt.Errorf("no task chosen")
+		}
+	}
+	alloc, _, err := client.Allocations().Info(chosen.AllocID, nil)
 	if err != nil {
-		return 1, err
+		return nil, "", err
 	}
-	return code, nil
+	return alloc, chosen.Task, nil
+}
+
+// execCandidatesForAllocs lists the running tasks of the running allocations, only those named task if it isn't
+// empty
+func execCandidatesForAllocs(allocs []*api.AllocationListStub, task string) []ExecCandidate {
+	var candidates []ExecCandidate
+	for _, alloc := range allocs {
+		if alloc == nil || alloc.ClientStatus != "running" {
+			continue
+		}
+		candidates = append(candidates, execCandidatesForTasks(alloc.JobID, alloc.Namespace, alloc.TaskGroup, alloc.ID, alloc.Name, alloc.NodeName, alloc.TaskStates, task)...)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].JobID != candidates[j].JobID {
+			return candidates[i].JobID < candidates[j].JobID
+		}
+		if candidates[i].AllocName != candidates[j].AllocName {
+			return candidates[i].AllocName < candidates[j].AllocName
+		}
+		if candidates[i].AllocID != candidates[j].AllocID {
+			return candidates[i].AllocID < candidates[j].AllocID
+		}
+		return candidates[i].Task < candidates[j].Task
+	})
+	return candidates
+}
+
+func execCandidatesForTasks(jobID, namespace, taskGroup, allocID, allocName, nodeName string, taskStates map[string]*api.TaskState, task string) []ExecCandidate {
+	var candidates []ExecCandidate
+	for taskName, state := range taskStates {
+		if state == nil || state.State != "running" || (task != "" && taskName != task) {
+			continue
+		}
+		candidates = append(candidates, ExecCandidate{
+			JobID:     jobID,
+			Namespace: namespace,
+			TaskGroup: taskGroup,
+			AllocID:   allocID,
+			AllocName: allocName,
+			NodeName:  nodeName,
+			Task:      taskName,
+			StartedAt: state.StartedAt,
+		})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].Task < candidates[j].Task
+	})
+	return candidates
+}
+
+// ExecCandidatesAsTable renders the candidates as a header and a row per candidate, in the same order
+func ExecCandidatesAsTable(candidates []ExecCandidate) (string, []string) {
+	var data [][]string
+	for _, c := range candidates {
+		uptime := "-"
+		if !c.StartedAt.IsZero() {
+			uptime = formatter.FormatTimeNsSinceNow(c.StartedAt.UnixNano())
+		}
+		data = append(data, []string{
+			c.JobID,
+			c.TaskGroup,
+			formatter.ShortAllocID(c.AllocID),
+			c.NodeName,
+			c.Task,
+			uptime,
+		})
+	}
+	table := formatter.GetRenderedTableAsString([]string{"Job", "Task Group", "Alloc ID", "Node", "Task", "Uptime"}, data)
+	return table.HeaderRows[0], table.ContentRows
 }
 
 // execImpl invokes the Alloc Exec api call, it also prepares and restores terminal states as necessary.
+// If recorder is non-nil, output, input and terminal size changes are recorded to it.
 func execImpl(
 	client *api.Client,
 	alloc *api.Allocation,
@@ -117,6 +288,7 @@ func execImpl(
 	stdin io.Reader,
 	stdout,
 	stderr io.WriteCloser,
+	recorder *fileio.CastRecorder,
 ) (int, error) {
 	// attempt to clear screen
 	time.Sleep(10 * time.Millisecond)
@@ -144,7 +316,27 @@ func execImpl(
 	}
 	defer outCleanup()
 
-	sizeCleanup, err := signals.WatchTerminalSize(stdin, sizeCh)
+	watchedSizeCh := sizeCh
+	if recorder != nil {
+		// record size changes on their way to the remote tty
+		watchedSizeCh = make(chan api.TerminalSize, 1)
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case size := <-watchedSizeCh:
+					recorder.Resize(size.Width, size.Height)
+					select {
+					case sizeCh <- size:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}()
+	}
+	sizeCleanup, err := signals.WatchTerminalSize(stdin, watchedSizeCh)
 	if err != nil {
 		return -1, err
 	}
@@ -174,8 +366,15 @@ func execImpl(
 		}
 	}()
 
+	var execStdout, execStderr io.Writer = stdout, stderr
+	if recorder != nil {
+		stdin = io.TeeReader(stdin, recorder.InputWriter())
+		execStdout = io.MultiWriter(stdout, recorder.OutputWriter())
+		execStderr = io.MultiWriter(stderr, recorder.OutputWriter())
+	}
+
 	return client.Allocations().Exec(ctx,
-		alloc, task, true, command, stdin, stdout, stderr, sizeCh, nil)
+		alloc, task, true, command, stdin, execStdout, execStderr, sizeCh, nil)
 }
 
 // setRawTerminal sets the stream terminal in raw mode, so process captures
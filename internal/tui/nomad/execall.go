@@ -0,0 +1,285 @@
+package nomad
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/anmitsu/go-shlex"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/hashicorp/nomad/api"
+	"github.com/robinovitch61/wander/internal/tui/components/page"
+	"github.com/robinovitch61/wander/internal/tui/formatter"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ExecAllTarget is a task in a running allocation that a command is run in by ExecAll
+type ExecAllTarget struct {
+	AllocID   string
+	AllocName string
+	NodeName  string
+	TaskGroup string
+	Task      string
+}
+
+type ExecAllResult struct {
+	ExecAllTarget
+	ExitCode int
+	Stdout   string
+	Stderr   string
+	// Err is set if the command couldn't be run, in which case ExitCode is -1
+	Err      error
+	Duration time.Duration
+}
+
+type ExecAllCompleteMsg struct {
+	Results []ExecAllResult
+	// Err is set if the allocations to run the command in couldn't be found
+	Err error
+}
+
+// SplitCommand splits a command into its arguments like a shell would, respecting quotes
+func SplitCommand(command string) ([]string, error) {
+	args, err := shlex.Split(command, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("no command specified")
+	}
+	return args, nil
+}
+
+// FindExecAllTargets lists the running allocations of a job, optionally only those of taskGroup. The job can be
+// a prefix of its ID, and namespace can be "*". If task is empty, each allocation must have a single task.
+func FindExecAllTargets(client api.Client, jobID, namespace, taskGroup, task string) ([]ExecAllTarget, error) {
+	job, err := findJob(client, jobID, namespace)
+	if err != nil {
+		return nil, err
+	}
+	allocs, _, err := client.Jobs().Allocations(job.ID, false, &api.QueryOptions{Namespace: job.Namespace})
+	if err != nil {
+		return nil, err
+	}
+	var targets []ExecAllTarget
+	for _, alloc := range allocs {
+		if alloc.ClientStatus != "running" || (taskGroup != "" && alloc.TaskGroup != taskGroup) {
+			continue
+		}
+		targetTask := task
+		if targetTask == "" {
+			if len(alloc.TaskStates) != 1 {
+				return nil, fmt.Errorf("allocation %s (%s) has %d tasks, specify one", formatter.ShortAllocID(alloc.ID), alloc.Name, len(alloc.TaskStates))
+			}
+			for taskName := range alloc.TaskStates {
+				targetTask = taskName
+			}
+		}
+		if state, exists := alloc.TaskStates[targetTask]; !exists || state.State != "running" {
+			continue
+		}
+		targets = append(targets, ExecAllTarget{
+			AllocID:   alloc.ID,
+			AllocName: alloc.Name,
+			NodeName:  alloc.NodeName,
+			TaskGroup: alloc.TaskGroup,
+			Task:      targetTask,
+		})
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no running allocations of job %s found", job.ID)
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		if targets[i].AllocName == targets[j].AllocName {
+			return targets[i].AllocID < targets[j].AllocID
+		}
+		return targets[i].AllocName < targets[j].AllocName
+	})
+	return targets, nil
+}
+
+// findJob finds the job with the ID, or the only job with the ID as a prefix
+func findJob(client api.Client, jobID, namespace string) (*api.JobListStub, error) {
+	jobs, _, err := client.Jobs().List(&api.QueryOptions{Namespace: namespace, Prefix: jobID})
+	if err != nil {
+		return nil, err
+	}
+	for _, job := range jobs {
+		if job.ID == jobID {
+			return job, nil
+		}
+	}
+	if len(jobs) == 1 {
+		return jobs[0], nil
+	}
+	if len(jobs) == 0 {
+		return nil, fmt.Errorf("no job %s found", jobID)
+	}
+	var matches []string
+	for _, job := range jobs {
+		matches = append(matches, fmt.Sprintf("%s (in %s)", job.ID, job.Namespace))
+	}
+	return nil, fmt.Errorf("prefix %s matched multiple jobs: %s", jobID, strings.Join(matches, ", "))
+}
+
+// ExecAll runs a non-interactive command in every target, at most concurrency at a time. onResult, if not nil, is
+// called as each command completes. Results are in the same order as targets.
+func ExecAll(ctx context.Context, client *api.Client, targets []ExecAllTarget, command []string, concurrency int, onResult func(ExecAllResult)) []ExecAllResult {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	results := make([]ExecAllResult, len(targets))
+	sem := make(chan struct{}, concurrency)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target ExecAllTarget) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			result := execInTarget(ctx, client, target, command)
+			results[i] = result
+			if onResult != nil {
+				mu.Lock()
+				onResult(result)
+				mu.Unlock()
+			}
+		}(i, target)
+	}
+	wg.Wait()
+	return results
+}
+
+func execInTarget(ctx context.Context, client *api.Client, target ExecAllTarget, command []string) ExecAllResult {
+	start := time.Now()
+	result := ExecAllResult{ExecAllTarget: target, ExitCode: -1}
+	alloc, _, err := client.Allocations().Info(target.AllocID, nil)
+	if err != nil {
+		result.Err = err
+		result.Duration = time.Since(start)
+		return result
+	}
+	var stdout, stderr bytes.Buffer
+	// a nil terminal size channel, as there is no tty
+	code, err := client.Allocations().Exec(ctx, alloc, target.Task, false, command, strings.NewReader(""), &stdout, &stderr, nil, nil)
+	result.Stdout, result.Stderr = stdout.String(), stderr.String()
+	result.Duration = time.Since(start)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	result.ExitCode = code
+	return result
+}
+
+// Output is the result's stdout followed by its stderr, or the error running the command
+func (r ExecAllResult) Output() string {
+	output := strings.TrimRight(r.Stdout, "\n")
+	if stderr := strings.TrimRight(r.Stderr, "\n"); stderr != "" {
+		if output != "" {
+			output += "\n"
+		}
+		output += stderr
+	}
+	if r.Err != nil {
+		if output != "" {
+			output += "\n"
+		}
+		output += fmt.Sprintf("error: %v", r.Err)
+	}
+	return output
+}
+
+// Prefix identifies the target's allocation on each line of combined output
+func (t ExecAllTarget) Prefix() string {
+	return fmt.Sprintf("[%s %s]", t.AllocName, formatter.ShortAllocID(t.AllocID))
+}
+
+// FetchExecAll runs the command in every running allocation of a job, see FindExecAllTargets, returning the results
+// once all are complete
+func FetchExecAll(client api.Client, jobID, namespace, taskGroup, task string, command []string, concurrency int, readOnly bool) tea.Cmd {
+	return func() tea.Msg {
+		if readOnly {
+			return ExecAllCompleteMsg{Err: ErrReadOnly}
+		}
+		targets, err := FindExecAllTargets(client, jobID, namespace, taskGroup, task)
+		if err != nil {
+			return ExecAllCompleteMsg{Err: err}
+		}
+		return ExecAllCompleteMsg{Results: ExecAll(context.Background(), &client, targets, command, concurrency, nil)}
+	}
+}
+
+func FetchExecAllResults(results []ExecAllResult, err error) tea.Cmd {
+	return func() tea.Msg {
+		if err != nil {
+			return PageLoadedMsg{
+				Page:        ExecAllResultsPage,
+				TableHeader: []string{"Error"},
+				AllPageRows: []page.Row{{Key: "", Row: err.Error()}},
+			}
+		}
+		tableHeader, allPageRows := ExecAllResultsAsTable(results)
+		return PageLoadedMsg{Page: ExecAllResultsPage, TableHeader: tableHeader, AllPageRows: allPageRows}
+	}
+}
+
+// FetchExecAllOutput shows the output of the results, prefixed by their allocation if combined
+func FetchExecAllOutput(results []ExecAllResult, combined bool, p Page) tea.Cmd {
+	return func() tea.Msg {
+		return PageLoadedMsg{Page: p, TableHeader: []string{}, AllPageRows: ExecAllResultAsRows(results, combined)}
+	}
+}
+
+// ExecAllResultsAsTable lists the results, keyed by their index in results
+func ExecAllResultsAsTable(results []ExecAllResult) ([]string, []page.Row) {
+	var data [][]string
+	for _, r := range results {
+		exitCode := strconv.Itoa(r.ExitCode)
+		if r.Err != nil {
+			exitCode = "error"
+		}
+		var firstLine string
+		if output := r.Output(); output != "" {
+			firstLine = strings.SplitN(output, "\n", 2)[0]
+		}
+		data = append(data, []string{
+			r.AllocName,
+			formatter.ShortAllocID(r.AllocID),
+			r.NodeName,
+			r.Task,
+			exitCode,
+			r.Duration.Round(time.Millisecond).String(),
+			firstLine,
+		})
+	}
+	table := formatter.GetRenderedTableAsString([]string{"Alloc Name", "Alloc ID", "Node", "Task", "Exit Code", "Duration", "Output"}, data)
+	var rows []page.Row
+	for idx, row := range table.ContentRows {
+		rows = append(rows, page.Row{Key: strconv.Itoa(idx), Row: row})
+	}
+	return table.HeaderRows, rows
+}
+
+// ExecAllResultAsRows shows the output of one result, or of all results prefixed by their allocation if combined
+func ExecAllResultAsRows(results []ExecAllResult, combined bool) []page.Row {
+	var rows []page.Row
+	for _, r := range results {
+		output := r.Output()
+		if output == "" {
+			continue
+		}
+		for _, line := range strings.Split(output, "\n") {
+			if combined {
+				line = fmt.Sprintf("%s %s", r.Prefix(), line)
+			}
+			rows = append(rows, page.Row{Key: "", Row: line})
+		}
+	}
+	return rows
+}
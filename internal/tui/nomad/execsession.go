@@ -0,0 +1,270 @@
+package nomad
+
+import (
+	"context"
+	"fmt"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/hashicorp/nomad/api"
+	"github.com/robinovitch61/wander/internal/fileio"
+	"github.com/robinovitch61/wander/internal/tui/components/page"
+	"github.com/robinovitch61/wander/internal/tui/formatter"
+	"github.com/robinovitch61/wander/internal/tui/style"
+	"github.com/robinovitch61/wander/internal/tui/terminal"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// execSessionInputBuffer is how many writes of input can be waiting to be sent to a session
+const execSessionInputBuffer = 256
+
+// ExecSession is an exec session shown in a page by an emulated terminal, rather than taking over the real one
+type ExecSession struct {
+	// ID distinguishes messages from this session from those of other sessions
+	ID        int
+	AllocID   string
+	AllocName string
+	Task      string
+	Command   string
+	Terminal  *terminal.Terminal
+	// RecordPath is where the session is recorded to, empty if it isn't recorded
+	RecordPath string
+
+	input    chan []byte
+	sizeCh   chan api.TerminalSize
+	recorder *fileio.CastRecorder
+	cancel   context.CancelFunc
+	// updated is signalled when there is new output, coalescing output that arrives before it's read
+	updated chan struct{}
+	// done is closed once the session has exited, after which exitCode and err are set
+	done     chan struct{}
+	exitCode int
+	err      error
+}
+
+type ExecSessionUpdatedMsg struct {
+	ID int
+}
+
+type ExecSessionClosedMsg struct {
+	ID       int
+	ExitCode int
+	Err      error
+}
+
+// StartExecSession runs command in the task with a tty of width by height, recording it to recordDir if it isn't
+// empty. Read its output with ReadExecSessionNextUpdate.
+func StartExecSession(client api.Client, id int, allocID, allocName, task string, command []string, width, height, scrollback int, recordDir string, readOnly bool) (*ExecSession, error) {
+	if readOnly {
+		return nil, ErrReadOnly
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &ExecSession{
+		ID:        id,
+		AllocID:   allocID,
+		AllocName: allocName,
+		Task:      task,
+		Command:   strings.Join(command, " "),
+		input:     make(chan []byte, execSessionInputBuffer),
+		sizeCh:    make(chan api.TerminalSize, 1),
+		cancel:    cancel,
+		updated:   make(chan struct{}, 1),
+		done:      make(chan struct{}),
+	}
+	// answers to queries like the cursor position go back to the program as input
+	s.Terminal = terminal.New(width, height, scrollback, s.Input)
+
+	if recordDir != "" {
+		title := fmt.Sprintf("%s (%s), task %s: %s", allocName, formatter.ShortAllocID(allocID), task, s.Command)
+		recorder, err := fileio.NewCastRecorder(recordDir, fmt.Sprintf("%s_%s", allocName, task), width, height, title)
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("could not start recording exec session: %v", err)
+		}
+		s.recorder = recorder
+		s.RecordPath = recorder.Path()
+	}
+	s.sizeCh <- api.TerminalSize{Width: width, Height: height}
+
+	stdin, stdinWriter := io.Pipe()
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				_ = stdinWriter.Close()
+				return
+			case p := <-s.input:
+				if _, err := stdinWriter.Write(p); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	go func() {
+		defer close(s.done)
+		defer cancel()
+		var in io.Reader = stdin
+		var out io.Writer = execSessionOutput{s}
+		if s.recorder != nil {
+			in = io.TeeReader(stdin, s.recorder.InputWriter())
+			out = io.MultiWriter(out, s.recorder.OutputWriter())
+		}
+		s.exitCode, s.err = execInSession(ctx, client, allocID, task, command, in, out, s.sizeCh)
+		if s.recorder != nil {
+			if err := s.recorder.Close(); err != nil && s.err == nil {
+				s.err = fmt.Errorf("could not record exec session to %s: %v", s.RecordPath, err)
+			}
+		}
+	}()
+	return s, nil
+}
+
+func execInSession(ctx context.Context, client api.Client, allocID, task string, command []string, stdin io.Reader, out io.Writer, sizeCh <-chan api.TerminalSize) (int, error) {
+	alloc, _, err := client.Allocations().Info(allocID, nil)
+	if err != nil {
+		return -1, err
+	}
+	// stdout and stderr are the same tty, as in a real terminal
+	return client.Allocations().Exec(ctx, alloc, task, true, command, stdin, out, out, sizeCh, nil)
+}
+
+// execSessionOutput writes output to the session's terminal, signalling that it was updated
+type execSessionOutput struct {
+	session *ExecSession
+}
+
+func (o execSessionOutput) Write(p []byte) (int, error) {
+	n, err := o.session.Terminal.Write(p)
+	select {
+	case o.session.updated <- struct{}{}:
+	default:
+	}
+	return n, err
+}
+
+// Input sends p to the session as if typed. It never blocks, dropping input if too much is waiting to be sent.
+func (s *ExecSession) Input(p []byte) {
+	if len(p) == 0 || s.Exited() {
+		return
+	}
+	select {
+	case s.input <- append([]byte(nil), p...):
+	default:
+	}
+}
+
+// Resize changes the size of the session's terminal and tty
+func (s *ExecSession) Resize(width, height int) {
+	if w, h := s.Terminal.Size(); w == width && h == height {
+		return
+	}
+	s.Terminal.Resize(width, height)
+	if s.Exited() {
+		return
+	}
+	if s.recorder != nil {
+		s.recorder.Resize(width, height)
+	}
+	// only the latest size matters
+	select {
+	case <-s.sizeCh:
+	default:
+	}
+	s.sizeCh <- api.TerminalSize{Width: width, Height: height}
+}
+
+// Close ends the session if it's still running
+func (s *ExecSession) Close() {
+	s.cancel()
+}
+
+func (s *ExecSession) Exited() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Status describes whether the session is running or how it exited
+func (s *ExecSession) Status() string {
+	if !s.Exited() {
+		return "running"
+	}
+	if s.err != nil {
+		return fmt.Sprintf("error: %v", s.err)
+	}
+	return fmt.Sprintf("exited %d", s.exitCode)
+}
+
+func ReadExecSessionNextUpdate(s *ExecSession) tea.Cmd {
+	return func() tea.Msg {
+		select {
+		case <-s.updated:
+			return ExecSessionUpdatedMsg{ID: s.ID}
+		case <-s.done:
+			return ExecSessionClosedMsg{ID: s.ID, ExitCode: s.exitCode, Err: s.err}
+		}
+	}
+}
+
+func FetchExecSessions(sessions []*ExecSession) tea.Cmd {
+	tableHeader, allPageRows := ExecSessionsAsTable(sessions)
+	return func() tea.Msg {
+		return PageLoadedMsg{Page: ExecSessionsPage, TableHeader: tableHeader, AllPageRows: allPageRows}
+	}
+}
+
+// FetchExecSession shows the terminal of the session, with the tabs of all sessions as the header
+func FetchExecSession(sessions []*ExecSession, current int, showCursor bool) tea.Cmd {
+	tableHeader, allPageRows := ExecSessionAsRows(sessions, current, showCursor)
+	return func() tea.Msg {
+		return PageLoadedMsg{Page: ExecSessionPage, TableHeader: tableHeader, AllPageRows: allPageRows}
+	}
+}
+
+// ExecSessionsAsTable lists the sessions, keyed by their index in sessions
+func ExecSessionsAsTable(sessions []*ExecSession) ([]string, []page.Row) {
+	var data [][]string
+	for i, s := range sessions {
+		data = append(data, []string{
+			strconv.Itoa(i + 1),
+			s.AllocName,
+			formatter.ShortAllocID(s.AllocID),
+			s.Task,
+			s.Command,
+			s.Status(),
+		})
+	}
+	table := formatter.GetRenderedTableAsString([]string{"Tab", "Alloc Name", "Alloc ID", "Task", "Command", "Status"}, data)
+	var rows []page.Row
+	for idx, row := range table.ContentRows {
+		rows = append(rows, page.Row{Key: strconv.Itoa(idx), Row: row})
+	}
+	return table.HeaderRows, rows
+}
+
+// ExecSessionAsRows is a header with a tab for each session, the current one highlighted, and the lines of the
+// current session's terminal
+func ExecSessionAsRows(sessions []*ExecSession, current int, showCursor bool) ([]string, []page.Row) {
+	var tabs []string
+	for i, s := range sessions {
+		tab := fmt.Sprintf(" %d %s ", i+1, s.Task)
+		if s.Exited() {
+			tab = fmt.Sprintf(" %d %s (%s) ", i+1, s.Task, s.Status())
+		}
+		if i == current {
+			tab = style.ViewportSelectedRowStyle.Render(tab)
+		}
+		tabs = append(tabs, tab)
+	}
+	var rows []page.Row
+	if current >= 0 && current < len(sessions) {
+		for _, line := range sessions[current].Terminal.Lines(showCursor) {
+			rows = append(rows, page.Row{Key: "", Row: line})
+		}
+	}
+	return []string{strings.Join(tabs, "│")}, rows
+}
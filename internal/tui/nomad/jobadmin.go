@@ -34,7 +34,13 @@ func GetCmdForJobAdminAction(
 	client api.Client,
 	adminAction AdminAction,
 	jobID, jobNamespace string,
+	readOnly bool,
 ) tea.Cmd {
+	if readOnly {
+		return func() tea.Msg {
+			return JobAdminActionCompleteMsg{Err: ErrReadOnly, JobID: jobID}
+		}
+	}
 	switch adminAction {
 	case StopJobAction:
 		return StopJob(client, jobID, jobNamespace, false)
@@ -14,33 +14,54 @@ import (
 
 func FetchJobs(client api.Client, columns []string) tea.Cmd {
 	return func() tea.Msg {
-		jobListOpts := &api.JobListOptions{
-			Fields: &api.JobListFields{Meta: true},
-		}
-		jobResults, _, err := client.Jobs().ListOptions(jobListOpts, nil)
+		jobResults, err := listJobs(client)
 		if err != nil {
-			if strings.Contains(err.Error(), "UUID must be 36 characters") {
-				return message.ErrMsg{Err: errors.New("token must be 36 characters")}
-			} else if strings.Contains(err.Error(), "ACL token not found") {
-				return message.ErrMsg{Err: errors.New("token not authorized to list jobs")}
-			}
 			return message.ErrMsg{Err: err}
 		}
-
-		sort.Slice(jobResults, func(x, y int) bool {
-			firstJob := jobResults[x]
-			secondJob := jobResults[y]
-			if firstJob.Name == secondJob.Name {
-				return firstJob.Namespace < secondJob.Namespace
-			}
-			return jobResults[x].Name < jobResults[y].Name
-		})
-
 		tableHeader, allPageData := jobResponsesAsTable(jobResults, columns)
 		return PageLoadedMsg{Page: JobsPage, TableHeader: tableHeader, AllPageRows: allPageData}
 	}
 }
 
+// JobsRows are the values of the columns for each job, as shown on the jobs page
+func JobsRows(client api.Client, columns []string) ([][]string, error) {
+	jobResults, err := listJobs(client)
+	if err != nil {
+		return nil, err
+	}
+	var rows [][]string
+	for _, row := range jobResults {
+		rows = append(rows, getJobRowFromColumns(row, columns))
+	}
+	return rows, nil
+}
+
+// listJobs lists jobs with their meta, sorted by name then namespace
+func listJobs(client api.Client) ([]*api.JobListStub, error) {
+	jobListOpts := &api.JobListOptions{
+		Fields: &api.JobListFields{Meta: true},
+	}
+	jobResults, _, err := client.Jobs().ListOptions(jobListOpts, nil)
+	if err != nil {
+		if strings.Contains(err.Error(), "UUID must be 36 characters") {
+			return nil, errors.New("token must be 36 characters")
+		} else if strings.Contains(err.Error(), "ACL token not found") {
+			return nil, ErrTokenNotAuthorized
+		}
+		return nil, err
+	}
+
+	sort.Slice(jobResults, func(x, y int) bool {
+		firstJob := jobResults[x]
+		secondJob := jobResults[y]
+		if firstJob.Name == secondJob.Name {
+			return firstJob.Namespace < secondJob.Namespace
+		}
+		return jobResults[x].Name < jobResults[y].Name
+	})
+	return jobResults, nil
+}
+
 func getCount(row *api.JobListStub) string {
 	num, denom := 0, 0
 	for _, v := range row.JobSummary.Summary {
@@ -50,6 +71,9 @@ func getCount(row *api.JobListStub) string {
 	return strconv.Itoa(num) + "/" + strconv.Itoa(denom)
 }
 
+// JobColumns are the columns of the jobs page that aren't looked up in the job meta
+var JobColumns = []string{"Job", "Type", "Namespace", "Priority", "Status", "Count", "Submitted", "Since Submit"}
+
 func getJobRowFromColumns(row *api.JobListStub, columns []string) []string {
 	knownColMap := map[string]string{
 		"Job":          row.ID,
@@ -0,0 +1,171 @@
+package nomad
+
+import (
+	"fmt"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/robinovitch61/wander/internal/tui/components/page"
+	"github.com/robinovitch61/wander/internal/tui/style"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type LogLevel int8
+
+// the definition order of these is important, as it's used to compare severity
+const (
+	UnknownLogLevel LogLevel = iota
+	TraceLogLevel
+	DebugLogLevel
+	InfoLogLevel
+	WarnLogLevel
+	ErrorLogLevel
+	FatalLogLevel
+)
+
+var (
+	// {"level":"error"}
+	jsonLevelRe = regexp.MustCompile(`"(?i:level|lvl|severity|loglevel)"\s*:\s*"([A-Za-z]+)"`)
+	// level=error
+	logfmtLevelRe = regexp.MustCompile(`(?:^|\s)(?i:level|lvl|severity)=["']?([A-Za-z]+)`)
+	// [ERROR]
+	bracketLevelRe = regexp.MustCompile(`\[([A-Za-z]+)\]`)
+	// E0102 15:04:05.000000 (glog/klog)
+	glogLevelRe = regexp.MustCompile(`^([IWEF])\d{4} \d{2}:\d{2}:\d{2}`)
+	// 2023-01-02 15:04:05 ERROR ..., ERROR:root:...
+	prefixLevelRe = regexp.MustCompile(`^(?:\S+\s+){0,3}?(TRACE|DEBUG|INFO|WARN|WARNING|ERROR|FATAL|CRITICAL|PANIC)\b`)
+)
+
+func (l LogLevel) String() string {
+	switch l {
+	case TraceLogLevel:
+		return "trace"
+	case DebugLogLevel:
+		return "debug"
+	case InfoLogLevel:
+		return "info"
+	case WarnLogLevel:
+		return "warn"
+	case ErrorLogLevel:
+		return "error"
+	case FatalLogLevel:
+		return "fatal"
+	}
+	return "unknown"
+}
+
+// Style returns the style for log lines of this level, or false if lines of this level are not styled
+func (l LogLevel) Style() (lipgloss.Style, bool) {
+	switch l {
+	case TraceLogLevel, DebugLogLevel:
+		return style.LogLevelDebug, true
+	case WarnLogLevel:
+		return style.LogLevelWarn, true
+	case ErrorLogLevel:
+		return style.LogLevelError, true
+	case FatalLogLevel:
+		return style.LogLevelFatal, true
+	}
+	return lipgloss.Style{}, false
+}
+
+func logLevelFromName(name string) LogLevel {
+	switch strings.ToLower(name) {
+	case "trace":
+		return TraceLogLevel
+	case "debug", "dbug":
+		return DebugLogLevel
+	case "info", "inf", "notice":
+		return InfoLogLevel
+	case "warn", "warning", "wrn":
+		return WarnLogLevel
+	case "error", "err", "eror":
+		return ErrorLogLevel
+	case "fatal", "crit", "critical", "panic", "emerg", "alert":
+		return FatalLogLevel
+	}
+	return UnknownLogLevel
+}
+
+// DetectLogLevel detects the severity of a log line from common formats: JSON level fields,
+// logfmt level=, bracketed [ERROR] prefixes, glog E0102 prefixes and leading level names
+func DetectLogLevel(line string) LogLevel {
+	line = stripColors(line)
+	if match := jsonLevelRe.FindStringSubmatch(line); match != nil {
+		if level := logLevelFromName(match[1]); level != UnknownLogLevel {
+			return level
+		}
+	}
+	if match := logfmtLevelRe.FindStringSubmatch(line); match != nil {
+		if level := logLevelFromName(match[1]); level != UnknownLogLevel {
+			return level
+		}
+	}
+	if match := glogLevelRe.FindStringSubmatch(line); match != nil {
+		switch match[1] {
+		case "I":
+			return InfoLogLevel
+		case "W":
+			return WarnLogLevel
+		case "E":
+			return ErrorLogLevel
+		case "F":
+			return FatalLogLevel
+		}
+	}
+	for _, match := range bracketLevelRe.FindAllStringSubmatch(line, 3) {
+		if level := logLevelFromName(match[1]); level != UnknownLogLevel {
+			return level
+		}
+	}
+	if match := prefixLevelRe.FindStringSubmatch(line); match != nil {
+		return logLevelFromName(match[1])
+	}
+	return UnknownLogLevel
+}
+
+// toLogKey stores the level and full text of a log entry. The level of a grouped, multi-line entry is taken from its first line
+func toLogKey(entry string) string {
+	firstLine := strings.SplitN(entry, "\n", 2)[0]
+	return strconv.Itoa(int(DetectLogLevel(firstLine))) + keySeparator + entry
+}
+
+// LogLineFromKey returns the detected level and raw log line stored in the key of a log row
+func LogLineFromKey(key string) (LogLevel, string) {
+	split := strings.SplitN(key, keySeparator, 2)
+	if len(split) != 2 {
+		return UnknownLogLevel, key
+	}
+	level, err := strconv.Atoi(split[0])
+	if err != nil {
+		return UnknownLogLevel, split[1]
+	}
+	return LogLevel(level), split[1]
+}
+
+// LogLineAsRow returns a raw, unstructured log row for a log line or grouped entry
+func LogLineAsRow(line string) page.Row {
+	return page.Row{Key: toLogKey(line), Row: logEntrySummary(line)}
+}
+
+// logEntrySummary is the first line of a grouped log entry, noting how many lines are folded under it
+func logEntrySummary(entry string) string {
+	lines := strings.Split(entry, "\n")
+	if len(lines) == 1 {
+		return entry
+	}
+	return fmt.Sprintf("%s [+%d lines]", lines[0], len(lines)-1)
+}
+
+// LogRowAtLeast returns a function that matches log rows at or above the given level
+func LogRowAtLeast(level LogLevel) func(page.Row) bool {
+	return func(row page.Row) bool {
+		rowLevel, _ := LogLineFromKey(row.Key)
+		return rowLevel >= level
+	}
+}
+
+func logRowStyle(row page.Row) (lipgloss.Style, bool) {
+	level, _ := LogLineFromKey(row.Key)
+	return level.Style()
+}
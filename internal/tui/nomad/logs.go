@@ -5,10 +5,19 @@ import (
 	"github.com/hashicorp/nomad/api"
 	"github.com/robinovitch61/wander/internal/tui/components/page"
 	"github.com/robinovitch61/wander/internal/tui/formatter"
+	"github.com/robinovitch61/wander/internal/tui/message"
+	"regexp"
 	"strings"
+	"sync"
 	"time"
 )
 
+// LogFieldsMetaKey is the job meta key that overrides the configured structured log fields for that job
+const LogFieldsMetaKey = "wander_log_fields"
+
+// setClientConnTimeout sets the timeout once, as logs can be opened concurrently
+var setClientConnTimeout sync.Once
+
 type LogType int8
 
 const (
@@ -17,8 +26,15 @@ const (
 )
 
 type LogsStreamMsg struct {
-	Value string // may include line breaks
-	Type  LogType
+	StreamID int
+	Value    string // may include line breaks
+	Type     LogType
+}
+
+// LogsStreamClosedMsg is sent when a logs stream errors or closes. Err is nil if it closed without error.
+type LogsStreamClosedMsg struct {
+	StreamID int
+	Err      error
 }
 
 func (p LogType) String() string {
@@ -41,25 +57,18 @@ func (p LogType) ShortString() string {
 	return "unknown"
 }
 
-func FetchLogs(client api.Client, alloc api.Allocation, taskName string, logType LogType, logOffset int, logTail bool) tea.Cmd {
+func FetchLogs(client api.Client, alloc api.Allocation, taskName string, logType LogType, logOffset int, logTail bool, structuredFields []string, preserveColors bool, groupContinuation *regexp.Regexp) tea.Cmd {
 	return func() tea.Msg {
-		// This is currently very important and strange. The logs api attempts to go through the node directly
-		// by default. The default timeout for this is 1 second. If it fails, it falls silently to going through
-		// the server. Since it always fails, at least in my Nomad setup, make it timeout immediately by setting
-		// the timeout to something tiny.
-		api.ClientConnTimeout = 1 * time.Microsecond
-
-		closeLogConn := make(chan struct{})   // never closed for now
-		logsChan, _ := client.AllocFS().Logs( // TODO: deal with error channel
-			&alloc,
-			logTail,
-			taskName,
-			logType.ShortString(),
-			"end",
-			int64(logOffset),
-			closeLogConn,
-			nil,
-		)
+		if len(structuredFields) > 0 {
+			structuredFields = getStructuredLogFields(client, alloc, structuredFields)
+		}
+
+		closeLogConn := make(chan struct{}) // never closed for now
+		logsChan, logsErrs := openLogs(client, &alloc, taskName, logType, logTail, "end", int64(logOffset), closeLogConn)
+		if logsChan == nil {
+			// the logs couldn't be opened, e.g. as nomad doesn't accept the token
+			return message.ErrMsg{Err: <-logsErrs}
+		}
 
 		var logRows []string
 		var logsStream LogsStream
@@ -69,24 +78,73 @@ func FetchLogs(client api.Client, alloc api.Allocation, taskName string, logType
 				allLogs += string(l.Data)
 			}
 
-			tabReplacedLogs := formatter.CleanLogs(allLogs)
+			tabReplacedLogs := cleanLogs(allLogs, preserveColors)
 			logRows = strings.Split(tabReplacedLogs, "\n")
 		} else {
-			logsStream = LogsStream{logsChan, logType}
+			logsStream = LogsStream{
+				Chan:              logsChan,
+				Errs:              logsErrs,
+				LogType:           logType,
+				Fields:            structuredFields,
+				PreserveColors:    preserveColors,
+				GroupContinuation: groupContinuation,
+			}
 		}
-		tableHeader, allPageData := logsAsTable(logRows, logType)
+		tableHeader, allPageData := LogsAsTable(logRows, logType, structuredFields, groupContinuation)
 		return PageLoadedMsg{Page: LogsPage, TableHeader: tableHeader, AllPageRows: allPageData, LogsStream: logsStream}
 	}
 }
 
-func logsAsTable(logs []string, logType LogType) ([]string, []page.Row) {
+// openLogs streams the logs of the task from offset bytes after origin, "start" or "end", following new logs if
+// follow is true
+func openLogs(client api.Client, alloc *api.Allocation, taskName string, logType LogType, follow bool, origin string, offset int64, cancel <-chan struct{}) (<-chan *api.StreamFrame, <-chan error) {
+	// This is currently very important and strange. The logs api attempts to go through the node directly
+	// by default. The default timeout for this is 1 second. If it fails, it falls silently to going through
+	// the server. Since it always fails, at least in my Nomad setup, make it timeout immediately by setting
+	// the timeout to something tiny.
+	setClientConnTimeout.Do(func() { api.ClientConnTimeout = 1 * time.Microsecond })
+	return client.AllocFS().Logs(alloc, follow, taskName, logType.ShortString(), origin, offset, cancel, nil)
+}
+
+// getStructuredLogFields returns the fields in the job's meta under LogFieldsMetaKey if present, otherwise the given defaults
+func getStructuredLogFields(client api.Client, alloc api.Allocation, defaultFields []string) []string {
+	job, _, err := client.Jobs().Info(alloc.JobID, &api.QueryOptions{Namespace: alloc.Namespace})
+	if err != nil || job == nil {
+		return defaultFields
+	}
+	metaFields, exists := job.Meta[LogFieldsMetaKey]
+	if !exists {
+		return defaultFields
+	}
+	var fields []string
+	for _, f := range strings.Split(metaFields, ",") {
+		if t := strings.TrimSpace(f); t != "" {
+			fields = append(fields, t)
+		}
+	}
+	if len(fields) == 0 {
+		return defaultFields
+	}
+	return fields
+}
+
+// LogsAsTable renders log lines as page rows. If structuredFields is not empty, lines are parsed as JSON or
+// logfmt and the fields are shown as columns, with lines that fail to parse shown raw. If groupContinuation
+// is not nil, lines are first grouped into multi-line entries, see GroupLogLines. The Key of each row
+// holds the detected log level and raw log entry, see LogLineFromKey.
+func LogsAsTable(logs []string, logType LogType, structuredFields []string, groupContinuation *regexp.Regexp) ([]string, []page.Row) {
+	logs = GroupLogLines(logs, groupContinuation)
+	if len(structuredFields) > 0 {
+		return structuredLogsAsTable(logs, structuredFields)
+	}
+
 	var logRows [][]string
 	var keys []string
 	for _, row := range logs {
 		if stripped := strings.TrimSpace(row); stripped != "" {
-			logRows = append(logRows, []string{row})
+			logRows = append(logRows, []string{logEntrySummary(row)})
+			keys = append(keys, toLogKey(row))
 		}
-		keys = append(keys, "")
 	}
 
 	columns := []string{logType.String()}
@@ -100,10 +158,226 @@ func logsAsTable(logs []string, logType LogType) ([]string, []page.Row) {
 	return table.HeaderRows, rows
 }
 
+func structuredLogsAsTable(logs []string, fields []string) ([]string, []page.Row) {
+	var rawLines []string
+	var parsedRows [][]string
+	// parsedIdxs maps each raw line to its row in parsedRows, or -1 if it failed to parse
+	var parsedIdxs []int
+	for _, line := range logs {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		rawLines = append(rawLines, line)
+		row := structuredLogCells(line, fields)
+		if row == nil {
+			parsedIdxs = append(parsedIdxs, -1)
+			continue
+		}
+		parsedIdxs = append(parsedIdxs, len(parsedRows))
+		parsedRows = append(parsedRows, row)
+	}
+
+	table := formatter.GetRenderedTableAsString(fields, parsedRows)
+
+	var rows []page.Row
+	for idx, line := range rawLines {
+		if parsedIdx := parsedIdxs[idx]; parsedIdx >= 0 {
+			rows = append(rows, page.Row{Key: toLogKey(line), Row: table.ContentRows[parsedIdx]})
+		} else {
+			rows = append(rows, LogLineAsRow(line))
+		}
+	}
+
+	return table.HeaderRows, rows
+}
+
+// structuredLogCells are the values of fields in a log entry, or nil if it is shown raw as it fails to parse or is a
+// grouped, multi-line entry
+func structuredLogCells(entry string, fields []string) []string {
+	if strings.Contains(entry, "\n") {
+		return nil
+	}
+	parsed, ok := formatter.ParseStructuredLogLine(stripColors(entry))
+	if !ok {
+		return nil
+	}
+	var cells []string
+	for _, f := range fields {
+		v, exists := parsed[f]
+		if !exists || v == "" {
+			v = "-"
+		}
+		cells = append(cells, strings.ReplaceAll(v, "\n", "\\n"))
+	}
+	return cells
+}
+
+// LogTable builds the rows of LogsAsTable from streamed log data, parsing, grouping and detecting the level of each
+// entry once rather than every time data arrives. Column widths only grow, so rows already rendered are only
+// rendered again, from their parsed fields, when a new row is wider.
+type LogTable struct {
+	columns           []string
+	structured        bool
+	groupContinuation *regexp.Regexp
+	widths            []int
+	rows              []page.Row
+	// cells are the structured fields of each row, nil for rows shown raw
+	cells [][]string
+	// partial is the last line received if it hasn't finished yet, and partialInRows whether it ends the last row
+	partial       string
+	partialInRows bool
+}
+
+// NewLogTable shows the fields of structured logs as columns if there are any, see LogsAsTable
+func NewLogTable(logType LogType, structuredFields []string, groupContinuation *regexp.Regexp) *LogTable {
+	columns := structuredFields
+	if len(columns) == 0 {
+		columns = []string{logType.String()}
+	}
+	widths := make([]int, len(columns))
+	formatter.GrowTableWidths(widths, columns)
+	return &LogTable{
+		columns:           columns,
+		structured:        len(structuredFields) > 0,
+		groupContinuation: groupContinuation,
+		widths:            widths,
+	}
+}
+
+func (t *LogTable) Header() []string {
+	return formatter.GetRenderedTableHeader(t.columns, t.widths)
+}
+
+func (t *LogTable) Rows() []page.Row {
+	return t.rows
+}
+
+// Append adds streamed log data, which can finish the last line received and end part way through a line
+func (t *LogTable) Append(value string) {
+	lines := strings.Split(value, "\n")
+	lines[0] = t.partial + lines[0]
+	t.partial = lines[len(lines)-1]
+
+	// entries are added as rows once all their lines in this data are known
+	var entries [][]string
+	if t.partialInRows {
+		// the unfinished line is added again, as it can parse or group differently once finished
+		entry := t.dropLastRow()
+		if len(entry) > 1 {
+			entries = append(entries, entry[:len(entry)-1])
+		}
+	}
+	t.partialInRows = false
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		t.partialInRows = i == len(lines)-1
+		if t.groupContinuation != nil && t.groupContinuation.MatchString(line) {
+			if len(entries) > 0 {
+				entries[len(entries)-1] = append(entries[len(entries)-1], line)
+				continue
+			}
+			if len(t.rows) > 0 {
+				entries = append(entries, append(t.dropLastRow(), line))
+				continue
+			}
+		}
+		entries = append(entries, []string{line})
+	}
+
+	for _, entry := range entries {
+		t.appendRow(strings.Join(entry, "\n"))
+	}
+}
+
+// DropOldest keeps the newest maxRows rows, as the logs page does. 0 keeps all rows.
+func (t *LogTable) DropOldest(maxRows int) {
+	if maxRows <= 0 || len(t.rows) <= maxRows {
+		return
+	}
+	dropped := len(t.rows) - maxRows
+	// copy the rows kept so the dropped ones can be garbage collected
+	t.rows = append([]page.Row(nil), t.rows[dropped:]...)
+	t.cells = append([][]string(nil), t.cells[dropped:]...)
+}
+
+func (t *LogTable) appendRow(entry string) {
+	var cells []string
+	if t.structured {
+		cells = structuredLogCells(entry, t.columns)
+	}
+	if cells == nil {
+		t.rows = append(t.rows, LogLineAsRow(entry))
+		t.cells = append(t.cells, nil)
+		return
+	}
+
+	if formatter.GrowTableWidths(t.widths, cells) {
+		for i, c := range t.cells {
+			if c != nil {
+				t.rows[i].Row = formatter.GetRenderedTableRow(c, t.widths)
+			}
+		}
+	}
+	t.rows = append(t.rows, page.Row{Key: toLogKey(entry), Row: formatter.GetRenderedTableRow(cells, t.widths)})
+	t.cells = append(t.cells, cells)
+}
+
+// dropLastRow removes the last row, returning the lines of its entry
+func (t *LogTable) dropLastRow() []string {
+	_, entry := LogLineFromKey(t.rows[len(t.rows)-1].Key)
+	t.rows = t.rows[:len(t.rows)-1]
+	t.cells = t.cells[:len(t.cells)-1]
+	return strings.Split(entry, "\n")
+}
+
+// GroupLogLines folds lines matching continuation into the preceding entry, e.g. so that a stack trace is a single
+// entry, joining the lines of each entry with "\n". Blank lines are dropped. A nil continuation returns lines as is.
+func GroupLogLines(lines []string, continuation *regexp.Regexp) []string {
+	if continuation == nil {
+		return lines
+	}
+	var entries []string
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if len(entries) > 0 && continuation.MatchString(line) {
+			entries[len(entries)-1] += "\n" + line
+		} else {
+			entries = append(entries, line)
+		}
+	}
+	return entries
+}
+
 func ReadLogsStreamNextMessage(c LogsStream) tea.Cmd {
 	return func() tea.Msg {
-		line := <-c.Chan
-		cleanedData := formatter.CleanLogs(string(line.Data))
-		return LogsStreamMsg{Value: cleanedData, Type: c.LogType}
+		select {
+		case line, ok := <-c.Chan:
+			if !ok {
+				return LogsStreamClosedMsg{StreamID: c.ID}
+			}
+			cleanedData := cleanLogs(string(line.Data), c.PreserveColors)
+			return LogsStreamMsg{StreamID: c.ID, Value: cleanedData, Type: c.LogType}
+		case err := <-c.Errs:
+			return LogsStreamClosedMsg{StreamID: c.ID, Err: err}
+		}
+	}
+}
+
+func cleanLogs(logs string, preserveColors bool) string {
+	if preserveColors {
+		return formatter.CleanLogsPreservingColors(logs)
+	}
+	return formatter.CleanLogs(logs)
+}
+
+// stripColors removes any color sequences kept by cleanLogs, for when the log line itself needs parsing
+func stripColors(line string) string {
+	if !strings.Contains(line, "\x1b") {
+		return line
 	}
+	return formatter.StripANSI(line)
 }
@@ -0,0 +1,186 @@
+package nomad
+
+import (
+	"bytes"
+	"context"
+	"github.com/hashicorp/nomad/api"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type StreamLogsOptions struct {
+	LogType LogType
+	// Offset is how many bytes before the end of the logs to start from, or all logs if 0
+	Offset int64
+	// Follow keeps streaming new logs until the task finishes or the context is done
+	Follow bool
+	// Since, if not zero, excludes lines with a timestamp before it, along with the lines without a timestamp that
+	// follow them, see LogLineTime
+	Since time.Time
+	// OnReconnect, if not nil, is called before reconnecting a stream that closed while following
+	OnReconnect func(attempt int, err error)
+}
+
+// StreamLogs calls onLine with each line of the logs of the task. While following, a stream that closes before the
+// task finishes is reconnected from where it left off, with increasing delays if it keeps failing.
+func StreamLogs(ctx context.Context, client api.Client, alloc *api.Allocation, task string, opts StreamLogsOptions, onLine func(string)) error {
+	cancel := make(chan struct{})
+	stop := context.AfterFunc(ctx, func() { close(cancel) })
+	defer stop()
+
+	lines := &logLineWriter{since: opts.Since, onLine: onLine}
+	defer lines.flush()
+
+	origin, offset := "end", opts.Offset
+	if offset <= 0 {
+		origin, offset = "start", 0
+	}
+	// file and fileOffset are where the last logs received were read up to
+	var file string
+	var fileOffset int64
+	attempt := 0
+	for {
+		frames, errs := openLogs(client, alloc, task, opts.LogType, opts.Follow, origin, offset, cancel)
+		received, err := readLogFrames(frames, errs, cancel, func(frame *api.StreamFrame) {
+			if frame.File != "" {
+				// a frame's offset is where its data ends in its file
+				file, fileOffset = frame.File, frame.Offset
+			}
+			lines.write(frame.Data)
+		})
+		if ctx.Err() != nil {
+			return nil
+		}
+		if !opts.Follow {
+			return err
+		}
+		if err == nil && logsTaskFinished(client, alloc.ID, task) {
+			return nil
+		}
+
+		if received {
+			attempt = 0
+		}
+		attempt++
+		if opts.OnReconnect != nil {
+			opts.OnReconnect(attempt, err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(EventsReconnectDelay(attempt)):
+		}
+		if file != "" {
+			origin, offset = "end", missedLogBytes(client, alloc, file, fileOffset)
+		}
+	}
+}
+
+// readLogFrames calls onFrame with each frame until the stream ends or is cancelled, returning whether any frames
+// were received
+func readLogFrames(frames <-chan *api.StreamFrame, errs <-chan error, cancel <-chan struct{}, onFrame func(*api.StreamFrame)) (bool, error) {
+	received := false
+	for {
+		select {
+		case <-cancel:
+			return received, nil
+		case err := <-errs:
+			return received, err
+		case frame, ok := <-frames:
+			if !ok {
+				return received, nil
+			}
+			received = true
+			onFrame(frame)
+		}
+	}
+}
+
+func logsTaskFinished(client api.Client, allocID, task string) bool {
+	alloc, _, err := client.Allocations().Info(allocID, nil)
+	if err != nil {
+		return false
+	}
+	state, exists := alloc.TaskStates[task]
+	return exists && state.State == "dead"
+}
+
+// missedLogBytes is how many bytes of logs were written after offset in file, a log file like
+// alloc/logs/redis.stdout.0, including the log files rotated to since
+func missedLogBytes(client api.Client, alloc *api.Allocation, file string, offset int64) int64 {
+	dir, name := path.Split(file)
+	base, index, ok := splitLogFileName(name)
+	if !ok {
+		return 0
+	}
+	files, _, err := client.AllocFS().List(alloc, dir, nil)
+	if err != nil {
+		return 0
+	}
+	var missed int64
+	for _, f := range files {
+		fileBase, fileIndex, ok := splitLogFileName(f.Name)
+		if !ok || fileBase != base || fileIndex < index {
+			continue
+		}
+		if fileIndex == index {
+			missed += max(0, f.Size-offset)
+		} else {
+			missed += f.Size
+		}
+	}
+	return missed
+}
+
+// splitLogFileName splits a log file name like redis.stdout.0 into redis.stdout and 0
+func splitLogFileName(name string) (string, int, bool) {
+	i := strings.LastIndex(name, ".")
+	if i < 0 {
+		return "", 0, false
+	}
+	index, err := strconv.Atoi(name[i+1:])
+	return name[:i], index, err == nil
+}
+
+// logLineWriter splits logs into lines, keeping a partial last line until the rest of it is written
+type logLineWriter struct {
+	since time.Time
+	// included is whether lines without a timestamp are included, following the last line with one
+	included bool
+	partial  []byte
+	onLine   func(string)
+}
+
+func (w *logLineWriter) write(data []byte) {
+	w.partial = append(w.partial, data...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			return
+		}
+		w.line(string(w.partial[:i]))
+		w.partial = w.partial[i+1:]
+	}
+}
+
+func (w *logLineWriter) flush() {
+	if len(w.partial) > 0 {
+		w.line(string(w.partial))
+		w.partial = nil
+	}
+}
+
+func (w *logLineWriter) line(line string) {
+	line = strings.TrimSuffix(line, "\r")
+	if !w.since.IsZero() {
+		if t, ok := LogLineTime(line); ok {
+			w.included = !t.Before(w.since)
+		}
+		if !w.included {
+			return
+		}
+	}
+	w.onLine(line)
+}
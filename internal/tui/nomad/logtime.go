@@ -0,0 +1,38 @@
+package nomad
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// 2023-01-02T15:04:05.000Z, 2023-01-02 15:04:05,000+01:00
+	logTimeRe = regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(?:[.,]\d+)?(?:Z|[+-]\d{2}:?\d{2})?`)
+	// {"ts":1672671845.123}
+	jsonEpochTimeRe = regexp.MustCompile(`"(?i:ts|time|timestamp)"\s*:\s*(\d{10}(?:\.\d+)?)`)
+)
+
+// LogLineTime is when a log line was written, from the first timestamp in it, or false if it has none. Timestamps
+// without a zone are taken to be local.
+func LogLineTime(line string) (time.Time, bool) {
+	if match := logTimeRe.FindString(line); match != "" {
+		match = strings.Replace(strings.Replace(match, " ", "T", 1), ",", ".", 1)
+		for _, layout := range []string{"2006-01-02T15:04:05.999999999Z07:00", "2006-01-02T15:04:05.999999999Z0700"} {
+			if t, err := time.Parse(layout, match); err == nil {
+				return t, true
+			}
+		}
+		if t, err := time.ParseInLocation("2006-01-02T15:04:05.999999999", match, time.Local); err == nil {
+			return t, true
+		}
+		return time.Time{}, false
+	}
+	if match := jsonEpochTimeRe.FindStringSubmatch(line); match != nil {
+		if seconds, err := strconv.ParseFloat(match[1], 64); err == nil {
+			return time.UnixMilli(int64(seconds * 1000)), true
+		}
+	}
+	return time.Time{}, false
+}
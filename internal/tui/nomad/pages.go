@@ -1,6 +1,7 @@
 package nomad
 
 import (
+	"context"
 	"fmt"
 	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
@@ -11,6 +12,7 @@ import (
 	"github.com/robinovitch61/wander/internal/tui/formatter"
 	"github.com/robinovitch61/wander/internal/tui/keymap"
 	"github.com/robinovitch61/wander/internal/tui/style"
+	"regexp"
 	"strings"
 	"time"
 )
@@ -40,6 +42,24 @@ const (
 	AllocAdminConfirmPage
 	JobAdminPage
 	JobAdminConfirmPage
+	ReplayEventsPage
+	ReplayEventPage
+	EventsTopicsPage
+	EventsJQQueryPage
+	EventsPresetsPage
+	EventsPresetNamePage
+	AlertsPage
+	AlertPage
+	EventsSummaryPage
+	EventsGroupPage
+	EventsGroupEventPage
+	ExecAllPage
+	ExecAllResultsPage
+	ExecAllResultPage
+	ExecAllCombinedPage
+	ExecSessionsPage
+	ExecSessionPage
+	CopyPage
 )
 
 func GetAllPageConfigs(width, height int, compactTables bool) map[Page]page.Config {
@@ -125,6 +145,7 @@ func GetAllPageConfigs(width, height int, compactTables bool) map[Page]page.Conf
 			Width: width, Height: height,
 			LoadingString:    LogsPage.LoadingString(),
 			SelectionEnabled: true, WrapText: false, RequestInput: false,
+			RowStyle: logRowStyle,
 		},
 		LoglinePage: {
 			Width: width, Height: height,
@@ -156,11 +177,103 @@ func GetAllPageConfigs(width, height int, compactTables bool) map[Page]page.Conf
 			LoadingString:    JobAdminConfirmPage.LoadingString(),
 			SelectionEnabled: true, WrapText: false, RequestInput: false,
 		},
+		ReplayEventsPage: {
+			Width: width, Height: height,
+			LoadingString:    ReplayEventsPage.LoadingString(),
+			SelectionEnabled: true, WrapText: false, RequestInput: false,
+		},
+		ReplayEventPage: {
+			Width: width, Height: height,
+			LoadingString:    ReplayEventPage.LoadingString(),
+			SelectionEnabled: false, WrapText: true, RequestInput: false,
+		},
+		EventsTopicsPage: {
+			Width: width, Height: height,
+			LoadingString:    EventsTopicsPage.LoadingString(),
+			SelectionEnabled: false, WrapText: true, RequestInput: true,
+		},
+		EventsJQQueryPage: {
+			Width: width, Height: height,
+			LoadingString:    EventsJQQueryPage.LoadingString(),
+			SelectionEnabled: false, WrapText: true, RequestInput: true,
+		},
+		EventsPresetsPage: {
+			Width: width, Height: height,
+			LoadingString:    EventsPresetsPage.LoadingString(),
+			SelectionEnabled: true, WrapText: false, RequestInput: false,
+		},
+		EventsPresetNamePage: {
+			Width: width, Height: height,
+			LoadingString:    EventsPresetNamePage.LoadingString(),
+			SelectionEnabled: false, WrapText: true, RequestInput: true,
+		},
+		AlertsPage: {
+			Width: width, Height: height,
+			LoadingString:    AlertsPage.LoadingString(),
+			SelectionEnabled: true, WrapText: false, RequestInput: false,
+		},
+		AlertPage: {
+			Width: width, Height: height,
+			LoadingString:    AlertPage.LoadingString(),
+			SelectionEnabled: false, WrapText: true, RequestInput: false,
+		},
+		EventsSummaryPage: {
+			Width: width, Height: height,
+			LoadingString:    EventsSummaryPage.LoadingString(),
+			SelectionEnabled: true, WrapText: false, RequestInput: false,
+		},
+		EventsGroupPage: {
+			Width: width, Height: height,
+			LoadingString:    EventsGroupPage.LoadingString(),
+			SelectionEnabled: true, WrapText: false, RequestInput: false,
+		},
+		EventsGroupEventPage: {
+			Width: width, Height: height,
+			LoadingString:    EventsGroupEventPage.LoadingString(),
+			SelectionEnabled: false, WrapText: true, RequestInput: false,
+		},
+		ExecAllPage: {
+			Width: width, Height: height,
+			LoadingString:    ExecAllPage.LoadingString(),
+			SelectionEnabled: false, WrapText: true, RequestInput: true,
+		},
+		CopyPage: {
+			Width: width, Height: height,
+			LoadingString:    CopyPage.LoadingString(),
+			SelectionEnabled: false, WrapText: true, RequestInput: true,
+		},
+		ExecAllResultsPage: {
+			Width: width, Height: height,
+			LoadingString:    "Running command in all allocations...",
+			SelectionEnabled: true, WrapText: false, RequestInput: false,
+			CompactTableContent: compactTables,
+		},
+		ExecAllResultPage: {
+			Width: width, Height: height,
+			LoadingString:    ExecAllResultPage.LoadingString(),
+			SelectionEnabled: false, WrapText: false, RequestInput: false,
+		},
+		ExecAllCombinedPage: {
+			Width: width, Height: height,
+			LoadingString:    ExecAllCombinedPage.LoadingString(),
+			SelectionEnabled: false, WrapText: false, RequestInput: false,
+		},
+		ExecSessionsPage: {
+			Width: width, Height: height,
+			LoadingString:    ExecSessionsPage.LoadingString(),
+			SelectionEnabled: true, WrapText: false, RequestInput: false,
+			CompactTableContent: compactTables,
+		},
+		ExecSessionPage: {
+			Width: width, Height: height,
+			LoadingString:    ExecSessionPage.LoadingString(),
+			SelectionEnabled: false, WrapText: false, RequestInput: false,
+		},
 	}
 }
 
 func (p Page) DoesLoad() bool {
-	noLoadPages := []Page{LoglinePage, JobEventPage, AllocEventPage, AllEventPage}
+	noLoadPages := []Page{LoglinePage, JobEventPage, AllocEventPage, AllEventPage, ReplayEventPage, AlertPage, EventsGroupEventPage}
 	for _, noLoadPage := range noLoadPages {
 		if noLoadPage == p {
 			return false
@@ -184,6 +297,24 @@ func (p Page) DoesReload() bool {
 		AllocAdminConfirmPage,
 		JobAdminPage,
 		JobAdminConfirmPage,
+		ReplayEventsPage,
+		ReplayEventPage,
+		EventsTopicsPage,
+		EventsJQQueryPage,
+		EventsPresetsPage,
+		EventsPresetNamePage,
+		AlertsPage,
+		AlertPage,
+		EventsSummaryPage,
+		EventsGroupPage,
+		EventsGroupEventPage,
+		ExecAllPage,
+		ExecAllResultsPage,
+		ExecAllResultPage,
+		ExecAllCombinedPage,
+		ExecSessionsPage,
+		ExecSessionPage,
+		CopyPage,
 	}
 	for _, noReloadPage := range noReloadPages {
 		if noReloadPage == p {
@@ -203,6 +334,42 @@ func (p Page) ShowsTasks() bool {
 	return false
 }
 
+func (p Page) StreamsEvents() bool {
+	eventsPages := []Page{JobEventsPage, AllocEventsPage, AllEventsPage}
+	for _, eventsPage := range eventsPages {
+		if eventsPage == p {
+			return true
+		}
+	}
+	return false
+}
+
+// EditsEvents is true for pages that change the topics and jq query of the events page they were opened from
+func (p Page) EditsEvents() bool {
+	editPages := []Page{EventsTopicsPage, EventsJQQueryPage, EventsPresetsPage, EventsPresetNamePage}
+	for _, editPage := range editPages {
+		if editPage == p {
+			return true
+		}
+	}
+	return false
+}
+
+// SummarizesEvents is true for the events summary and the pages drilling into it, which follow the stream
+// that the summary is counting
+func (p Page) SummarizesEvents() bool {
+	return p == EventsSummaryPage || p == EventsGroupPage || p == EventsGroupEventPage
+}
+
+func (p Page) requestsInput() bool {
+	return p == ExecPage || p == EventsTopicsPage || p == EventsJQQueryPage || p == EventsPresetNamePage || p == ExecAllPage || p == CopyPage
+}
+
+// CanOpenExecSessions is true for pages that the list of embedded exec sessions can be opened from and returned to
+func (p Page) CanOpenExecSessions() bool {
+	return p != ExecSessionsPage && p != ExecSessionPage && !p.requestsInput() && !p.SummarizesEvents()
+}
+
 func (p Page) HasAdminMenu() bool {
 	adminMenuPages := []Page{AllTasksPage, JobTasksPage, JobsPage}
 	for _, adminMenuPage := range adminMenuPages {
@@ -235,6 +402,24 @@ func (p Page) doesUpdate() bool {
 		AllocAdminConfirmPage, // doesn't load
 		JobAdminPage,          // doesn't load
 		JobAdminConfirmPage,   // doesn't load
+		ReplayEventsPage,      // replays from a file
+		ReplayEventPage,       // doesn't load
+		EventsTopicsPage,      // doesn't reload
+		EventsJQQueryPage,     // doesn't reload
+		EventsPresetsPage,     // doesn't reload
+		EventsPresetNamePage,  // doesn't reload
+		AlertsPage,            // updated as alerts fire
+		AlertPage,             // doesn't load
+		EventsSummaryPage,     // updated as events are received
+		EventsGroupPage,       // shows the events in a group when opened
+		EventsGroupEventPage,  // doesn't load
+		ExecAllPage,           // doesn't reload
+		ExecAllResultsPage,    // would run the command again
+		ExecAllResultPage,     // doesn't reload
+		ExecAllCombinedPage,   // doesn't reload
+		ExecSessionsPage,      // shows the open sessions when opened
+		ExecSessionPage,       // updated as the session outputs
+		CopyPage,              // doesn't reload
 	}
 	for _, noUpdatePage := range noUpdatePages {
 		if noUpdatePage == p {
@@ -262,8 +447,10 @@ func (p Page) String() string {
 		return "meta"
 	case AllEventsPage:
 		return "all events"
-	case JobEventPage, AllocEventPage, AllEventPage:
+	case JobEventPage, AllocEventPage, AllEventPage, ReplayEventPage, EventsGroupEventPage:
 		return "event"
+	case ReplayEventsPage:
+		return "replayed events"
 	case JobTasksPage:
 		return "tasks"
 	case ExecPage:
@@ -284,6 +471,36 @@ func (p Page) String() string {
 		return "job admin menu"
 	case AllocAdminConfirmPage, JobAdminConfirmPage:
 		return "execute"
+	case EventsTopicsPage:
+		return "event topics"
+	case EventsJQQueryPage:
+		return "event jq query"
+	case EventsPresetsPage:
+		return "event presets"
+	case EventsPresetNamePage:
+		return "new event preset"
+	case AlertsPage:
+		return "alerts"
+	case AlertPage:
+		return "alert"
+	case EventsSummaryPage:
+		return "events summary"
+	case EventsGroupPage:
+		return "events in group"
+	case ExecAllPage:
+		return "exec in all allocations"
+	case CopyPage:
+		return "copy files"
+	case ExecAllResultsPage:
+		return "exec results"
+	case ExecAllResultPage:
+		return "output"
+	case ExecAllCombinedPage:
+		return "combined output"
+	case ExecSessionsPage:
+		return "exec sessions"
+	case ExecSessionPage:
+		return "exec session"
 	}
 	return "unknown"
 }
@@ -316,6 +533,18 @@ func (p Page) Forward(inJobsMode bool) Page {
 		return JobAdminConfirmPage
 	case JobAdminConfirmPage:
 		return JobsPage
+	case ReplayEventsPage:
+		return ReplayEventPage
+	case AlertsPage:
+		return AlertPage
+	case EventsSummaryPage:
+		return EventsGroupPage
+	case EventsGroupPage:
+		return EventsGroupEventPage
+	case ExecAllResultsPage:
+		return ExecAllResultPage
+	case ExecSessionsPage:
+		return ExecSessionPage
 	}
 	return p
 }
@@ -367,6 +596,35 @@ func (p Page) Backward(inJobsMode bool) Page {
 		return JobsPage
 	case JobAdminConfirmPage:
 		return JobAdminPage
+	case ReplayEventPage:
+		return ReplayEventsPage
+	case EventsJQQueryPage:
+		return EventsTopicsPage
+	case EventsPresetNamePage:
+		return EventsPresetsPage
+	case AlertsPage:
+		if inJobsMode {
+			return JobsPage
+		}
+		return AllTasksPage
+	case AlertPage:
+		return AlertsPage
+	case EventsGroupPage:
+		return EventsSummaryPage
+	case EventsGroupEventPage:
+		return EventsGroupPage
+	case ExecAllPage:
+		return returnToTasksPage(inJobsMode)
+	case CopyPage:
+		return returnToTasksPage(inJobsMode)
+	case ExecAllResultsPage:
+		return returnToTasksPage(inJobsMode)
+	case ExecAllResultPage:
+		return ExecAllResultsPage
+	case ExecAllCombinedPage:
+		return ExecAllResultsPage
+	case ExecSessionPage:
+		return ExecSessionsPage
 	}
 	return p
 }
@@ -430,20 +688,72 @@ func (p Page) GetFilterPrefix(namespace, jobID, taskName, allocName, allocID str
 		return fmt.Sprintf("Admin Actions for Job %s", style.Bold.Render(jobID))
 	case JobAdminConfirmPage:
 		return fmt.Sprintf("Confirm Admin Action for Job %s", style.Bold.Render(jobID))
+	case ReplayEventsPage:
+		return "Replayed Events"
+	case ReplayEventPage:
+		return "Replayed Event"
+	case EventsTopicsPage:
+		return "Edit Event Topics"
+	case EventsJQQueryPage:
+		return "Edit Event jq Query"
+	case EventsPresetsPage:
+		return "Event Presets"
+	case EventsPresetNamePage:
+		return "Save Event Preset"
+	case AlertsPage:
+		return "Alerts"
+	case AlertPage:
+		return "Alert"
+	case EventsSummaryPage:
+		return "Events Summary"
+	case EventsGroupPage:
+		return "Events in Group"
+	case EventsGroupEventPage:
+		return "Event in Group"
+	case ExecAllPage:
+		return fmt.Sprintf("Exec in Task %s of All Allocations of Job %s", style.Bold.Render(taskName), style.Bold.Render(jobID))
+	case CopyPage:
+		return fmt.Sprintf("Copy Files to or from Task %s of Allocation %s %s", style.Bold.Render(taskName), style.Bold.Render(allocName), formatter.ShortAllocID(allocID))
+	case ExecAllResultsPage:
+		return fmt.Sprintf("Exec Results for Task %s of Job %s", style.Bold.Render(taskName), style.Bold.Render(jobID))
+	case ExecAllResultPage:
+		return fmt.Sprintf("Exec Output for Allocation %s %s", style.Bold.Render(allocName), formatter.ShortAllocID(allocID))
+	case ExecAllCombinedPage:
+		return fmt.Sprintf("Combined Exec Output for Job %s", style.Bold.Render(jobID))
+	case ExecSessionsPage:
+		return "Exec Sessions"
+	case ExecSessionPage:
+		return fmt.Sprintf("Exec Session in Task %s", taskFilterPrefix(taskName, allocName))
 	default:
 		panic("page not found")
 	}
 }
 
 type EventsStream struct {
+	// ID distinguishes messages from this stream from those of previously opened streams
+	ID        int
 	Chan      <-chan *api.Events
 	Topics    Topics
 	Namespace string
+	// Cancel closes the stream
+	Cancel context.CancelFunc
+	// LastIndex is the index of the last events received, from which the stream resumes if it closes
+	LastIndex uint64
 }
 
 type LogsStream struct {
-	Chan    <-chan *api.StreamFrame
+	// ID distinguishes messages from this stream from those of previously opened streams
+	ID   int
+	Chan <-chan *api.StreamFrame
+	// Errs gets the error the stream ends with, if any
+	Errs    <-chan error
 	LogType LogType
+	// Fields are the structured log fields shown as columns, empty if logs are shown raw
+	Fields []string
+	// PreserveColors keeps color sequences in streamed logs
+	PreserveColors bool
+	// GroupContinuation matches lines that continue the previous log entry, nil if lines are not grouped
+	GroupContinuation *regexp.Regexp
 }
 
 type PageLoadedMsg struct {
@@ -452,6 +762,8 @@ type PageLoadedMsg struct {
 	AllPageRows  []page.Row
 	EventsStream EventsStream
 	LogsStream   LogsStream
+	// RecordedEvents are the events being replayed on ReplayEventsPage
+	RecordedEvents []RecordedEvents
 }
 
 type UpdatePageDataMsg struct {
@@ -484,8 +796,16 @@ func GetPageKeyHelp(
 	currentPage Page,
 	filterFocused, filterApplied, saving bool,
 	logType LogType,
+	structuredLogs, warnLogsOnly, recording bool,
 	compact, inJobsMode bool,
+	hasExecSessions, execAttached bool,
+	readOnly bool,
 ) string {
+	if currentPage == ExecSessionPage && execAttached {
+		// every other key goes to the exec session
+		return getShortHelp([]key.Binding{keymap.KeyMap.ExecDetach})
+	}
+
 	if compact {
 		changeKeyHelp(&keymap.KeyMap.Compact, "expand header")
 		return getShortHelp([]key.Binding{keymap.KeyMap.Compact})
@@ -493,7 +813,7 @@ func GetPageKeyHelp(
 		changeKeyHelp(&keymap.KeyMap.Compact, "compact")
 	}
 
-	if filterFocused || currentPage == ExecPage {
+	if filterFocused || currentPage.requestsInput() {
 		keymap.KeyMap.Exit.SetHelp("ctrl+c", "exit")
 	} else {
 		keymap.KeyMap.Exit.SetHelp("q/ctrl+c", "exit")
@@ -511,7 +831,7 @@ func GetPageKeyHelp(
 	viewportKeyMap := viewport.GetKeyMap()
 	secondRow := []key.Binding{viewportKeyMap.Save, keymap.KeyMap.Wrap}
 
-	if currentPage.HasAdminMenu() {
+	if currentPage.HasAdminMenu() && !readOnly {
 		secondRow = append(secondRow, keymap.KeyMap.AdminMenu)
 	}
 
@@ -532,6 +852,14 @@ func GetPageKeyHelp(
 	} else if prevPage := currentPage.Backward(inJobsMode); prevPage != currentPage {
 		changeKeyHelp(&keymap.KeyMap.Back, currentPage.Backward(inJobsMode).String())
 		fourthRow = append(fourthRow, keymap.KeyMap.Back)
+	} else if currentPage.EditsEvents() || currentPage == EventsSummaryPage {
+		// goes back to whichever events page it was opened from
+		changeKeyHelp(&keymap.KeyMap.Back, "events")
+		fourthRow = append(fourthRow, keymap.KeyMap.Back)
+	} else if currentPage == ExecSessionsPage {
+		// goes back to whichever page it was opened from
+		changeKeyHelp(&keymap.KeyMap.Back, "back")
+		fourthRow = append(fourthRow, keymap.KeyMap.Back)
 	}
 
 	if currentPage == JobsPage || currentPage.ShowsTasks() {
@@ -540,6 +868,9 @@ func GetPageKeyHelp(
 		} else if currentPage == AllTasksPage {
 			fourthRow = append(fourthRow, keymap.KeyMap.JobsMode)
 		}
+		if currentPage.CanBeFirstPage() {
+			fourthRow = append(fourthRow, keymap.KeyMap.Alerts)
+		}
 		fourthRow = append(fourthRow, keymap.KeyMap.Spec)
 	} else if currentPage == LogsPage {
 		if logType == StdOut {
@@ -547,6 +878,54 @@ func GetPageKeyHelp(
 		} else {
 			fourthRow = append(fourthRow, keymap.KeyMap.StdOut)
 		}
+		if structuredLogs {
+			changeKeyHelp(&keymap.KeyMap.StructuredLogs, "raw")
+		} else {
+			changeKeyHelp(&keymap.KeyMap.StructuredLogs, "structured")
+		}
+		fourthRow = append(fourthRow, keymap.KeyMap.StructuredLogs)
+		if warnLogsOnly {
+			changeKeyHelp(&keymap.KeyMap.LogLevelFilter, "all levels")
+		} else {
+			changeKeyHelp(&keymap.KeyMap.LogLevelFilter, "warn+ only")
+		}
+		fourthRow = append(fourthRow, keymap.KeyMap.LogLevelFilter, keymap.KeyMap.NextError, keymap.KeyMap.PrevError)
+	} else if currentPage == ExecAllResultsPage {
+		fourthRow = append(fourthRow, keymap.KeyMap.CombinedOutput)
+	} else if currentPage == ExecSessionPage {
+		changeKeyHelp(&keymap.KeyMap.Forward, "attach")
+		fourthRow = append([]key.Binding{keymap.KeyMap.Forward}, fourthRow...)
+		fourthRow = append(fourthRow, keymap.KeyMap.ExecNextTab, keymap.KeyMap.ExecPrevTab, keymap.KeyMap.CloseExecTab)
+	} else if currentPage == ExecSessionsPage {
+		fourthRow = append(fourthRow, keymap.KeyMap.CloseExecTab)
+	} else if currentPage == ReplayEventsPage {
+		fourthRow = append(
+			fourthRow,
+			keymap.KeyMap.ReplayPause,
+			keymap.KeyMap.ReplaySlower,
+			keymap.KeyMap.ReplayFaster,
+			keymap.KeyMap.ReplaySeekBack,
+			keymap.KeyMap.ReplaySeekAhead,
+		)
+	}
+
+	if currentPage.StreamsEvents() {
+		fourthRow = append(fourthRow, keymap.KeyMap.EditEvents, keymap.KeyMap.EventsPresets, keymap.KeyMap.EventsSummary)
+	} else if currentPage == EventsPresetsPage {
+		changeKeyHelp(&keymap.KeyMap.Forward, "apply preset")
+		fourthRow = append([]key.Binding{keymap.KeyMap.Forward}, fourthRow...)
+		if !readOnly {
+			fourthRow = append(fourthRow, keymap.KeyMap.NewPreset)
+		}
+	}
+
+	if currentPage == LogsPage || currentPage.StreamsEvents() {
+		if recording {
+			changeKeyHelp(&keymap.KeyMap.Record, "stop recording")
+		} else {
+			changeKeyHelp(&keymap.KeyMap.Record, "record")
+		}
+		fourthRow = append(fourthRow, keymap.KeyMap.Record)
 	}
 
 	if currentPage == JobsPage {
@@ -558,7 +937,15 @@ func GetPageKeyHelp(
 	if currentPage.ShowsTasks() {
 		fourthRow = append(fourthRow, keymap.KeyMap.AllocEvents)
 		fourthRow = append(fourthRow, keymap.KeyMap.Stats)
-		fourthRow = append(fourthRow, keymap.KeyMap.Exec)
+		if !readOnly {
+			fourthRow = append(fourthRow, keymap.KeyMap.Exec)
+			fourthRow = append(fourthRow, keymap.KeyMap.ExecAll)
+			fourthRow = append(fourthRow, keymap.KeyMap.Copy)
+		}
+	}
+
+	if hasExecSessions && currentPage.CanOpenExecSessions() {
+		fourthRow = append(fourthRow, keymap.KeyMap.ExecSessions)
 	}
 
 	if currentPage == ExecPage {
@@ -567,6 +954,23 @@ func GetPageKeyHelp(
 		return getShortHelp(firstRow) + "\n" + getShortHelp(secondRow)
 	}
 
+	if currentPage.requestsInput() {
+		switch currentPage {
+		case EventsTopicsPage:
+			changeKeyHelp(&keymap.KeyMap.Forward, "edit jq query")
+		case EventsJQQueryPage:
+			changeKeyHelp(&keymap.KeyMap.Forward, "apply")
+		case EventsPresetNamePage:
+			changeKeyHelp(&keymap.KeyMap.Forward, "save preset")
+		case ExecAllPage:
+			changeKeyHelp(&keymap.KeyMap.Forward, "run in all allocations")
+		case CopyPage:
+			changeKeyHelp(&keymap.KeyMap.Forward, "copy")
+		}
+		secondRow = append(fourthRow, keymap.KeyMap.Forward)
+		return getShortHelp(firstRow) + "\n" + getShortHelp(secondRow)
+	}
+
 	if saving {
 		changeKeyHelp(&keymap.KeyMap.Forward, "confirm save")
 		changeKeyHelp(&keymap.KeyMap.Back, "cancel save")
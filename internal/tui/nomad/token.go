@@ -0,0 +1,100 @@
+package nomad
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	tea "github.com/charmbracelet/bubbletea"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenCommandTimeout limits how long a token command can take, e.g. waiting on an OIDC login that never completes
+const tokenCommandTimeout = 2 * time.Minute
+
+// ErrTokenNotAuthorized is returned when listing jobs with a token nomad doesn't know
+var ErrTokenNotAuthorized = errors.New("token not authorized to list jobs")
+
+// TokenSource reads a token from a file or the output of a command, caching it until refreshed, e.g. to use
+// short-lived tokens that are renewed outside of wander
+type TokenSource struct {
+	File, Command string
+
+	mu    sync.Mutex
+	token string
+}
+
+type TokenRefreshedMsg struct {
+	Token string
+	Err   error
+}
+
+// Token is the cached token, read or run for the first time if there is none
+func (s *TokenSource) Token() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != "" {
+		return s.token, nil
+	}
+	return s.load()
+}
+
+// Refresh reads the file or runs the command again, replacing the cached token
+func (s *TokenSource) Refresh() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.load()
+}
+
+func (s *TokenSource) load() (string, error) {
+	var token string
+	if s.File != "" {
+		content, err := os.ReadFile(s.File)
+		if err != nil {
+			return "", fmt.Errorf("could not read token file: %v", err)
+		}
+		token = strings.TrimSpace(string(content))
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), tokenCommandTimeout)
+		defer cancel()
+		var stdout, stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, "sh", "-c", s.Command)
+		cmd.Stdout, cmd.Stderr = &stdout, &stderr
+		if err := cmd.Run(); err != nil {
+			if msg := strings.TrimSpace(stderr.String()); msg != "" {
+				return "", fmt.Errorf("token command failed: %v: %s", err, msg)
+			}
+			return "", fmt.Errorf("token command failed: %v", err)
+		}
+		token = strings.TrimSpace(stdout.String())
+	}
+	if token == "" {
+		return "", errors.New("token file or command gave an empty token")
+	}
+	s.token = token
+	return token, nil
+}
+
+// RefreshToken gets a new token from the source in the background, as commands can take a while
+func RefreshToken(source *TokenSource) tea.Cmd {
+	return func() tea.Msg {
+		token, err := source.Refresh()
+		return TokenRefreshedMsg{Token: token, Err: err}
+	}
+}
+
+// IsACLTokenError is true if err is from nomad not knowing the token or it having expired, which a new token may fix
+func IsACLTokenError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, ErrTokenNotAuthorized) {
+		return true
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "ACL token not found") || strings.Contains(msg, "ACL token expired")
+}
@@ -2,6 +2,7 @@ package nomad
 
 import (
 	"encoding/json"
+	"errors"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/hashicorp/nomad/api"
 	"github.com/robinovitch61/wander/internal/tui/components/page"
@@ -13,6 +14,9 @@ import (
 
 const keySeparator = "|【=◈︿◈=】|"
 
+// ErrReadOnly is returned by actions that change the cluster or run commands in it when wander is read-only
+var ErrReadOnly = errors.New("not allowed in read-only mode")
+
 type AdminAction int8
 
 // all admin actions, task or job
@@ -41,6 +41,12 @@ var (
 	SaveDialogTextStyle           = Regular.Copy().Background(darkred).Foreground(black)
 	StdOut                        = Regular.Copy().UnsetForeground()
 	StdErr                        = Regular.Copy().Foreground(red)
+	LogLevelDebug                 = Regular.Copy().Foreground(grey)
+	LogLevelWarn                  = Regular.Copy().Foreground(yellow)
+	LogLevelError                 = Regular.Copy().Foreground(red)
+	LogLevelFatal                 = Bold.Copy().Foreground(black).Background(red)
+	HeaderStatus                  = Bold.Copy().Foreground(red)
+	InputError                    = Regular.Copy().Foreground(red)
 	SuccessToast                  = Bold.Copy().PaddingLeft(1).Foreground(black).Background(darkgreen)
 	ErrorToast                    = Bold.Copy().PaddingLeft(1).Foreground(black).Background(darkred)
 )
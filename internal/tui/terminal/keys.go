@@ -0,0 +1,84 @@
+package terminal
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+var keySequences = map[tea.KeyType]string{
+	tea.KeyShiftTab:       "\x1b[Z",
+	tea.KeyHome:           "\x1b[H",
+	tea.KeyEnd:            "\x1b[F",
+	tea.KeyPgUp:           "\x1b[5~",
+	tea.KeyPgDown:         "\x1b[6~",
+	tea.KeyCtrlPgUp:       "\x1b[5;5~",
+	tea.KeyCtrlPgDown:     "\x1b[6;5~",
+	tea.KeyDelete:         "\x1b[3~",
+	tea.KeyInsert:         "\x1b[2~",
+	tea.KeySpace:          " ",
+	tea.KeyCtrlUp:         "\x1b[1;5A",
+	tea.KeyCtrlDown:       "\x1b[1;5B",
+	tea.KeyCtrlRight:      "\x1b[1;5C",
+	tea.KeyCtrlLeft:       "\x1b[1;5D",
+	tea.KeyCtrlHome:       "\x1b[1;5H",
+	tea.KeyCtrlEnd:        "\x1b[1;5F",
+	tea.KeyShiftUp:        "\x1b[1;2A",
+	tea.KeyShiftDown:      "\x1b[1;2B",
+	tea.KeyShiftRight:     "\x1b[1;2C",
+	tea.KeyShiftLeft:      "\x1b[1;2D",
+	tea.KeyShiftHome:      "\x1b[1;2H",
+	tea.KeyShiftEnd:       "\x1b[1;2F",
+	tea.KeyCtrlShiftUp:    "\x1b[1;6A",
+	tea.KeyCtrlShiftDown:  "\x1b[1;6B",
+	tea.KeyCtrlShiftRight: "\x1b[1;6C",
+	tea.KeyCtrlShiftLeft:  "\x1b[1;6D",
+	tea.KeyCtrlShiftHome:  "\x1b[1;6H",
+	tea.KeyCtrlShiftEnd:   "\x1b[1;6F",
+	tea.KeyF1:             "\x1bOP",
+	tea.KeyF2:             "\x1bOQ",
+	tea.KeyF3:             "\x1bOR",
+	tea.KeyF4:             "\x1bOS",
+	tea.KeyF5:             "\x1b[15~",
+	tea.KeyF6:             "\x1b[17~",
+	tea.KeyF7:             "\x1b[18~",
+	tea.KeyF8:             "\x1b[19~",
+	tea.KeyF9:             "\x1b[20~",
+	tea.KeyF10:            "\x1b[21~",
+	tea.KeyF11:            "\x1b[23~",
+	tea.KeyF12:            "\x1b[24~",
+}
+
+var cursorKeyFinals = map[tea.KeyType]byte{
+	tea.KeyUp:    'A',
+	tea.KeyDown:  'B',
+	tea.KeyRight: 'C',
+	tea.KeyLeft:  'D',
+}
+
+// KeyBytes is what a terminal sends to the program for a key press. Cursor keys send application sequences if
+// appCursorKeys is true, see Terminal.AppCursorKeys.
+func KeyBytes(msg tea.KeyMsg, appCursorKeys bool) []byte {
+	var b []byte
+	if msg.Alt {
+		b = append(b, 0x1b)
+	}
+	switch {
+	case msg.Type == tea.KeyRunes:
+		b = append(b, string(msg.Runes)...)
+	case msg.Type >= 0:
+		// control characters are sent as they are, e.g. ctrl+c as ETX
+		b = append(b, byte(msg.Type))
+	default:
+		if final, ok := cursorKeyFinals[msg.Type]; ok {
+			if appCursorKeys {
+				b = append(b, 0x1b, 'O', final)
+			} else {
+				b = append(b, 0x1b, '[', final)
+			}
+		} else if seq, ok := keySequences[msg.Type]; ok {
+			b = append(b, seq...)
+		} else {
+			return nil
+		}
+	}
+	return b
+}
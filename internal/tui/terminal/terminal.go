@@ -0,0 +1,873 @@
+package terminal
+
+import (
+	"fmt"
+	"github.com/mattn/go-runewidth"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// color is a cell's foreground or background: defaultColor, one of the 256 indexed colors, or rgbColor with its
+// red, green and blue in the lower 24 bits
+type color int32
+
+const (
+	defaultColor color = -1
+	rgbColor     color = 1 << 24
+	tabWidth           = 8
+	maxCSIParam        = 1 << 16
+)
+
+type attrs struct {
+	fg, bg                                                 color
+	bold, faint, italic, underline, blink, reverse, strike bool
+	hidden                                                 bool
+}
+
+var defaultAttrs = attrs{fg: defaultColor, bg: defaultColor}
+
+// cell is one character on the screen. Wide characters take up two cells, the second with no rune.
+type cell struct {
+	r     rune
+	attrs attrs
+}
+
+type line []cell
+
+type cursor struct {
+	row, col int
+	attrs    attrs
+	// wrapPending is set after writing to the last column, so that the next character wraps to the next line
+	wrapPending bool
+}
+
+type parserState int
+
+const (
+	stateGround parserState = iota
+	stateEscape
+	stateCSI
+	// stateString is inside an OSC, DCS or similar string, which ends with BEL or ST
+	stateString
+	stateStringEscape
+	// stateSkipByte skips the next byte, e.g. the character set of a designation like ESC ( B
+	stateSkipByte
+)
+
+// Terminal emulates enough of a VT100 (and the common xterm extensions) to show interactive programs like shells,
+// top and vim. It is safe to use from multiple goroutines, e.g. one writing output to it while another renders it.
+type Terminal struct {
+	mu            sync.Mutex
+	width, height int
+
+	main, alt []line
+	altActive bool
+	// scrollback are the rendered lines scrolled off the top of the main screen, see renderLine
+	scrollback []string
+	// maxScrollback is how many lines scrolled off the top of the main screen are kept
+	maxScrollback int
+
+	cursor      cursor
+	savedCursor cursor
+	// scrollTop and scrollBottom are the rows of the scrolling region, inclusive
+	scrollTop, scrollBottom int
+
+	autoWrap      bool
+	cursorVisible bool
+	appCursorKeys bool
+	title         string
+
+	state      parserState
+	params     []byte
+	stringData []byte
+	// pending is an incomplete UTF-8 character at the end of the last write
+	pending []byte
+
+	// reply receives what the terminal sends back to the program, e.g. the cursor position when asked for it
+	reply   func([]byte)
+	replies []byte
+}
+
+// New creates a terminal of width by height cells. reply, if not nil, is called with responses to queries from the
+// program, e.g. for the cursor position, which should be written to its input.
+func New(width, height, maxScrollback int, reply func([]byte)) *Terminal {
+	width, height = max(1, width), max(1, height)
+	t := &Terminal{
+		width:         width,
+		height:        height,
+		maxScrollback: maxScrollback,
+		reply:         reply,
+	}
+	t.reset()
+	return t
+}
+
+func (t *Terminal) reset() {
+	t.main = newScreen(t.width, t.height)
+	t.alt = newScreen(t.width, t.height)
+	t.altActive = false
+	t.cursor = cursor{attrs: defaultAttrs}
+	t.savedCursor = t.cursor
+	t.scrollTop, t.scrollBottom = 0, t.height-1
+	t.autoWrap = true
+	t.cursorVisible = true
+	t.appCursorKeys = false
+	t.state = stateGround
+}
+
+func newScreen(width, height int) []line {
+	screen := make([]line, height)
+	for i := range screen {
+		screen[i] = blankLine(width, defaultAttrs)
+	}
+	return screen
+}
+
+func blankLine(width int, a attrs) line {
+	l := make(line, width)
+	for i := range l {
+		l[i] = blankCell(a)
+	}
+	return l
+}
+
+// blankCell is an erased cell, which keeps the current background color like xterm does
+func blankCell(a attrs) cell {
+	return cell{r: ' ', attrs: attrs{fg: defaultColor, bg: a.bg}}
+}
+
+func (t *Terminal) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	data := append(t.pending, p...)
+	t.pending = nil
+	for i := 0; i < len(data); {
+		b := data[i]
+		switch t.state {
+		case stateGround:
+			switch {
+			case b == 0x1b:
+				t.state = stateEscape
+			case b < 0x20 || b == 0x7f:
+				t.control(b)
+			case b < utf8.RuneSelf:
+				t.print(rune(b))
+			default:
+				if !utf8.FullRune(data[i:]) {
+					t.pending = append([]byte(nil), data[i:]...)
+					i = len(data)
+					continue
+				}
+				r, size := utf8.DecodeRune(data[i:])
+				t.print(r)
+				i += size
+				continue
+			}
+		case stateEscape:
+			t.escape(b)
+		case stateCSI:
+			t.csi(b)
+		case stateString:
+			switch b {
+			case 0x07:
+				t.endString()
+			case 0x1b:
+				t.state = stateStringEscape
+			default:
+				t.stringData = append(t.stringData, b)
+			}
+		case stateStringEscape:
+			t.endString()
+			if b != '\\' {
+				// not a string terminator, but the start of another escape sequence
+				t.escape(b)
+			}
+		case stateSkipByte:
+			t.state = stateGround
+		}
+		i++
+	}
+	replies := t.replies
+	t.replies = nil
+	t.mu.Unlock()
+
+	if len(replies) > 0 && t.reply != nil {
+		t.reply(replies)
+	}
+	return len(p), nil
+}
+
+// Resize changes the size of the terminal. Lines that no longer fit above the cursor move to the scrollback.
+func (t *Terminal) Resize(width, height int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	width, height = max(1, width), max(1, height)
+	if width == t.width && height == t.height {
+		return
+	}
+
+	for _, screen := range []*[]line{&t.main, &t.alt} {
+		for i, l := range *screen {
+			(*screen)[i] = resizeLine(l, width)
+		}
+	}
+
+	if height < t.height {
+		excess := t.height - height
+		// first drop rows below the cursor, then push rows above it into the scrollback
+		belowCursor := min(excess, t.height-1-t.cursor.row)
+		t.main = t.main[:t.height-belowCursor]
+		t.alt = t.alt[:t.height-belowCursor]
+		if aboveCursor := excess - belowCursor; aboveCursor > 0 {
+			if !t.altActive {
+				t.addToScrollback(t.main[:aboveCursor])
+			}
+			t.main = t.main[aboveCursor:]
+			t.alt = t.alt[aboveCursor:]
+			t.cursor.row -= aboveCursor
+			t.savedCursor.row = max(0, t.savedCursor.row-aboveCursor)
+		}
+	} else {
+		for i := t.height; i < height; i++ {
+			t.main = append(t.main, blankLine(width, defaultAttrs))
+			t.alt = append(t.alt, blankLine(width, defaultAttrs))
+		}
+	}
+
+	t.width, t.height = width, height
+	t.scrollTop, t.scrollBottom = 0, height-1
+	t.cursor.row, t.cursor.col = min(t.cursor.row, height-1), min(t.cursor.col, width-1)
+	t.cursor.wrapPending = false
+	t.savedCursor.row, t.savedCursor.col = min(t.savedCursor.row, height-1), min(t.savedCursor.col, width-1)
+}
+
+func resizeLine(l line, width int) line {
+	if len(l) >= width {
+		return l[:width]
+	}
+	for len(l) < width {
+		l = append(l, blankCell(defaultAttrs))
+	}
+	return l
+}
+
+// Lines renders the scrollback and the screen, with colors and other attributes as ANSI escape sequences. Blank
+// lines below the cursor aren't included. Full screen programs using the alternate screen show only it, without the
+// scrollback. If showCursor is true and the cursor is visible, it is shown in reverse video.
+func (t *Terminal) Lines(showCursor bool) []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var lines []string
+	screen := t.main
+	if t.altActive {
+		screen = t.alt
+	} else {
+		lines = append(lines, t.scrollback[max(0, len(t.scrollback)-t.maxScrollback):]...)
+		// the screen starts at its first row, and ends at the cursor or last non-blank row
+		last := t.cursor.row
+		for i := len(screen) - 1; i > last; i-- {
+			if !isBlank(screen[i]) {
+				last = i
+				break
+			}
+		}
+		screen = screen[:last+1]
+	}
+
+	for i, l := range screen {
+		cursorCol := -1
+		if showCursor && t.cursorVisible && i == t.cursor.row {
+			cursorCol = t.cursor.col
+		}
+		lines = append(lines, renderLine(l, cursorCol))
+	}
+	return lines
+}
+
+func (t *Terminal) Size() (int, int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.width, t.height
+}
+
+// AppCursorKeys is true if the program asked for the cursor keys to send application sequences, like vim does
+func (t *Terminal) AppCursorKeys() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.appCursorKeys
+}
+
+// Title is the window title last set by the program, if any
+func (t *Terminal) Title() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.title
+}
+
+func isBlank(l line) bool {
+	for _, c := range l {
+		if (c.r != ' ' && c.r != 0) || c.attrs.bg != defaultColor {
+			return false
+		}
+	}
+	return true
+}
+
+// renderLine renders a line without its trailing blank cells, showing the cursor at cursorCol if it's not negative
+func renderLine(l line, cursorCol int) string {
+	end := len(l)
+	for end > 0 && l[end-1].r == ' ' && l[end-1].attrs == defaultAttrs {
+		end--
+	}
+	end = max(end, cursorCol+1)
+
+	var b strings.Builder
+	current := defaultAttrs
+	for i := 0; i < end; i++ {
+		c := cell{r: ' ', attrs: defaultAttrs}
+		if i < len(l) {
+			c = l[i]
+		}
+		if c.r == 0 {
+			// the second cell of a wide character
+			continue
+		}
+		a := c.attrs
+		if i == cursorCol {
+			a.reverse = !a.reverse
+		}
+		if a != current {
+			b.WriteString(sgr(a))
+			current = a
+		}
+		b.WriteRune(c.r)
+	}
+	if current != defaultAttrs {
+		b.WriteString("\x1b[0m")
+	}
+	return b.String()
+}
+
+// sgr is the escape sequence that sets exactly the attributes a
+func sgr(a attrs) string {
+	params := []string{"0"}
+	for _, attr := range []struct {
+		set   bool
+		param string
+	}{
+		{a.bold, "1"},
+		{a.faint, "2"},
+		{a.italic, "3"},
+		{a.underline, "4"},
+		{a.blink, "5"},
+		{a.reverse, "7"},
+		{a.hidden, "8"},
+		{a.strike, "9"},
+	} {
+		if attr.set {
+			params = append(params, attr.param)
+		}
+	}
+	if a.fg != defaultColor {
+		params = append(params, colorParams(a.fg, 30, 90, 38))
+	}
+	if a.bg != defaultColor {
+		params = append(params, colorParams(a.bg, 40, 100, 48))
+	}
+	return fmt.Sprintf("\x1b[%sm", strings.Join(params, ";"))
+}
+
+func colorParams(c color, base, brightBase, extended int) string {
+	switch {
+	case c&rgbColor != 0:
+		return fmt.Sprintf("%d;2;%d;%d;%d", extended, (c>>16)&0xff, (c>>8)&0xff, c&0xff)
+	case c < 8:
+		return strconv.Itoa(base + int(c))
+	case c < 16:
+		return strconv.Itoa(brightBase + int(c) - 8)
+	default:
+		return fmt.Sprintf("%d;5;%d", extended, c)
+	}
+}
+
+func (t *Terminal) screen() []line {
+	if t.altActive {
+		return t.alt
+	}
+	return t.main
+}
+
+func (t *Terminal) print(r rune) {
+	width := runewidth.RuneWidth(r)
+	if width == 0 {
+		// combining characters aren't supported
+		return
+	}
+	if t.cursor.wrapPending && t.autoWrap {
+		t.cursor.col = 0
+		t.lineFeed()
+	}
+	if width == 2 && t.cursor.col == t.width-1 {
+		if !t.autoWrap || t.width < 2 {
+			return
+		}
+		// a wide character doesn't fit on the end of the line
+		t.screen()[t.cursor.row][t.cursor.col] = blankCell(t.cursor.attrs)
+		t.cursor.col = 0
+		t.lineFeed()
+	}
+
+	l := t.screen()[t.cursor.row]
+	l[t.cursor.col] = cell{r: r, attrs: t.cursor.attrs}
+	if width == 2 {
+		l[t.cursor.col+1] = cell{r: 0, attrs: t.cursor.attrs}
+	}
+	if t.cursor.col+width >= t.width {
+		t.cursor.col = t.width - 1
+		t.cursor.wrapPending = true
+	} else {
+		t.cursor.col += width
+		t.cursor.wrapPending = false
+	}
+}
+
+func (t *Terminal) control(b byte) {
+	if b == 0x07 {
+		return
+	}
+	t.cursor.wrapPending = false
+	switch b {
+	case '\b':
+		t.cursor.col = max(0, t.cursor.col-1)
+	case '\t':
+		t.cursor.col = min(t.width-1, (t.cursor.col/tabWidth+1)*tabWidth)
+	case '\n', '\v', '\f':
+		t.lineFeed()
+	case '\r':
+		t.cursor.col = 0
+	}
+}
+
+func (t *Terminal) lineFeed() {
+	t.cursor.wrapPending = false
+	if t.cursor.row == t.scrollBottom {
+		t.scrollUp(1)
+	} else if t.cursor.row < t.height-1 {
+		t.cursor.row++
+	}
+}
+
+func (t *Terminal) reverseIndex() {
+	t.cursor.wrapPending = false
+	if t.cursor.row == t.scrollTop {
+		t.scrollDown(1)
+	} else if t.cursor.row > 0 {
+		t.cursor.row--
+	}
+}
+
+// scrollUp moves the lines of the scrolling region up n, adding blank lines at its bottom. Lines scrolled off the
+// top of the main screen are kept in the scrollback.
+func (t *Terminal) scrollUp(n int) {
+	t.scroll(n, true)
+}
+
+func (t *Terminal) scroll(n int, keepInScrollback bool) {
+	n = min(n, t.scrollBottom-t.scrollTop+1)
+	screen := t.screen()
+	if keepInScrollback && !t.altActive && t.scrollTop == 0 {
+		t.addToScrollback(screen[:n])
+	}
+	copy(screen[t.scrollTop:], screen[t.scrollTop+n:t.scrollBottom+1])
+	for i := t.scrollBottom - n + 1; i <= t.scrollBottom; i++ {
+		screen[i] = blankLine(t.width, t.cursor.attrs)
+	}
+}
+
+// scrollDown moves the lines of the scrolling region down n, adding blank lines at its top
+func (t *Terminal) scrollDown(n int) {
+	n = min(n, t.scrollBottom-t.scrollTop+1)
+	screen := t.screen()
+	copy(screen[t.scrollTop+n:t.scrollBottom+1], screen[t.scrollTop:t.scrollBottom+1-n])
+	for i := t.scrollTop; i < t.scrollTop+n; i++ {
+		screen[i] = blankLine(t.width, t.cursor.attrs)
+	}
+}
+
+func (t *Terminal) addToScrollback(lines []line) {
+	if t.maxScrollback <= 0 {
+		return
+	}
+	// lines can't change once in the scrollback, so are only rendered once
+	for _, l := range lines {
+		t.scrollback = append(t.scrollback, renderLine(l, -1))
+	}
+	if len(t.scrollback) > 2*t.maxScrollback {
+		// copy the lines kept so the dropped ones can be garbage collected, only occasionally as it's expensive
+		t.scrollback = append([]string(nil), t.scrollback[len(t.scrollback)-t.maxScrollback:]...)
+	}
+}
+
+func (t *Terminal) escape(b byte) {
+	t.state = stateGround
+	switch b {
+	case '[':
+		t.state = stateCSI
+		t.params = t.params[:0]
+	case ']', 'P', 'X', '^', '_':
+		// OSC, DCS, SOS, PM and APC strings
+		t.state = stateString
+		t.stringData = append(t.stringData[:0], b)
+	case '(', ')', '*', '+', '#', '%':
+		t.state = stateSkipByte
+	case '7':
+		t.savedCursor = t.cursor
+	case '8':
+		t.cursor = t.savedCursor
+	case 'D':
+		t.lineFeed()
+	case 'E':
+		t.cursor.col = 0
+		t.lineFeed()
+	case 'M':
+		t.reverseIndex()
+	case 'c':
+		t.reset()
+		t.scrollback = nil
+	}
+}
+
+// endString handles a string like an OSC sequence once it's terminated. Only window titles are used.
+func (t *Terminal) endString() {
+	t.state = stateGround
+	if len(t.stringData) == 0 || t.stringData[0] != ']' {
+		return
+	}
+	osc := string(t.stringData[1:])
+	if code, value, found := strings.Cut(osc, ";"); found && (code == "0" || code == "2") {
+		t.title = value
+	}
+}
+
+func (t *Terminal) csi(b byte) {
+	switch {
+	case b == 0x1b:
+		t.state = stateEscape
+	case b < 0x20:
+		t.control(b)
+	case b >= 0x20 && b <= 0x3f:
+		// parameters, private markers and intermediates
+		t.params = append(t.params, b)
+	case b >= 0x40 && b <= 0x7e:
+		t.state = stateGround
+		t.dispatchCSI(b)
+	default:
+		t.state = stateGround
+	}
+}
+
+// csiParams parses the parameters of a CSI sequence, returning its private marker, e.g. '?', and any intermediate
+// bytes. Missing, invalid and negative parameters are 0, so are treated as the default.
+func csiParams(raw []byte) (byte, []int, string) {
+	var private byte
+	if len(raw) > 0 && raw[0] >= '<' && raw[0] <= '?' {
+		private = raw[0]
+		raw = raw[1:]
+	}
+	end := len(raw)
+	for end > 0 && raw[end-1] >= 0x20 && raw[end-1] <= 0x2f {
+		end--
+	}
+	intermediates := string(raw[end:])
+	var params []int
+	if end > 0 {
+		// sub-parameters, e.g. 38:5:208, are treated as parameters
+		for _, p := range strings.Split(strings.ReplaceAll(string(raw[:end]), ":", ";"), ";") {
+			n, err := strconv.Atoi(p)
+			if err != nil || n < 0 {
+				n = 0
+			}
+			// large enough for any row, column or count, small enough that adding to them can't overflow
+			params = append(params, min(n, maxCSIParam))
+		}
+	}
+	return private, params, intermediates
+}
+
+func param(params []int, i, def int) int {
+	if i >= len(params) || params[i] <= 0 {
+		return def
+	}
+	return params[i]
+}
+
+func (t *Terminal) dispatchCSI(final byte) {
+	private, params, intermediates := csiParams(t.params)
+	if intermediates != "" {
+		// e.g. setting the cursor style, which isn't supported
+		return
+	}
+	if private != 0 && private != '?' && final != 'c' {
+		return
+	}
+
+	n := max(1, param(params, 0, 1))
+	screen := t.screen()
+	if final != 'm' && final != 'n' && final != 'c' {
+		t.cursor.wrapPending = false
+	}
+	switch final {
+	case '@':
+		l := screen[t.cursor.row]
+		n = min(n, t.width-t.cursor.col)
+		copy(l[t.cursor.col+n:], l[t.cursor.col:t.width-n])
+		for i := t.cursor.col; i < t.cursor.col+n; i++ {
+			l[i] = blankCell(t.cursor.attrs)
+		}
+	case 'A':
+		t.cursor.row = max(t.topLimit(), t.cursor.row-n)
+	case 'B', 'e':
+		t.cursor.row = min(t.bottomLimit(), t.cursor.row+n)
+	case 'C', 'a':
+		t.cursor.col = min(t.width-1, t.cursor.col+n)
+	case 'D':
+		t.cursor.col = max(0, t.cursor.col-n)
+	case 'E':
+		t.cursor.row = min(t.bottomLimit(), t.cursor.row+n)
+		t.cursor.col = 0
+	case 'F':
+		t.cursor.row = max(t.topLimit(), t.cursor.row-n)
+		t.cursor.col = 0
+	case 'G', '`':
+		t.cursor.col = min(t.width-1, max(0, n-1))
+	case 'H', 'f':
+		t.cursor.row = min(t.height-1, max(0, param(params, 0, 1)-1))
+		t.cursor.col = min(t.width-1, max(0, param(params, 1, 1)-1))
+	case 'd':
+		t.cursor.row = min(t.height-1, max(0, n-1))
+	case 'J':
+		switch param(params, 0, 0) {
+		case 0:
+			t.eraseLine(t.cursor.row, t.cursor.col, t.width)
+			for i := t.cursor.row + 1; i < t.height; i++ {
+				t.eraseLine(i, 0, t.width)
+			}
+		case 1:
+			for i := 0; i < t.cursor.row; i++ {
+				t.eraseLine(i, 0, t.width)
+			}
+			t.eraseLine(t.cursor.row, 0, t.cursor.col+1)
+		case 2:
+			for i := 0; i < t.height; i++ {
+				t.eraseLine(i, 0, t.width)
+			}
+		case 3:
+			t.scrollback = nil
+		}
+	case 'K':
+		switch param(params, 0, 0) {
+		case 0:
+			t.eraseLine(t.cursor.row, t.cursor.col, t.width)
+		case 1:
+			t.eraseLine(t.cursor.row, 0, t.cursor.col+1)
+		case 2:
+			t.eraseLine(t.cursor.row, 0, t.width)
+		}
+	case 'L', 'M':
+		if t.cursor.row < t.scrollTop || t.cursor.row > t.scrollBottom {
+			return
+		}
+		// insert or delete lines by scrolling the part of the region from the cursor down
+		top := t.scrollTop
+		t.scrollTop = t.cursor.row
+		if final == 'L' {
+			t.scrollDown(n)
+		} else {
+			// deleted lines never go to the scrollback
+			t.scroll(n, false)
+		}
+		t.scrollTop = top
+		t.cursor.col = 0
+	case 'P':
+		l := screen[t.cursor.row]
+		n = min(n, t.width-t.cursor.col)
+		copy(l[t.cursor.col:], l[t.cursor.col+n:])
+		for i := t.width - n; i < t.width; i++ {
+			l[i] = blankCell(t.cursor.attrs)
+		}
+	case 'X':
+		t.eraseLine(t.cursor.row, t.cursor.col, min(t.width, t.cursor.col+n))
+	case 'S':
+		t.scrollUp(n)
+	case 'T':
+		t.scrollDown(n)
+	case 'm':
+		if private == 0 {
+			t.setAttrs(params)
+		}
+	case 'r':
+		top, bottom := param(params, 0, 1)-1, param(params, 1, t.height)-1
+		if 0 <= top && top < bottom && bottom < t.height {
+			t.scrollTop, t.scrollBottom = top, bottom
+			t.cursor.row, t.cursor.col = 0, 0
+		}
+	case 's':
+		if private == 0 {
+			t.savedCursor = t.cursor
+		}
+	case 'u':
+		if private == 0 {
+			t.cursor = t.savedCursor
+		}
+	case 'h', 'l':
+		if private == '?' {
+			for _, mode := range params {
+				t.setPrivateMode(mode, final == 'h')
+			}
+		}
+	case 'n':
+		switch param(params, 0, 0) {
+		case 5:
+			t.replies = append(t.replies, "\x1b[0n"...)
+		case 6:
+			t.replies = append(t.replies, fmt.Sprintf("\x1b[%d;%dR", t.cursor.row+1, t.cursor.col+1)...)
+		}
+	case 'c':
+		if private == '>' {
+			t.replies = append(t.replies, "\x1b[>0;0;0c"...)
+		} else if private == 0 {
+			// a VT100 with advanced video
+			t.replies = append(t.replies, "\x1b[?1;2c"...)
+		}
+	}
+}
+
+// topLimit and bottomLimit are how far the cursor can move up and down: the scrolling region if it's in it
+func (t *Terminal) topLimit() int {
+	if t.cursor.row >= t.scrollTop {
+		return t.scrollTop
+	}
+	return 0
+}
+
+func (t *Terminal) bottomLimit() int {
+	if t.cursor.row <= t.scrollBottom {
+		return t.scrollBottom
+	}
+	return t.height - 1
+}
+
+func (t *Terminal) eraseLine(row, start, end int) {
+	l := t.screen()[row]
+	for i := max(0, start); i < min(end, len(l)); i++ {
+		l[i] = blankCell(t.cursor.attrs)
+	}
+}
+
+func (t *Terminal) setPrivateMode(mode int, set bool) {
+	switch mode {
+	case 1:
+		t.appCursorKeys = set
+	case 7:
+		t.autoWrap = set
+	case 25:
+		t.cursorVisible = set
+	case 47, 1047, 1049:
+		if set == t.altActive {
+			return
+		}
+		if mode == 1049 && set {
+			t.savedCursor = t.cursor
+		}
+		t.altActive = set
+		if set {
+			t.alt = newScreen(t.width, t.height)
+		}
+		if mode == 1049 && !set {
+			t.cursor = t.savedCursor
+		}
+		t.scrollTop, t.scrollBottom = 0, t.height-1
+	}
+}
+
+func (t *Terminal) setAttrs(params []int) {
+	if len(params) == 0 {
+		params = []int{0}
+	}
+	a := &t.cursor.attrs
+	for i := 0; i < len(params); i++ {
+		p := params[i]
+		switch {
+		case p == 0:
+			*a = defaultAttrs
+		case p == 1:
+			a.bold = true
+		case p == 2:
+			a.faint = true
+		case p == 3:
+			a.italic = true
+		case p == 4:
+			a.underline = true
+		case p == 5 || p == 6:
+			a.blink = true
+		case p == 7:
+			a.reverse = true
+		case p == 8:
+			a.hidden = true
+		case p == 9:
+			a.strike = true
+		case p == 21 || p == 22:
+			a.bold, a.faint = false, false
+		case p == 23:
+			a.italic = false
+		case p == 24:
+			a.underline = false
+		case p == 25:
+			a.blink = false
+		case p == 27:
+			a.reverse = false
+		case p == 28:
+			a.hidden = false
+		case p == 29:
+			a.strike = false
+		case p >= 30 && p <= 37:
+			a.fg = color(p - 30)
+		case p == 38:
+			a.fg, i = extendedColor(params, i)
+		case p == 39:
+			a.fg = defaultColor
+		case p >= 40 && p <= 47:
+			a.bg = color(p - 40)
+		case p == 48:
+			a.bg, i = extendedColor(params, i)
+		case p == 49:
+			a.bg = defaultColor
+		case p >= 90 && p <= 97:
+			a.fg = color(p - 90 + 8)
+		case p >= 100 && p <= 107:
+			a.bg = color(p - 100 + 8)
+		}
+	}
+}
+
+// extendedColor parses a 256 or true color starting at the 38 or 48 at params[i], returning the index of its last
+// parameter
+func extendedColor(params []int, i int) (color, int) {
+	if i+1 >= len(params) {
+		return defaultColor, i
+	}
+	switch params[i+1] {
+	case 5:
+		if i+2 < len(params) {
+			return color(params[i+2] & 0xff), i + 2
+		}
+	case 2:
+		if i+4 < len(params) {
+			r, g, b := params[i+2]&0xff, params[i+3]&0xff, params[i+4]&0xff
+			return rgbColor | color(r<<16|g<<8|b), i + 4
+		}
+	}
+	return defaultColor, len(params) - 1
+}